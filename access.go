@@ -0,0 +1,124 @@
+package main
+
+import (
+	"github.com/slevchyk/taskeram/models"
+)
+
+//Permissions checked by the bot menus and the web handlers
+const (
+	permViewUsers      = "view_users"
+	permApproveUsers   = "approve_users"
+	permBanUsers       = "ban_users"
+	permCreateTasks    = "create_tasks"
+	permAssignOthers   = "assign_others"
+	permAssignGuests   = "assign_guests"
+	permViewAllTasks   = "view_all_tasks"
+	permEditProfiles   = "edit_profiles"
+	permChangeRoles    = "change_roles"
+	permManageWebhooks = "manage_webhooks"
+	permManageTeams    = "manage_teams"
+	permAllTeams       = "all_teams"
+	permBackup         = "backup"
+)
+
+var roles = []string{models.RoleAdmin, models.RoleManager, models.RoleMember, models.RoleGuest}
+
+//rolePermissions is the permission matrix. Users may always view and edit their own profile and Tasks
+var rolePermissions = map[string]map[string]bool{
+	models.RoleAdmin: {
+		permViewUsers:      true,
+		permApproveUsers:   true,
+		permBanUsers:       true,
+		permCreateTasks:    true,
+		permAssignOthers:   true,
+		permAssignGuests:   true,
+		permViewAllTasks:   true,
+		permEditProfiles:   true,
+		permChangeRoles:    true,
+		permManageWebhooks: true,
+		permManageTeams:    true,
+		permAllTeams:       true,
+		permBackup:         true,
+	},
+	models.RoleManager: {
+		permViewUsers:    true,
+		permApproveUsers: true,
+		permCreateTasks:  true,
+		permAssignOthers: true,
+		permAssignGuests: true,
+		permViewAllTasks: true,
+		permAllTeams:     true,
+	},
+	models.RoleMember: {
+		permCreateTasks:  true,
+		permAssignOthers: true,
+	},
+	models.RoleGuest: {},
+}
+
+//userRole returns the user role. Users created before roles were introduced are members
+func userRole(u models.DbUsers) string {
+
+	if _, ok := rolePermissions[u.Role]; ok {
+		return u.Role
+	}
+
+	if u.Admin == 1 {
+		return models.RoleAdmin
+	}
+
+	return models.RoleMember
+}
+
+func isRole(role string) bool {
+
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+//can reports whether an approved user has the permission
+func can(u models.DbUsers, perm string) bool {
+
+	if u.ID == 0 || u.Status != models.UserApprowed {
+		return false
+	}
+
+	return rolePermissions[userRole(u)][perm]
+}
+
+//canAssignTask reports whether from may create a Task for to.
+//Users are limited to their teams unless their role works across all teams
+func canAssignTask(from models.DbUsers, to models.DbUsers) bool {
+
+	if !can(from, permCreateTasks) {
+		return false
+	}
+
+	if from.TelegramID == to.TelegramID {
+		return true
+	}
+
+	if !can(from, permAllTeams) && !inSameTeam(from, to) {
+		return false
+	}
+
+	if userRole(to) == models.RoleGuest {
+		return can(from, permAssignGuests)
+	}
+
+	return can(from, permAssignOthers)
+}
+
+//canViewTask reports whether the user may see the Task. Team leads see all Tasks of their teams
+func canViewTask(u models.DbUsers, t models.DbTasks) bool {
+
+	if t.FromUser == u.TelegramID || t.ToUser == u.TelegramID {
+		return true
+	}
+
+	if can(u, permViewAllTasks) {
+		return true
+	}
+
+	return leadsTeamOf(u, t.ToUser) || leadsTeamOf(u, t.FromUser)
+}
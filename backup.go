@@ -0,0 +1,264 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/slevchyk/taskeram/dbase"
+	"github.com/slevchyk/taskeram/models"
+	"gopkg.in/telegram-bot-api.v4"
+)
+
+const (
+	backupFormatSqlite = "sqlite"
+	backupFormatJSON   = "json"
+	//backupVersion is the version of JSON dump format
+	backupVersion = 1
+)
+
+//sqliteHeader starts every sqlite database file, restore tells snapshots from JSON dumps by it
+var sqliteHeader = []byte("SQLite format 3\x00")
+
+//userpicsDir is where utils.UploadUserpic keeps userpics
+var userpicsDir = filepath.Join("public", "userpics")
+
+//defaultBackupFormat is sqlite snapshot for sqlite DB and JSON dump for postgres
+func defaultBackupFormat() string {
+
+	if cfg.Database.Type == dbase.Postgres {
+		return backupFormatJSON
+	}
+
+	return backupFormatSqlite
+}
+
+//readUserpics reads all files under userpicsDir, e.g. <tgid>/<hash>.jpg. Keys are paths relative to it with forward slashes
+func readUserpics() (map[string][]byte, error) {
+
+	xs := make(map[string][]byte)
+
+	err := filepath.Walk(userpicsDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(userpicsDir, path)
+		if err != nil {
+			return err
+		}
+
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		xs[filepath.ToSlash(rel)] = b
+
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return xs, nil
+	}
+
+	return xs, err
+}
+
+//userpicPath returns where the restored userpic is saved. Names leaving userpicsDir are rejected
+func userpicPath(name string) (string, error) {
+
+	if strings.Contains(name, "..") || strings.Contains(name, "\\") {
+		return "", fmt.Errorf("wrong userpic name %v", name)
+	}
+
+	for _, val := range strings.Split(name, "/") {
+		if val == "" || val == "." {
+			return "", fmt.Errorf("wrong userpic name %v", name)
+		}
+	}
+
+	return filepath.Join(userpicsDir, filepath.FromSlash(name)), nil
+}
+
+//writeUserpics saves restored userpics with their subdirectories, existing files are kept
+func writeUserpics(pics map[string][]byte) error {
+
+	for name, b := range pics {
+		path, err := userpicPath(name)
+		if err != nil {
+			return err
+		}
+
+		if fileExists(path) {
+			continue
+		}
+
+		err = os.MkdirAll(filepath.Dir(path), os.ModePerm)
+		if err != nil {
+			return err
+		}
+
+		err = ioutil.WriteFile(path, b, 0644)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//backupDB writes consistent copy of DB with userpics to path which must not exist.
+//format is sqlite snapshot made by VACUUM INTO or portable JSON dump which can be restored to DB of any type
+func backupDB(path string, format string) error {
+
+	if fileExists(path) {
+		return fmt.Errorf("%v already exists", path)
+	}
+
+	pics, err := readUserpics()
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case backupFormatSqlite:
+		return dbase.Backup(cfg, path, pics)
+	case backupFormatJSON:
+	default:
+		return fmt.Errorf("backup format should be %v or %v", backupFormatSqlite, backupFormatJSON)
+	}
+
+	d := models.DbDump{
+		Version:   backupVersion,
+		CreatedAt: time.Now().UTC(),
+		Database:  cfg.Database.Type,
+		Userpics:  pics,
+	}
+
+	d.Tables, err = dbase.Dump(cfg)
+	if err != nil {
+		return err
+	}
+
+	//the dump has API tokens and sessions, so it is readable by the owner only
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+
+	err = json.NewEncoder(f).Encode(d)
+	if err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+
+	return f.Close()
+}
+
+//readBackup reads sqlite snapshot or JSON dump made by backupDB
+func readBackup(path string) (models.DbDump, error) {
+
+	var d models.DbDump
+
+	f, err := os.Open(path)
+	if err != nil {
+		return d, err
+	}
+	defer f.Close()
+
+	header := make([]byte, len(sqliteHeader))
+	_, err = io.ReadFull(f, header)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return d, err
+	}
+
+	if bytes.Equal(header, sqliteHeader) {
+		return dbase.ReadSnapshot(path)
+	}
+
+	_, err = f.Seek(0, io.SeekStart)
+	if err != nil {
+		return d, err
+	}
+
+	d, err = dbase.DecodeDump(f)
+	if err != nil {
+		return d, fmt.Errorf("%v is neither sqlite snapshot nor JSON dump: %v", path, err)
+	}
+
+	if d.Version > backupVersion {
+		return d, fmt.Errorf("%v is made by a newer version of taskeram", path)
+	}
+
+	return d, nil
+}
+
+//restoreDB loads backup into empty DB of any type and returns number of rows in every table
+func restoreDB(path string) (map[string]int, error) {
+
+	d, err := readBackup(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(d.Tables) == 0 {
+		return nil, errors.New("backup has no tables")
+	}
+
+	dbase.InitDB(cfg)
+
+	counts, err := dbase.Restore(cfg, d.Tables)
+	if err != nil {
+		return counts, err
+	}
+
+	return counts, writeUserpics(d.Userpics)
+}
+
+//handleCommandBackup sends a backup of DB to the admin as a document
+func handleCommandBackup(c *models.UserCache) {
+
+	if !can(c.User, permBackup) {
+		return
+	}
+
+	dir, err := ioutil.TempDir("", "taskeram")
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	format := defaultBackupFormat()
+	path := filepath.Join(dir, fmt.Sprintf("taskeram-%v.%v", time.Now().UTC().Format("20060102-150405"), format))
+
+	err = backupDB(path, format)
+	if err != nil {
+		log.Println(fmt.Errorf("backup: %v", err))
+
+		msg := tgbotapi.NewMessage(c.ChatID, "Something went wrong while making backup")
+		_, err := bot.Send(msg)
+		if err != nil {
+			log.Println(err)
+		}
+		return
+	}
+
+	doc := tgbotapi.NewDocumentUpload(c.ChatID, path)
+	doc.Caption = "Restore it by taskeram restore " + filepath.Base(path)
+	_, err = bot.Send(doc)
+	if err != nil {
+		log.Println(err)
+	}
+}
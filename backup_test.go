@@ -0,0 +1,116 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUserpics(t *testing.T) {
+
+	defer func(dir string) { userpicsDir = dir }(userpicsDir)
+
+	userpicsDir = filepath.Join(t.TempDir(), "userpics")
+
+	pics, err := readUserpics()
+	if err != nil || len(pics) != 0 {
+		t.Fatalf("without userpics dir: got %v, %v", pics, err)
+	}
+
+	files := map[string]string{
+		"42/5d41402a.jpg":        "new",
+		"42/5d41402a-origin.png": "origin",
+		"old.jpg":                "old",
+	}
+
+	for name, data := range files {
+		path := filepath.Join(userpicsDir, filepath.FromSlash(name))
+		err := os.MkdirAll(filepath.Dir(path), os.ModePerm)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = ioutil.WriteFile(path, []byte(data), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pics, err = readUserpics()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pics) != len(files) {
+		t.Errorf("read %v userpics, want %v", len(pics), len(files))
+	}
+	for name, data := range files {
+		if string(pics[name]) != data {
+			t.Errorf("userpic %v is %q, want %q", name, pics[name], data)
+		}
+	}
+
+	//відновлюємо в інший каталог, підкаталоги мають створитися
+	userpicsDir = filepath.Join(t.TempDir(), "restored")
+
+	err = writeUserpics(pics)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, data := range files {
+		b, err := ioutil.ReadFile(filepath.Join(userpicsDir, filepath.FromSlash(name)))
+		if err != nil || string(b) != data {
+			t.Errorf("restored userpic %v is %q, %v", name, b, err)
+		}
+	}
+
+	for _, name := range []string{"../evil.jpg", "42/../../evil.jpg", "/etc/evil.jpg", "42//evil.jpg", `..\evil.jpg`, ""} {
+		err := writeUserpics(map[string][]byte{name: []byte("evil")})
+		if err == nil {
+			t.Errorf("userpic %q is written", name)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(filepath.Dir(userpicsDir), "evil.jpg")); err == nil {
+		t.Error("userpic is written outside userpics dir")
+	}
+}
+
+func TestBackupIsPrivate(t *testing.T) {
+
+	setupTestDB(t)
+
+	defer func(dir string) { userpicsDir = dir }(userpicsDir)
+	userpicsDir = filepath.Join(t.TempDir(), "userpics")
+
+	for _, format := range []string{backupFormatSqlite, backupFormatJSON} {
+		path := filepath.Join(t.TempDir(), "taskeram."+format)
+
+		err := backupDB(path, format)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if info.Mode().Perm() != 0600 {
+			t.Errorf("%v backup has mode %v, want %v", format, info.Mode().Perm(), os.FileMode(0600))
+		}
+
+		d, err := readBackup(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, table := range d.Tables {
+			if table.Name == "tasks" && len(table.Rows) != 1 {
+				t.Errorf("%v backup has %v Tasks, want 1", format, len(table.Rows))
+			}
+		}
+	}
+}
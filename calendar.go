@@ -0,0 +1,356 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/slevchyk/taskeram/dbase"
+	"github.com/slevchyk/taskeram/models"
+	"github.com/slevchyk/taskeram/router"
+)
+
+const (
+	calendarEvent = "event"
+	calendarTodo  = "todo"
+	//calendarStampLayout is UTC date-time of iCalendar
+	calendarStampLayout = "20060102T150405Z"
+	calendarDateLayout  = "20060102"
+)
+
+//calendarTask is a Task of the feed with the data which isn't kept in tasks table
+type calendarTask struct {
+	Task      models.DbTasks
+	FromUser  models.DbUsers
+	CreatedAt time.Time
+	//Sequence grows every time the Task status is changed, so calendar apps update the entry
+	Sequence int
+}
+
+//newCalendarToken replaces user calendar token with a new one and returns it. Only the hash is kept in DB
+func newCalendarToken(tgid int) (string, error) {
+
+	b := make([]byte, 24)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+
+	err = deleteCalendarToken(tgid)
+	if err != nil {
+		return "", err
+	}
+
+	stmt, err := dbase.InsertCalendarToken(cfg)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = dbase.ExecInsertAPIToken(stmt, models.DbAPITokens{
+		TelegramID: tgid,
+		Token:      hashToken(token),
+		CreatedAt:  models.NullTime{Time: time.Now().UTC(), Valid: true},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+//deleteCalendarToken turns the calendar feed of the user off, subscribed apps get 404
+func deleteCalendarToken(tgid int) error {
+
+	stmt, err := dbase.DeleteCalendarTokensByTelegramID(cfg)
+	if err != nil {
+		return err
+	}
+
+	_, err = stmt.Exec(tgid)
+	return err
+}
+
+//hasCalendarToken reports whether the user has turned the calendar feed on
+func hasCalendarToken(tgid int) (bool, error) {
+
+	rows, err := dbase.SelectCalendarTokensByTelegramID(cfg, tgid)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	return rows.Next(), rows.Err()
+}
+
+//calendarUser finds the approved owner of the calendar token
+func calendarUser(token string) (models.DbUsers, bool) {
+
+	var t models.DbAPITokens
+
+	if token == "" {
+		return models.DbUsers{}, false
+	}
+
+	rows, err := dbase.SelectCalendarTokenByToken(cfg, hashToken(token))
+	if err != nil {
+		log.Println(fmt.Errorf("SelectCalendarTokenByToken: %v", err))
+		return models.DbUsers{}, false
+	}
+
+	if rows.Next() {
+		err := dbase.ScanAPIToken(rows, &t)
+		if err != nil {
+			log.Println(err)
+		}
+	}
+	rows.Close()
+
+	if t.ID == 0 {
+		return models.DbUsers{}, false
+	}
+
+	u := dbase.GetUserByTelegramID(cfg, t.TelegramID)
+	if u.ID == 0 || u.Status != models.UserApprowed {
+		return u, false
+	}
+
+	return u, true
+}
+
+//calendarURL returns subscription URL of the feed. Without public URL in config the host of the request is used
+func calendarURL(r *http.Request, token string) string {
+
+	base := strings.TrimRight(cfg.Web.URL, "/")
+	if base == "" {
+		scheme := "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+		base = fmt.Sprintf("%v://%v", scheme, r.Host)
+	}
+
+	return fmt.Sprintf("%v/calendar/%v.ics", base, token)
+}
+
+//selectCalendarTasks returns Inbox Tasks of the user which have due date
+func selectCalendarTasks(u models.DbUsers) ([]calendarTask, error) {
+
+	var t models.DbTasks
+	var h models.DbHistory
+	var xs []calendarTask
+
+	users := make(map[int]models.DbUsers)
+
+	for _, status := range taskStatuses {
+		rows, err := dbase.SelectInboxTasks(cfg, u.TelegramID, status)
+		if err != nil {
+			return xs, err
+		}
+
+		for rows.Next() {
+			err := dbase.ScanTask(rows, &t)
+			if err != nil {
+				rows.Close()
+				return xs, err
+			}
+
+			if t.DueDate.Valid {
+				xs = append(xs, calendarTask{Task: t, CreatedAt: t.ChangedAt.Time})
+			}
+		}
+		rows.Close()
+	}
+
+	for i, ct := range xs {
+		if _, ok := users[ct.Task.FromUser]; !ok {
+			users[ct.Task.FromUser] = dbase.GetUserByTelegramID(cfg, ct.Task.FromUser)
+		}
+		xs[i].FromUser = users[ct.Task.FromUser]
+
+		rows, err := dbase.SelectHistory(cfg, ct.Task.ID, u.TelegramID)
+		if err != nil {
+			return xs, err
+		}
+
+		n := 0
+		for rows.Next() {
+			err := dbase.ScanHistory(rows, &h)
+			if err != nil {
+				rows.Close()
+				return xs, err
+			}
+
+			if n == 0 {
+				xs[i].CreatedAt = h.HDb.Date.Time
+			}
+			n++
+		}
+		rows.Close()
+
+		if n > 0 {
+			xs[i].Sequence = n - 1
+		}
+	}
+
+	return xs, nil
+}
+
+//calendarText escapes TEXT value of iCalendar property
+func calendarText(val string) string {
+
+	r := strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`)
+	return r.Replace(val)
+}
+
+//writeCalendarLine writes content line folded to 75 octets without splitting UTF-8 characters
+func writeCalendarLine(b *bytes.Buffer, line string) {
+
+	limit := 75
+	for len(line) > limit {
+		i := limit
+		for i > 0 && line[i]&0xC0 == 0x80 {
+			i--
+		}
+		b.WriteString(line[:i] + "\r\n ")
+		line = line[i:]
+		//the leading space of continuation line counts too
+		limit = 74
+	}
+
+	b.WriteString(line + "\r\n")
+}
+
+//calendarTodoStatus maps Task status to VTODO status
+func calendarTodoStatus(status string) string {
+
+	switch status {
+	case models.TaskStatusStarted:
+		return "IN-PROCESS"
+	case models.TaskStatusCompleted, models.TaskStatusClosed:
+		return "COMPLETED"
+	case models.TaskStatusRejected:
+		return "CANCELLED"
+	default:
+		return "NEEDS-ACTION"
+	}
+}
+
+//writeCalendar writes Tasks as all-day VEVENT entries on the due date or as VTODO entries
+func writeCalendar(b *bytes.Buffer, kind string, tasks []calendarTask) {
+
+	line := func(format string, a ...interface{}) {
+		writeCalendarLine(b, fmt.Sprintf(format, a...))
+	}
+
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//taskeram//Inbox Tasks//EN")
+	line("CALSCALE:GREGORIAN")
+	line("METHOD:PUBLISH")
+	line("X-WR-CALNAME:%v", calendarText("Taskeram Inbox"))
+	line("REFRESH-INTERVAL;VALUE=DURATION:PT1H")
+	line("X-PUBLISHED-TTL:PT1H")
+
+	for _, ct := range tasks {
+		t := ct.Task
+
+		component := "VEVENT"
+		if kind == calendarTodo {
+			component = "VTODO"
+		}
+
+		description := fmt.Sprintf("Status: %v\nFrom: %v", t.Status, strings.TrimSpace(ct.FromUser.FirstName+" "+ct.FromUser.LastName))
+		if t.Priority != "" {
+			description += "\nPriority: " + t.Priority
+		}
+		if t.Description != "" {
+			description += "\n\n" + t.Description
+		}
+
+		line("BEGIN:%v", component)
+		line("UID:taskeram-task-%v-%v", t.ID, kind)
+		line("DTSTAMP:%v", t.ChangedAt.Time.UTC().Format(calendarStampLayout))
+		line("CREATED:%v", ct.CreatedAt.UTC().Format(calendarStampLayout))
+		line("LAST-MODIFIED:%v", t.ChangedAt.Time.UTC().Format(calendarStampLayout))
+		line("SEQUENCE:%v", ct.Sequence)
+		line("SUMMARY:%v", calendarText(fmt.Sprintf("#%v %v", t.ID, t.Title)))
+		line("DESCRIPTION:%v", calendarText(description))
+		if url := taskWebURL(t.ID); url != "" {
+			line("URL:%v", url)
+		}
+
+		due := t.DueDate.Time
+		if kind == calendarTodo {
+			line("DUE;VALUE=DATE:%v", due.Format(calendarDateLayout))
+			line("STATUS:%v", calendarTodoStatus(t.Status))
+			if t.Status == models.TaskStatusCompleted || t.Status == models.TaskStatusClosed {
+				line("COMPLETED:%v", t.ChangedAt.Time.UTC().Format(calendarStampLayout))
+			}
+		} else {
+			line("DTSTART;VALUE=DATE:%v", due.Format(calendarDateLayout))
+			line("DTEND;VALUE=DATE:%v", due.AddDate(0, 0, 1).Format(calendarDateLayout))
+			line("TRANSP:TRANSPARENT")
+			if t.Status == models.TaskStatusRejected {
+				line("STATUS:CANCELLED")
+			} else {
+				line("STATUS:CONFIRMED")
+			}
+		}
+
+		if t.Priority == models.PriorityHigh {
+			line("PRIORITY:1")
+		} else if t.Priority == models.PriorityLow {
+			line("PRIORITY:9")
+		}
+
+		line("END:%v", component)
+	}
+
+	line("END:VCALENDAR")
+}
+
+//calendarHandler serves /calendar/<token>.ics, the feed of Inbox Tasks with due date for calendar apps.
+//Tasks are VEVENT entries, ?kind=todo makes them VTODO. The token is the only credential, wrong one gets 404
+func calendarHandler(w http.ResponseWriter, r *http.Request) {
+
+	token := strings.TrimSuffix(router.Param(r, "token"), ".ics")
+
+	u, ok := calendarUser(token)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	kind := r.FormValue("kind")
+	if kind == "" {
+		kind = calendarEvent
+	}
+	if kind != calendarEvent && kind != calendarTodo {
+		http.Error(w, fmt.Sprintf("Wrong kind %v, expected %v or %v", kind, calendarEvent, calendarTodo), http.StatusBadRequest)
+		return
+	}
+
+	tasks, err := selectCalendarTasks(u)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Selecting tasks. Err: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	var b bytes.Buffer
+	writeCalendar(&b, kind, tasks)
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="taskeram.ics"`)
+	w.Header().Set("Cache-Control", "no-cache")
+
+	_, err = b.WriteTo(w)
+	if err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/slevchyk/taskeram/models"
+)
+
+func TestSaveImportedTasksIsAtomic(t *testing.T) {
+
+	setupTestDB(t)
+
+	_, err := cfg.DB.Exec(`
+		CREATE TRIGGER fail_import BEFORE INSERT ON tasks WHEN new.title='second'
+		BEGIN
+			SELECT RAISE(ABORT, 'import failed');
+		END;`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tasks []models.DbTasks
+	for _, title := range []string{"first", "second", "third"} {
+		tasks = append(tasks, models.DbTasks{FromUser: testAdminID, ToUser: testAssigneeID, Status: models.TaskStatusNew, ChangedAt: models.NullTime{Time: time.Now().UTC(), Valid: true}, ChangedBy: testAdminID, Title: title})
+	}
+
+	ids, err := saveImportedTasks(tasks)
+	if err == nil {
+		t.Fatal("error of the second Task isn't returned")
+	}
+
+	if len(ids) != 0 || countRows(t, "tasks") != 1 || countRows(t, "task_history") != 1 {
+		t.Errorf("failed import left Tasks %v, %v Tasks and %v history records in DB", ids, countRows(t, "tasks"), countRows(t, "task_history"))
+	}
+
+	ids, err = saveImportedTasks(append(tasks[:1], tasks[2]))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ids) != 2 || countRows(t, "tasks") != 3 {
+		t.Errorf("imported Tasks %v, %v Tasks in DB", ids, countRows(t, "tasks"))
+	}
+}
+
+func TestCloseTask(t *testing.T) {
+
+	task := setupTestDB(t)
+	sent := recordNotifications(t)
+	manager := addTestUser(t, 4, "Manager", models.RoleManager)
+
+	task, err := closeTask(task, manager, "done by the script")
+	if err != nil {
+		t.Fatal(err)
+	}
+	notifyQueue.Wait()
+
+	if status := taskStatus(t, task.ID); status != models.TaskStatusClosed {
+		t.Errorf("status of Task #%v is %v, want %v", task.ID, status, models.TaskStatusClosed)
+	}
+
+	for _, tgid := range []int{testAdminID, testAssigneeID} {
+		if len(sent.sent[tgid]) != 1 {
+			t.Errorf("participant %v got %v messages, want 1", tgid, len(sent.sent[tgid]))
+		}
+	}
+
+	if n := countRows(t, "task_comments"); n != 1 {
+		t.Errorf("%v comments are saved, want 1", n)
+	}
+
+	_, err = closeTask(task, manager, "once more")
+	if err != errStatusForbidden {
+		t.Errorf("closing the closed Task: got %v, want %v", err, errStatusForbidden)
+	}
+
+	if n := countRows(t, "task_comments"); n != 1 {
+		t.Errorf("comment of the forbidden close is saved")
+	}
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/slevchyk/taskeram/dbase"
+	"github.com/slevchyk/taskeram/models"
+)
+
+const (
+	defaultConfigFile = "taskeram.cfg"
+	//envPrefix starts names of env variables, e.g. TASKERAM_TELEGRAM_TOKEN or TASKERAM_DATABASE_HOST
+	envPrefix = "TASKERAM_"
+)
+
+//defaultConfig returns config values used when they aren't set anywhere else
+func defaultConfig() models.Config {
+
+	var c models.Config
+
+	c.Database.Type = dbase.Sqlite
+	c.Database.Name = "taskeram"
+	c.Database.Host = "localhost"
+	c.Database.Port = 5432
+	c.Database.SSLMode = "disable"
+	c.SMTP.Port = 25
+	c.Web.Listen = defaultListen
+
+	return c
+}
+
+//loadConfig builds config in layers: defaults, then config file, then env variables, then command line flags.
+//Every config field may be set by env variable TASKERAM_<SECTION>_<FIELD> and flag -<section>.<field>,
+//the config file is set by -config flag or TASKERAM_CONFIG env variable
+func loadConfig(args []string) (models.Config, error) {
+
+	fs := flag.NewFlagSet("taskeram", flag.ContinueOnError)
+	file := configFlags(fs)
+
+	err := fs.Parse(args)
+	if err != nil {
+		return defaultConfig(), err
+	}
+
+	return configFromFlags(fs, *file)
+}
+
+//configFlags adds -config and -<section>.<field> flags to fs, so subcommands can mix them with their own flags
+func configFlags(fs *flag.FlagSet) *string {
+
+	var c models.Config
+
+	eachConfigField(&c, func(section string, name string, field reflect.Value) {
+		fs.String(section+"."+name, "", fmt.Sprintf("%v.%v config value", section, name))
+	})
+
+	return fs.String("config", "", "path to the config file (default "+defaultConfigFile+")")
+}
+
+//configFromFlags loads config after fs with configFlags has been parsed
+func configFromFlags(fs *flag.FlagSet, file string) (models.Config, error) {
+
+	var err error
+
+	c := defaultConfig()
+
+	path := file
+	if path == "" {
+		path = os.Getenv(envPrefix + "CONFIG")
+	}
+
+	if path != "" || fileExists(defaultConfigFile) {
+		if path == "" {
+			path = defaultConfigFile
+		}
+
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return c, err
+		}
+
+		err = json.Unmarshal(b, &c)
+		if err != nil {
+			return c, fmt.Errorf("%v: %v", path, err)
+		}
+	}
+
+	//TELEGRAM_TASKERAM_ADMIN was used before the config had admin_id
+	if val := os.Getenv("TELEGRAM_TASKERAM_ADMIN"); val != "" && c.Telegram.AdminID == "" {
+		c.Telegram.AdminID = val
+	}
+
+	eachConfigField(&c, func(section string, name string, field reflect.Value) {
+		env := envPrefix + strings.ToUpper(section+"_"+name)
+		if val, ok := os.LookupEnv(env); ok && err == nil {
+			err = setConfigField(field, val, env)
+		}
+	})
+	if err != nil {
+		return c, err
+	}
+
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name != "config" && err == nil {
+			err = setConfigFieldByName(&c, f.Name, f.Value.String())
+		}
+	})
+
+	return c, err
+}
+
+//eachConfigField calls f for every field of config sections, names are json names
+func eachConfigField(c *models.Config, f func(section string, name string, field reflect.Value)) {
+
+	v := reflect.ValueOf(c).Elem()
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		section := jsonName(t.Field(i))
+		if section == "" || t.Field(i).Type.Kind() != reflect.Struct {
+			continue
+		}
+
+		sv := v.Field(i)
+		st := sv.Type()
+		for j := 0; j < st.NumField(); j++ {
+			name := jsonName(st.Field(j))
+			if name == "" {
+				continue
+			}
+			f(section, name, sv.Field(j))
+		}
+	}
+}
+
+func jsonName(f reflect.StructField) string {
+
+	name := strings.Split(f.Tag.Get("json"), ",")[0]
+	if name == "-" {
+		return ""
+	}
+
+	return name
+}
+
+func setConfigFieldByName(c *models.Config, fullName string, val string) error {
+
+	var err error
+
+	eachConfigField(c, func(section string, name string, field reflect.Value) {
+		if section+"."+name == fullName {
+			err = setConfigField(field, val, "-"+fullName)
+		}
+	})
+
+	return err
+}
+
+//setConfigField sets config field from text value, source is the name of env variable or flag for error message
+func setConfigField(field reflect.Value, val string, source string) error {
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(val)
+	case reflect.Int:
+		n, err := strconv.Atoi(val)
+		if err != nil {
+			return fmt.Errorf("%v: %v is not a number", source, val)
+		}
+		field.SetInt(int64(n))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(val)
+		if err != nil {
+			return fmt.Errorf("%v: %v is not true or false", source, val)
+		}
+		field.SetBool(b)
+	}
+
+	return nil
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+//validateConfig returns problems which don't let Taskeram work and warnings about settings which look wrong
+func validateConfig(c models.Config) (problems []string, warnings []string) {
+
+	if c.Telegram.Token == "" {
+		problems = append(problems, "telegram.token is not set")
+	}
+
+	if c.Telegram.AdminID == "" {
+		problems = append(problems, "telegram.admin_id is not set")
+	} else if _, err := strconv.Atoi(c.Telegram.AdminID); err != nil {
+		problems = append(problems, fmt.Sprintf("telegram.admin_id %v is not a Telegram ID", c.Telegram.AdminID))
+	}
+
+	switch c.Database.Type {
+	case dbase.Sqlite:
+		if c.Database.Name == "" {
+			problems = append(problems, "database.name is not set")
+		}
+	case dbase.Postgres:
+		if c.Database.Name == "" || c.Database.User == "" {
+			problems = append(problems, "database.name and database.user should be set for postgres")
+		}
+		if c.Database.Port <= 0 || c.Database.Port > 65535 {
+			problems = append(problems, fmt.Sprintf("database.port %v is wrong", c.Database.Port))
+		}
+		switch c.Database.SSLMode {
+		case "disable", "require", "verify-ca", "verify-full":
+		default:
+			problems = append(problems, fmt.Sprintf("database.sslmode %v should be disable, require, verify-ca or verify-full", c.Database.SSLMode))
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("database.type %v should be %v or %v", c.Database.Type, dbase.Sqlite, dbase.Postgres))
+	}
+
+	if c.SMTP.Host != "" && c.SMTP.From == "" {
+		problems = append(problems, "smtp.from should be set to send e-mails")
+	}
+
+	if c.Web.URL != "" {
+		u, err := url.Parse(c.Web.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			problems = append(problems, fmt.Sprintf("web.url %v should be an absolute http(s) URL", c.Web.URL))
+		}
+	} else {
+		warnings = append(warnings, "web.url is not set, bot messages won't have links to the web app")
+	}
+
+	switch c.Web.Login {
+	case "", models.LoginWidget, models.LoginBot:
+	default:
+		problems = append(problems, fmt.Sprintf("web.login %v should be %v, %v or empty", c.Web.Login, models.LoginWidget, models.LoginBot))
+	}
+
+	if (c.Web.CertFile == "") != (c.Web.KeyFile == "") {
+		problems = append(problems, "web.cert_file and web.key_file should be set both")
+	}
+
+	for _, val := range []string{c.Web.CertFile, c.Web.KeyFile} {
+		if val != "" && !fileExists(val) {
+			problems = append(problems, fmt.Sprintf("%v does not exist", val))
+		}
+	}
+
+	if c.Web.SelfSigned && c.Web.CertFile != "" {
+		warnings = append(warnings, "web.self_signed is ignored because web.cert_file is set")
+	} else if c.Web.SelfSigned {
+		warnings = append(warnings, "web.self_signed certificate is for development only")
+	}
+
+	if c.Web.ReadTimeout < 0 || c.Web.WriteTimeout < 0 {
+		problems = append(problems, "web.read_timeout and web.write_timeout can't be negative")
+	}
+
+	return problems, warnings
+}
+
+//printConfigReport prints validation report and returns false if config has problems
+func printConfigReport(problems []string, warnings []string) bool {
+
+	for _, val := range problems {
+		fmt.Fprintln(os.Stderr, "config error:", val)
+	}
+
+	for _, val := range warnings {
+		fmt.Fprintln(os.Stderr, "config warning:", val)
+	}
+
+	return len(problems) == 0
+}
+
+//runConfigCheck is taskeram config check command. It validates config without starting the bot
+func runConfigCheck(args []string) int {
+
+	c, err := loadConfig(args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "config error:", err)
+		return 1
+	}
+
+	if !printConfigReport(validateConfig(c)) {
+		return 1
+	}
+
+	fmt.Println("config is OK")
+	return 0
+}
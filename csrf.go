@@ -0,0 +1,82 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"net/url"
+)
+
+//csrfToken returns anti-forgery token of the session from the request cookie.
+//It is derived from the session token, so it is the same for all forms of the session and ends with it
+func csrfToken(r *http.Request) string {
+
+	c, err := r.Cookie("session")
+	if err != nil || c.Value == "" {
+		return ""
+	}
+
+	return hashToken("csrf:" + c.Value)
+}
+
+//csrfProtect checks anti-forgery token of the requests which change data: everything except GET
+//and GET with do parameter. The token is sent as csrf form value or X-CSRF-Token header
+func csrfProtect(next http.HandlerFunc) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			if r.URL.Query().Get("do") == "" {
+				next(w, r)
+				return
+			}
+		}
+
+		token := r.Header.Get("X-CSRF-Token")
+		if token == "" {
+			token = r.FormValue("csrf")
+		}
+
+		expected := csrfToken(r)
+		if expected == "" || subtle.ConstantTimeCompare([]byte(token), []byte(expected)) != 1 {
+			http.Error(w, "Invalid or missing CSRF token", http.StatusForbidden)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+//sameOrigin reports whether the request is sent by a page of the web app according to Origin or Referer header
+func sameOrigin(r *http.Request) bool {
+
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		origin = r.Header.Get("Referer")
+	}
+
+	u, err := url.Parse(origin)
+	if origin == "" || err != nil {
+		return false
+	}
+
+	if u.Host == r.Host {
+		return true
+	}
+
+	public, err := url.Parse(cfg.Web.URL)
+	return cfg.Web.URL != "" && err == nil && public.Host == u.Host
+}
+
+//originProtect rejects JSON API requests authenticated by the session cookie which come from other sites
+func originProtect(next http.HandlerFunc) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		if !sameOrigin(r) {
+			http.Error(w, "Cross-origin request is not allowed", http.StatusForbidden)
+			return
+		}
+
+		next(w, r)
+	}
+}
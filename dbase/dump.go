@@ -0,0 +1,373 @@
+package dbase
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/slevchyk/taskeram/dbase/sqlite"
+	"github.com/slevchyk/taskeram/models"
+)
+
+//DumpTables lists all tables in the order they can be restored, rows refer to the tables above only
+var DumpTables = []string{
+	"users",
+	"user_history",
+	"tasks",
+	"task_history",
+	"task_comments",
+	"task_messages",
+	"user_settings",
+	"notification_channels",
+	"notification_log",
+	"task_mutes",
+	"webhooks",
+	"webhook_deliveries",
+	"api_tokens",
+	"calendar_tokens",
+	"teams",
+	"team_members",
+	"team_mutes",
+	"invites",
+	"sessions",
+	"auth",
+}
+
+//Dump reads all tables in one transaction, so the copy is consistent while the bot keeps working
+func Dump(cfg models.Config) ([]models.DbDumpTable, error) {
+
+	return dumpTables(cfg, DumpTables)
+}
+
+func dumpTables(cfg models.Config, tables []string) ([]models.DbDumpTable, error) {
+
+	var xs []models.DbDumpTable
+
+	opts := &sql.TxOptions{}
+	if cfg.Database.Type == Postgres {
+		opts = &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true}
+	}
+
+	tx, err := cfg.DB.BeginTx(context.Background(), opts)
+	if err != nil {
+		return xs, err
+	}
+	defer tx.Rollback()
+
+	for _, name := range tables {
+		t, err := dumpTable(tx, name)
+		if err != nil {
+			return xs, fmt.Errorf("%v: %v", name, err)
+		}
+		xs = append(xs, t)
+	}
+
+	return xs, tx.Commit()
+}
+
+func dumpTable(tx *sql.Tx, name string) (models.DbDumpTable, error) {
+
+	t := models.DbDumpTable{Name: name}
+
+	rows, err := tx.Query(fmt.Sprintf("SELECT * FROM %v ORDER BY id", name))
+	if err != nil {
+		return t, err
+	}
+	defer rows.Close()
+
+	t.Columns, err = rows.Columns()
+	if err != nil {
+		return t, err
+	}
+
+	timeColumns := make(map[int]bool)
+
+	for rows.Next() {
+		vals := make([]interface{}, len(t.Columns))
+		ptrs := make([]interface{}, len(t.Columns))
+		for i := range vals {
+			ptrs[i] = &vals[i]
+		}
+
+		err := rows.Scan(ptrs...)
+		if err != nil {
+			return t, err
+		}
+
+		for i, val := range vals {
+			switch v := val.(type) {
+			//postgres returns text as bytes
+			case []byte:
+				vals[i] = string(v)
+			case time.Time:
+				vals[i] = v.UTC()
+				timeColumns[i] = true
+			}
+		}
+
+		t.Rows = append(t.Rows, vals)
+	}
+
+	for i, col := range t.Columns {
+		if timeColumns[i] {
+			t.TimeColumns = append(t.TimeColumns, col)
+		}
+	}
+
+	return t, rows.Err()
+}
+
+//Restore loads tables into DB created by InitDB in one transaction. DB should have no rows except the admin added by InitDB,
+//it and rows added by triggers are replaced.
+//It returns number of rows in every restored table
+func Restore(cfg models.Config, tables []models.DbDumpTable) (map[string]int, error) {
+
+	var n int
+	counts := make(map[string]int)
+
+	for _, t := range tables {
+		if !isDumpTable(t.Name) {
+			return counts, fmt.Errorf("unknown table %v", t.Name)
+		}
+	}
+
+	tx, err := cfg.DB.Begin()
+	if err != nil {
+		return counts, err
+	}
+	defer tx.Rollback()
+
+	err = checkEmpty(cfg, tx)
+	if err != nil {
+		return counts, err
+	}
+
+	for i := len(DumpTables) - 1; i >= 0; i-- {
+		_, err := tx.Exec("DELETE FROM " + DumpTables[i])
+		if err != nil {
+			return counts, fmt.Errorf("%v: %v", DumpTables[i], err)
+		}
+	}
+
+	for _, name := range DumpTables {
+		for _, t := range tables {
+			if t.Name != name {
+				continue
+			}
+
+			//triggers fill history tables while users and tasks are inserted
+			_, err := tx.Exec("DELETE FROM " + name)
+			if err != nil {
+				return counts, fmt.Errorf("%v: %v", name, err)
+			}
+
+			err = restoreTable(cfg, tx, t)
+			if err != nil {
+				return counts, fmt.Errorf("%v: %v", name, err)
+			}
+		}
+	}
+
+	if cfg.Database.Type == Postgres {
+		for _, name := range DumpTables {
+			_, err := tx.Exec(fmt.Sprintf("SELECT setval(pg_get_serial_sequence('%v', 'id'), COALESCE(MAX(id), 0) + 1, false) FROM %v", name, name))
+			if err != nil {
+				return counts, fmt.Errorf("%v: %v", name, err)
+			}
+		}
+	}
+
+	for _, name := range DumpTables {
+		err := tx.QueryRow("SELECT COUNT(*) FROM " + name).Scan(&n)
+		if err != nil {
+			return counts, fmt.Errorf("%v: %v", name, err)
+		}
+		counts[name] = n
+	}
+
+	for _, t := range tables {
+		if counts[t.Name] != len(t.Rows) {
+			return counts, fmt.Errorf("%v: %v rows are restored of %v", t.Name, counts[t.Name], len(t.Rows))
+		}
+	}
+
+	return counts, tx.Commit()
+}
+
+//checkEmpty fails if any table has rows except the admin added by InitDB
+func checkEmpty(cfg models.Config, tx *sql.Tx) error {
+
+	adminID, err := strconv.Atoi(cfg.Telegram.AdminID)
+	if err != nil {
+		return fmt.Errorf("telegram.admin_id: %v", err)
+	}
+
+	for _, name := range DumpTables {
+		var n int
+
+		if name == "users" {
+			query := "SELECT COUNT(*) FROM users WHERE tgid <> ?"
+			if cfg.Database.Type == Postgres {
+				query = "SELECT COUNT(*) FROM users WHERE tgid <> $1"
+			}
+			err = tx.QueryRow(query, adminID).Scan(&n)
+		} else {
+			err = tx.QueryRow("SELECT COUNT(*) FROM " + name).Scan(&n)
+		}
+		if err != nil {
+			return fmt.Errorf("%v: %v", name, err)
+		}
+
+		if n != 0 {
+			return fmt.Errorf("DB already has rows in %v, restore needs an empty DB", name)
+		}
+	}
+
+	return nil
+}
+
+func restoreTable(cfg models.Config, tx *sql.Tx, t models.DbDumpTable) error {
+
+	var params []string
+	for i := range t.Columns {
+		if cfg.Database.Type == Postgres {
+			params = append(params, fmt.Sprintf("$%v", i+1))
+		} else {
+			params = append(params, "?")
+		}
+	}
+
+	stmt, err := tx.Prepare(fmt.Sprintf("INSERT INTO %v(%v) VALUES (%v)", t.Name, strings.Join(t.Columns, ", "), strings.Join(params, ", ")))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, vals := range t.Rows {
+		if len(vals) != len(t.Columns) {
+			return fmt.Errorf("row has %v values for %v columns", len(vals), len(t.Columns))
+		}
+
+		_, err := stmt.Exec(vals...)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func isDumpTable(name string) bool {
+
+	for _, val := range DumpTables {
+		if val == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+//CountRows returns number of rows in the table
+func CountRows(cfg models.Config, table string) (int, error) {
+
+	var n int
+
+	if !isDumpTable(table) {
+		return n, fmt.Errorf("unknown table %v", table)
+	}
+
+	err := cfg.DB.QueryRow("SELECT COUNT(*) FROM " + table).Scan(&n)
+	return n, err
+}
+
+//DecodeDump reads JSON dump. Numbers become int64 or float64 and values of time columns become time.Time
+func DecodeDump(r io.Reader) (models.DbDump, error) {
+
+	var d models.DbDump
+
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+
+	err := dec.Decode(&d)
+	if err != nil {
+		return d, err
+	}
+
+	for _, t := range d.Tables {
+		timeColumns := make(map[int]bool)
+		for i, col := range t.Columns {
+			for _, val := range t.TimeColumns {
+				if val == col {
+					timeColumns[i] = true
+				}
+			}
+		}
+
+		for _, vals := range t.Rows {
+			for i, val := range vals {
+				switch v := val.(type) {
+				case json.Number:
+					if n, err := v.Int64(); err == nil {
+						vals[i] = n
+					} else if f, err := v.Float64(); err == nil {
+						vals[i] = f
+					}
+				case string:
+					if !timeColumns[i] {
+						break
+					}
+					if tm, err := time.Parse(time.RFC3339Nano, v); err == nil {
+						vals[i] = tm
+					}
+				}
+			}
+		}
+	}
+
+	return d, nil
+}
+
+//ReadSnapshot reads tables and userpics from sqlite snapshot made by Backup
+func ReadSnapshot(path string) (models.DbDump, error) {
+
+	var d models.DbDump
+	var err error
+
+	c := models.Config{}
+	c.Database.Type = Sqlite
+
+	c.DB, err = sqlite.Open(path)
+	if err != nil {
+		return d, err
+	}
+	defer c.DB.Close()
+
+	d.Database = Sqlite
+
+	//tables added after the snapshot was made stay empty on restore
+	var tables []string
+	for _, name := range DumpTables {
+		ok, err := sqlite.HasTable(c.DB, name)
+		if err != nil {
+			return d, err
+		}
+		if ok {
+			tables = append(tables, name)
+		}
+	}
+
+	d.Tables, err = dumpTables(c, tables)
+	if err != nil {
+		return d, err
+	}
+
+	d.Userpics, err = sqlite.ReadUserpics(c.DB)
+
+	return d, err
+}
@@ -0,0 +1,296 @@
+package dbase
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/slevchyk/taskeram/dbase/sqlite"
+	"github.com/slevchyk/taskeram/models"
+)
+
+//openTestDB creates tables in a new temp sqlite DB
+func openTestDB(t *testing.T, name string) models.Config {
+
+	c, err := Open("sqlite:" + filepath.Join(t.TempDir(), name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.DB.Close() })
+
+	c.Telegram.AdminID = "1"
+	InitDB(c)
+
+	return c
+}
+
+//seedTestDB adds a user, Tasks with history, a comment and a team to the DB
+func seedTestDB(t *testing.T, c models.Config) {
+
+	changedAt := time.Date(2020, 3, 2, 10, 30, 15, 0, time.UTC)
+
+	stmt, err := InsertUser(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = ExecInsertUser(stmt, models.DbUsers{TelegramID: 2, FirstName: "Ірина", Status: models.UserApprowed, ChangedAt: models.NullTime{Time: changedAt, Valid: true}, ChangedBy: 1, Role: models.RoleMember})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stmt, err = InsertTask(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, title := range []string{"Звіт за березень", "Fix the printer"} {
+		_, err := ExecInsertTask(stmt, models.DbTasks{
+			FromUser:    1,
+			ToUser:      2,
+			Status:      models.TaskStatusNew,
+			ChangedAt:   models.NullTime{Time: changedAt.Add(time.Duration(i) * time.Hour), Valid: true},
+			ChangedBy:   1,
+			Title:       title,
+			Description: "line 1\nline 2",
+			DueDate:     models.NullTime{Time: time.Date(2020, 3, 10, 0, 0, 0, 0, time.UTC), Valid: i == 0},
+			Priority:    models.PriorityHigh,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stmt, err = UpdateTaskStatus(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = ExecUpdateTaskStatus(stmt, models.DbTasks{ID: 1, Status: models.TaskStatusStarted, ChangedAt: models.NullTime{Time: changedAt.Add(24 * time.Hour), Valid: true}, ChangedBy: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stmt, err = InsertTaskComment(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = ExecInsertTaskComment(stmt, models.DbTaskComments{TaskID: 1, UserID: 2, Date: models.NullTime{Time: changedAt.Add(25 * time.Hour), Valid: true}, Comment: "on it"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stmt, err = InsertTeam(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = ExecInsertTeam(stmt, models.DbTeams{Name: "Sales", CreatedBy: 1, CreatedAt: models.NullTime{Time: changedAt, Valid: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func selectTestTask(t *testing.T, c models.Config, id int) models.DbTasks {
+
+	var task models.DbTasks
+
+	rows, err := SelectTasksByID(c, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		t.Fatalf("Task #%v not found", id)
+	}
+
+	err = ScanTask(rows, &task)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return task
+}
+
+func TestCopySqliteToSqlite(t *testing.T) {
+
+	src := openTestDB(t, "src")
+	seedTestDB(t, src)
+
+	tables, err := Dump(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//копія проходить через JSON так само, як backup і restore
+	var b bytes.Buffer
+	err = json.NewEncoder(&b).Encode(models.DbDump{Version: 1, Database: Sqlite, Tables: tables})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := DecodeDump(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, table := range d.Tables {
+		if table.Name != "tasks" {
+			continue
+		}
+
+		for i, col := range table.Columns {
+			if col != "changed_at" {
+				continue
+			}
+			if _, ok := table.Rows[0][i].(time.Time); !ok {
+				t.Errorf("tasks.changed_at is decoded as %T, want time.Time", table.Rows[0][i])
+			}
+		}
+	}
+
+	dst := openTestDB(t, "dst")
+
+	counts, err := Restore(dst, d.Tables)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range DumpTables {
+		n, err := CountRows(src, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if counts[name] != n {
+			t.Errorf("%v: %v rows are restored, source has %v", name, counts[name], n)
+		}
+	}
+
+	if counts["tasks"] != 2 || counts["task_history"] != 3 || counts["task_comments"] != 1 || counts["users"] != 2 {
+		t.Errorf("seeded rows aren't copied: %v", counts)
+	}
+
+	for _, id := range []int{1, 2} {
+		want := selectTestTask(t, src, id)
+		got := selectTestTask(t, dst, id)
+
+		if !got.ChangedAt.Time.Equal(want.ChangedAt.Time) || got.DueDate.Valid != want.DueDate.Valid || !got.DueDate.Time.Equal(want.DueDate.Time) {
+			t.Errorf("Task #%v times: got %v due %v, want %v due %v", id, got.ChangedAt, got.DueDate, want.ChangedAt, want.DueDate)
+		}
+
+		got.ChangedAt, want.ChangedAt = models.NullTime{}, models.NullTime{}
+		got.DueDate, want.DueDate = models.NullTime{}, models.NullTime{}
+		got.CommentedAt, want.CommentedAt = models.NullTime{}, models.NullTime{}
+		if got != want {
+			t.Errorf("Task #%v: got %+v, want %+v", id, got, want)
+		}
+	}
+
+	want := GetUserByTelegramID(src, 2)
+	got := GetUserByTelegramID(dst, 2)
+	if got.FirstName != want.FirstName || got.Role != want.Role || !got.ChangedAt.Time.Equal(want.ChangedAt.Time) {
+		t.Errorf("user: got %+v, want %+v", got, want)
+	}
+
+	//після відновлення нові записи не конфліктують з відновленими id
+	stmt, err := InsertTask(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := ExecInsertTask(stmt, models.DbTasks{FromUser: 1, ToUser: 2, Status: models.TaskStatusNew, Title: "new"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if id, _ := res.LastInsertId(); id != 3 {
+		t.Errorf("new Task got ID %v, want 3", id)
+	}
+}
+
+func TestReadOldSnapshot(t *testing.T) {
+
+	src := openTestDB(t, "src")
+	seedTestDB(t, src)
+
+	path := filepath.Join(t.TempDir(), "snapshot")
+	err := Backup(src, path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//знімок, зроблений до появи нових таблиць
+	db, err := sqlite.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"calendar_tokens", "team_mutes"} {
+		_, err := db.Exec("DROP TABLE " + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.Close()
+
+	d, err := ReadSnapshot(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dst := openTestDB(t, "dst")
+
+	counts, err := Restore(dst, d.Tables)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if counts["tasks"] != 2 || counts["calendar_tokens"] != 0 || counts["team_mutes"] != 0 {
+		t.Errorf("old snapshot is restored with %v", counts)
+	}
+}
+
+func TestRestoreIntoNotEmptyDB(t *testing.T) {
+
+	src := openTestDB(t, "src")
+	seedTestDB(t, src)
+
+	tables, err := Dump(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//у цільовій базі немає задач, але є інші записи
+	for _, query := range []string{
+		"INSERT INTO users(tgid, first_name, status) VALUES (7, 'Olena', 'approved')",
+		"INSERT INTO api_tokens(tgid, token) VALUES (1, 'hash')",
+	} {
+		dst := openTestDB(t, "dst")
+
+		_, err := dst.DB.Exec(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = Restore(dst, tables)
+		if err == nil {
+			t.Errorf("restore after %q: no error", query)
+		}
+
+		for _, name := range []string{"tasks", "task_history", "teams"} {
+			n, err := CountRows(dst, name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n != 0 {
+				t.Errorf("restore after %q: %v rows are left in %v", query, n, name)
+			}
+		}
+	}
+}
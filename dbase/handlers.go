@@ -6,6 +6,7 @@ import (
 	"github.com/slevchyk/taskeram/dbase/sqlite"
 	"github.com/slevchyk/taskeram/models"
 	"log"
+	"time"
 )
 
 func GetUserByTelegramID(cfg models.Config, tgid int) models.DbUsers {
@@ -37,6 +38,28 @@ func GetUserByTelegramID(cfg models.Config, tgid int) models.DbUsers {
 	return u
 }
 
+//FindUserByTelegramID is GetUserByTelegramID which returns DB errors instead of stopping the program.
+//ID of the user is 0 if there is no such user
+func FindUserByTelegramID(cfg models.Config, tgid int) (models.DbUsers, error) {
+
+	var u models.DbUsers
+
+	rows, err := SelectUsersByTelegramID(cfg, tgid)
+	if err != nil {
+		return u, err
+	}
+	defer rows.Close()
+
+	if rows.Next() {
+		err = ScanUser(rows, &u)
+		if err != nil {
+			return models.DbUsers{}, err
+		}
+	}
+
+	return u, rows.Err()
+}
+
 func SelectUsersByTelegramID(cfg models.Config, tgid int) (*sql.Rows, error) {
 
 	switch cfg.Database.Type {
@@ -217,6 +240,53 @@ func SelectUsersBySessionUUID(cfg models.Config, uuid string) (*sql.Rows, error)
 	}
 }
 
+func SelectTaskMessage(cfg models.Config, chatID int64, msgID int) (*sql.Rows, error) {
+
+	switch cfg.Database.Type {
+	case Sqlite:
+		return  sqlite.SelectTaskMessage(cfg.DB, chatID, msgID)
+	case Postgres:
+		return  postgres.SelectTaskMessage(cfg.DB, chatID, msgID)
+	default:
+		return  sqlite.SelectTaskMessage(cfg.DB, chatID, msgID)
+	}
+}
+
+func SelectCommentsSince(cfg models.Config, tgid int, since time.Time) (*sql.Rows, error) {
+
+	switch cfg.Database.Type {
+	case Sqlite:
+		return  sqlite.SelectCommentsSince(cfg.DB, tgid, since)
+	case Postgres:
+		return  postgres.SelectCommentsSince(cfg.DB, tgid, since)
+	default:
+		return  sqlite.SelectCommentsSince(cfg.DB, tgid, since)
+	}
+}
+
+func SelectUserSettingsByTelegramID(cfg models.Config, tgid int) (*sql.Rows, error) {
+
+	switch cfg.Database.Type {
+	case Sqlite:
+		return  sqlite.SelectUserSettingsByTelegramID(cfg.DB, tgid)
+	case Postgres:
+		return  postgres.SelectUserSettingsByTelegramID(cfg.DB, tgid)
+	default:
+		return  sqlite.SelectUserSettingsByTelegramID(cfg.DB, tgid)
+	}
+}
+
+func SelectUserSettingsWithDigest(cfg models.Config) (*sql.Rows, error) {
+
+	switch cfg.Database.Type {
+	case Sqlite:
+		return  sqlite.SelectUserSettingsWithDigest(cfg.DB)
+	case Postgres:
+		return  postgres.SelectUserSettingsWithDigest(cfg.DB)
+	default:
+		return  sqlite.SelectUserSettingsWithDigest(cfg.DB)
+	}
+}
 
 //UpdateUserStatus - for changing user status. Uses 4 params
 //1. New user status
@@ -252,6 +322,39 @@ func UpdateUserData(cfg models.Config) (*sql.Stmt, error) {
 	}
 }
 
+//UpdateUserRole - for changing user role. Uses 5 params
+//1. Role
+//2. Admin flag, kept in sync with the role
+//3. When role was changed
+//4. Who changed user role
+//5. User Telegram ID
+func UpdateUserRole(cfg models.Config) (*sql.Stmt, error) {
+
+	switch cfg.Database.Type {
+	case Sqlite:
+		return  sqlite.UpdateUserRole(cfg.DB)
+	case Postgres:
+		return  postgres.UpdateUserRole(cfg.DB)
+	default:
+		return  sqlite.UpdateUserRole(cfg.DB)
+	}
+}
+
+//UpdateUserUsername is for keeping Telegram username up to date. Uses 2 params
+//1. Telegram username
+//2. User Telegram ID
+func UpdateUserUsername(cfg models.Config) (*sql.Stmt, error) {
+
+	switch cfg.Database.Type {
+	case Sqlite:
+		return  sqlite.UpdateUserUsername(cfg.DB)
+	case Postgres:
+		return  postgres.UpdateUserUsername(cfg.DB)
+	default:
+		return  sqlite.UpdateUserUsername(cfg.DB)
+	}
+}
+
 //UpdateTaskStatus is for changing task status. Uses 4 params
 //1. New status
 //2. When status was add
@@ -316,74 +419,877 @@ func UpdateSessionLastActivityByUuid(cfg models.Config) (*sql.Stmt, error) {
 	}
 }
 
-func InsertUser(cfg models.Config) (*sql.Stmt, error) {
+//UpdateUserSettings is for changing digest schedule and notification preferences. Uses 13 params
+//1. Timezone
+//2. Digest ("", daily, weekly)
+//3. Digest time (HH:MM)
+//4. Digest weekday (0 - Sunday)
+//5. Notify about new Tasks (0/1)
+//6. Notify about status changes (0/1)
+//7. Notify about comments (0/1)
+//8. Notify about mentions (0/1)
+//9. Quiet hours from (HH:MM)
+//10. Quiet hours to (HH:MM)
+//11. E-mail
+//12. Webhook URL
+//13. User Telegram ID
+func UpdateUserSettings(cfg models.Config) (*sql.Stmt, error) {
 
 	switch cfg.Database.Type {
 	case Sqlite:
-		return  sqlite.InsertUser(cfg.DB)
+		return  sqlite.UpdateUserSettings(cfg.DB)
 	case Postgres:
-		return  postgres.InsertUser(cfg.DB)
+		return  postgres.UpdateUserSettings(cfg.DB)
 	default:
-		return  sqlite.InsertUser(cfg.DB)
+		return  sqlite.UpdateUserSettings(cfg.DB)
 	}
 }
 
-func InsertTask(cfg models.Config) (*sql.Stmt, error) {
+//UpdateUserSettingsDigestSentAt is for marking digest as sent. Uses 2 params
+//1. When digest was sent
+//2. User Telegram ID
+func UpdateUserSettingsDigestSentAt(cfg models.Config) (*sql.Stmt, error) {
 
 	switch cfg.Database.Type {
 	case Sqlite:
-		return  sqlite.InsertTask(cfg.DB)
+		return  sqlite.UpdateUserSettingsDigestSentAt(cfg.DB)
 	case Postgres:
-		return  postgres.InsertTask(cfg.DB)
+		return  postgres.UpdateUserSettingsDigestSentAt(cfg.DB)
 	default:
-		return  sqlite.InsertTask(cfg.DB)
+		return  sqlite.UpdateUserSettingsDigestSentAt(cfg.DB)
 	}
 }
 
-func InsertAuth(cfg models.Config) (*sql.Stmt, error) {
+func SelectTaskMutesByTelegramID(cfg models.Config, tgid int) (*sql.Rows, error) {
 
 	switch cfg.Database.Type {
 	case Sqlite:
-		return  sqlite.InsertAuth(cfg.DB)
+		return  sqlite.SelectTaskMutesByTelegramID(cfg.DB, tgid)
 	case Postgres:
-		return  postgres.InsertAuth(cfg.DB)
+		return  postgres.SelectTaskMutesByTelegramID(cfg.DB, tgid)
 	default:
-		return  sqlite.InsertAuth(cfg.DB)
+		return  sqlite.SelectTaskMutesByTelegramID(cfg.DB, tgid)
 	}
 }
 
-func InsertSession(cfg models.Config) (*sql.Stmt, error) {
+func SelectTaskMute(cfg models.Config, tgid int, taskID int) (*sql.Rows, error) {
 
 	switch cfg.Database.Type {
 	case Sqlite:
-		return  sqlite.InsertSession(cfg.DB)
+		return  sqlite.SelectTaskMute(cfg.DB, tgid, taskID)
 	case Postgres:
-		return  postgres.InsertSession(cfg.DB)
+		return  postgres.SelectTaskMute(cfg.DB, tgid, taskID)
 	default:
-		return  sqlite.InsertSession(cfg.DB)
+		return  sqlite.SelectTaskMute(cfg.DB, tgid, taskID)
 	}
 }
 
-func DeleteAuthByToken(cfg models.Config) (*sql.Stmt, error) {
+func SelectTeamMutesByTelegramID(cfg models.Config, tgid int) (*sql.Rows, error) {
 
 	switch cfg.Database.Type {
 	case Sqlite:
-		return  sqlite.DeleteAuthByToken(cfg.DB)
+		return  sqlite.SelectTeamMutesByTelegramID(cfg.DB, tgid)
 	case Postgres:
-		return  postgres.DeleteAuthByToken(cfg.DB)
+		return  postgres.SelectTeamMutesByTelegramID(cfg.DB, tgid)
 	default:
-		return  sqlite.DeleteAuthByToken(cfg.DB)
+		return  sqlite.SelectTeamMutesByTelegramID(cfg.DB, tgid)
 	}
 }
 
-func DeleteSessionByUUID(cfg models.Config) (*sql.Stmt, error) {
+//SelectTeamMutesByTask selects mutes of the user for the teams where both author and assignee of the Task are members
+func SelectTeamMutesByTask(cfg models.Config, tgid int, taskID int) (*sql.Rows, error) {
 
 	switch cfg.Database.Type {
 	case Sqlite:
-		return  sqlite.DeleteSessionByUUID(cfg.DB)
+		return  sqlite.SelectTeamMutesByTask(cfg.DB, tgid, taskID)
 	case Postgres:
-		return  postgres.DeleteSessionByUUID(cfg.DB)
+		return  postgres.SelectTeamMutesByTask(cfg.DB, tgid, taskID)
 	default:
-		return  sqlite.DeleteSessionByUUID(cfg.DB)
+		return  sqlite.SelectTeamMutesByTask(cfg.DB, tgid, taskID)
+	}
+}
+
+func SelectNotificationChannelsByTelegramID(cfg models.Config, tgid int) (*sql.Rows, error) {
+
+	switch cfg.Database.Type {
+	case Sqlite:
+		return  sqlite.SelectNotificationChannelsByTelegramID(cfg.DB, tgid)
+	case Postgres:
+		return  postgres.SelectNotificationChannelsByTelegramID(cfg.DB, tgid)
+	default:
+		return  sqlite.SelectNotificationChannelsByTelegramID(cfg.DB, tgid)
+	}
+}
+
+func SelectNotificationLogByTelegramID(cfg models.Config, tgid int, limit int) (*sql.Rows, error) {
+
+	switch cfg.Database.Type {
+	case Sqlite:
+		return  sqlite.SelectNotificationLogByTelegramID(cfg.DB, tgid, limit)
+	case Postgres:
+		return  postgres.SelectNotificationLogByTelegramID(cfg.DB, tgid, limit)
+	default:
+		return  sqlite.SelectNotificationLogByTelegramID(cfg.DB, tgid, limit)
+	}
+}
+
+func SelectWebhooks(cfg models.Config) (*sql.Rows, error) {
+
+	switch cfg.Database.Type {
+	case Sqlite:
+		return  sqlite.SelectWebhooks(cfg.DB)
+	case Postgres:
+		return  postgres.SelectWebhooks(cfg.DB)
+	default:
+		return  sqlite.SelectWebhooks(cfg.DB)
+	}
+}
+
+func SelectWebhookByID(cfg models.Config, id int) (*sql.Rows, error) {
+
+	switch cfg.Database.Type {
+	case Sqlite:
+		return  sqlite.SelectWebhookByID(cfg.DB, id)
+	case Postgres:
+		return  postgres.SelectWebhookByID(cfg.DB, id)
+	default:
+		return  sqlite.SelectWebhookByID(cfg.DB, id)
+	}
+}
+
+func SelectWebhookDeliveries(cfg models.Config, webhookID int, limit int) (*sql.Rows, error) {
+
+	switch cfg.Database.Type {
+	case Sqlite:
+		return  sqlite.SelectWebhookDeliveries(cfg.DB, webhookID, limit)
+	case Postgres:
+		return  postgres.SelectWebhookDeliveries(cfg.DB, webhookID, limit)
+	default:
+		return  sqlite.SelectWebhookDeliveries(cfg.DB, webhookID, limit)
+	}
+}
+
+//UpdateWebhookActive is for turning webhook on or off. Uses 2 params
+//1. Active (0/1)
+//2. Webhook ID
+func UpdateWebhookActive(cfg models.Config) (*sql.Stmt, error) {
+
+	switch cfg.Database.Type {
+	case Sqlite:
+		return  sqlite.UpdateWebhookActive(cfg.DB)
+	case Postgres:
+		return  postgres.UpdateWebhookActive(cfg.DB)
+	default:
+		return  sqlite.UpdateWebhookActive(cfg.DB)
+	}
+}
+
+func SelectAPITokenByToken(cfg models.Config, token string) (*sql.Rows, error) {
+
+	switch cfg.Database.Type {
+	case Sqlite:
+		return  sqlite.SelectAPITokenByToken(cfg.DB, token)
+	case Postgres:
+		return  postgres.SelectAPITokenByToken(cfg.DB, token)
+	default:
+		return  sqlite.SelectAPITokenByToken(cfg.DB, token)
+	}
+}
+
+func SelectAPITokensByTelegramID(cfg models.Config, tgid int) (*sql.Rows, error) {
+
+	switch cfg.Database.Type {
+	case Sqlite:
+		return  sqlite.SelectAPITokensByTelegramID(cfg.DB, tgid)
+	case Postgres:
+		return  postgres.SelectAPITokensByTelegramID(cfg.DB, tgid)
+	default:
+		return  sqlite.SelectAPITokensByTelegramID(cfg.DB, tgid)
+	}
+}
+
+func SelectCalendarTokenByToken(cfg models.Config, token string) (*sql.Rows, error) {
+
+	switch cfg.Database.Type {
+	case Sqlite:
+		return  sqlite.SelectCalendarTokenByToken(cfg.DB, token)
+	case Postgres:
+		return  postgres.SelectCalendarTokenByToken(cfg.DB, token)
+	default:
+		return  sqlite.SelectCalendarTokenByToken(cfg.DB, token)
+	}
+}
+
+func SelectCalendarTokensByTelegramID(cfg models.Config, tgid int) (*sql.Rows, error) {
+
+	switch cfg.Database.Type {
+	case Sqlite:
+		return  sqlite.SelectCalendarTokensByTelegramID(cfg.DB, tgid)
+	case Postgres:
+		return  postgres.SelectCalendarTokensByTelegramID(cfg.DB, tgid)
+	default:
+		return  sqlite.SelectCalendarTokensByTelegramID(cfg.DB, tgid)
+	}
+}
+
+func SelectTeams(cfg models.Config) (*sql.Rows, error) {
+
+	switch cfg.Database.Type {
+	case Sqlite:
+		return  sqlite.SelectTeams(cfg.DB)
+	case Postgres:
+		return  postgres.SelectTeams(cfg.DB)
+	default:
+		return  sqlite.SelectTeams(cfg.DB)
+	}
+}
+
+func SelectTeamByID(cfg models.Config, id int) (*sql.Rows, error) {
+
+	switch cfg.Database.Type {
+	case Sqlite:
+		return  sqlite.SelectTeamByID(cfg.DB, id)
+	case Postgres:
+		return  postgres.SelectTeamByID(cfg.DB, id)
+	default:
+		return  sqlite.SelectTeamByID(cfg.DB, id)
+	}
+}
+
+func SelectTeamMembers(cfg models.Config, teamID int) (*sql.Rows, error) {
+
+	switch cfg.Database.Type {
+	case Sqlite:
+		return  sqlite.SelectTeamMembers(cfg.DB, teamID)
+	case Postgres:
+		return  postgres.SelectTeamMembers(cfg.DB, teamID)
+	default:
+		return  sqlite.SelectTeamMembers(cfg.DB, teamID)
+	}
+}
+
+func SelectTeamMembersByTelegramID(cfg models.Config, tgid int) (*sql.Rows, error) {
+
+	switch cfg.Database.Type {
+	case Sqlite:
+		return  sqlite.SelectTeamMembersByTelegramID(cfg.DB, tgid)
+	case Postgres:
+		return  postgres.SelectTeamMembersByTelegramID(cfg.DB, tgid)
+	default:
+		return  sqlite.SelectTeamMembersByTelegramID(cfg.DB, tgid)
+	}
+}
+
+func SelectCommonTeamMembers(cfg models.Config, tgid int, otherTgid int) (*sql.Rows, error) {
+
+	switch cfg.Database.Type {
+	case Sqlite:
+		return  sqlite.SelectCommonTeamMembers(cfg.DB, tgid, otherTgid)
+	case Postgres:
+		return  postgres.SelectCommonTeamMembers(cfg.DB, tgid, otherTgid)
+	default:
+		return  sqlite.SelectCommonTeamMembers(cfg.DB, tgid, otherTgid)
+	}
+}
+
+func SelectTeamTasks(cfg models.Config, tgid int, status string) (*sql.Rows, error) {
+
+	switch cfg.Database.Type {
+	case Sqlite:
+		return  sqlite.SelectTeamTasks(cfg.DB, tgid, status)
+	case Postgres:
+		return  postgres.SelectTeamTasks(cfg.DB, tgid, status)
+	default:
+		return  sqlite.SelectTeamTasks(cfg.DB, tgid, status)
+	}
+}
+
+//UpdateTeamMemberLead is for making a member team lead or not. Uses 2 params
+//1. Lead (0/1)
+//2. Team member ID
+func UpdateTeamMemberLead(cfg models.Config) (*sql.Stmt, error) {
+
+	switch cfg.Database.Type {
+	case Sqlite:
+		return  sqlite.UpdateTeamMemberLead(cfg.DB)
+	case Postgres:
+		return  postgres.UpdateTeamMemberLead(cfg.DB)
+	default:
+		return  sqlite.UpdateTeamMemberLead(cfg.DB)
+	}
+}
+
+func SelectUsersByStatusSearch(cfg models.Config, status string, search string) (*sql.Rows, error) {
+
+	switch cfg.Database.Type {
+	case Sqlite:
+		return  sqlite.SelectUsersByStatusSearch(cfg.DB, status, search)
+	case Postgres:
+		return  postgres.SelectUsersByStatusSearch(cfg.DB, status, search)
+	default:
+		return  sqlite.SelectUsersByStatusSearch(cfg.DB, status, search)
+	}
+}
+
+func SelectUserHistory(cfg models.Config, userID int) (*sql.Rows, error) {
+
+	switch cfg.Database.Type {
+	case Sqlite:
+		return  sqlite.SelectUserHistory(cfg.DB, userID)
+	case Postgres:
+		return  postgres.SelectUserHistory(cfg.DB, userID)
+	default:
+		return  sqlite.SelectUserHistory(cfg.DB, userID)
+	}
+}
+
+//UpdateUserStatusComment is for moderating users with a reason. Uses 5 params
+//1. New user status
+//2. Reason
+//3. When status was changed
+//4. Who changed user status
+//5. User Telegram ID
+func UpdateUserStatusComment(cfg models.Config) (*sql.Stmt, error) {
+
+	switch cfg.Database.Type {
+	case Sqlite:
+		return  sqlite.UpdateUserStatusComment(cfg.DB)
+	case Postgres:
+		return  postgres.UpdateUserStatusComment(cfg.DB)
+	default:
+		return  sqlite.UpdateUserStatusComment(cfg.DB)
+	}
+}
+
+func SelectOutstandingInvites(cfg models.Config) (*sql.Rows, error) {
+
+	switch cfg.Database.Type {
+	case Sqlite:
+		return  sqlite.SelectOutstandingInvites(cfg.DB)
+	case Postgres:
+		return  postgres.SelectOutstandingInvites(cfg.DB)
+	default:
+		return  sqlite.SelectOutstandingInvites(cfg.DB)
 	}
-}
\ No newline at end of file
+}
+
+func SelectInviteByCode(cfg models.Config, code string) (*sql.Rows, error) {
+
+	switch cfg.Database.Type {
+	case Sqlite:
+		return  sqlite.SelectInviteByCode(cfg.DB, code)
+	case Postgres:
+		return  postgres.SelectInviteByCode(cfg.DB, code)
+	default:
+		return  sqlite.SelectInviteByCode(cfg.DB, code)
+	}
+}
+
+//UpdateInviteUse is for counting invite usage. Does nothing if invite is revoked or used up. Uses 1 param
+//1. Invite ID
+func UpdateInviteUse(cfg models.Config) (*sql.Stmt, error) {
+
+	switch cfg.Database.Type {
+	case Sqlite:
+		return  sqlite.UpdateInviteUse(cfg.DB)
+	case Postgres:
+		return  postgres.UpdateInviteUse(cfg.DB)
+	default:
+		return  sqlite.UpdateInviteUse(cfg.DB)
+	}
+}
+
+//UpdateInviteRevoked is for revoking invite. Uses 1 param
+//1. Invite ID
+func UpdateInviteRevoked(cfg models.Config) (*sql.Stmt, error) {
+
+	switch cfg.Database.Type {
+	case Sqlite:
+		return  sqlite.UpdateInviteRevoked(cfg.DB)
+	case Postgres:
+		return  postgres.UpdateInviteRevoked(cfg.DB)
+	default:
+		return  sqlite.UpdateInviteRevoked(cfg.DB)
+	}
+}
+
+func SelectSessionByUUID(cfg models.Config, uuid string) (*sql.Rows, error) {
+
+	switch cfg.Database.Type {
+	case Sqlite:
+		return  sqlite.SelectSessionByUUID(cfg.DB, uuid)
+	case Postgres:
+		return  postgres.SelectSessionByUUID(cfg.DB, uuid)
+	default:
+		return  sqlite.SelectSessionByUUID(cfg.DB, uuid)
+	}
+}
+
+func SelectSessionsByTelegramID(cfg models.Config, tgid int) (*sql.Rows, error) {
+
+	switch cfg.Database.Type {
+	case Sqlite:
+		return  sqlite.SelectSessionsByTelegramID(cfg.DB, tgid)
+	case Postgres:
+		return  postgres.SelectSessionsByTelegramID(cfg.DB, tgid)
+	default:
+		return  sqlite.SelectSessionsByTelegramID(cfg.DB, tgid)
+	}
+}
+
+//SelectTasks returns all Tasks with the status or all Tasks if status is empty
+func SelectTasks(cfg models.Config, status string) (*sql.Rows, error) {
+
+	switch cfg.Database.Type {
+	case Sqlite:
+		return  sqlite.SelectTasks(cfg.DB, status)
+	case Postgres:
+		return  postgres.SelectTasks(cfg.DB, status)
+	default:
+		return  sqlite.SelectTasks(cfg.DB, status)
+	}
+}
+
+func InsertUser(cfg models.Config) (*sql.Stmt, error) {
+
+	switch cfg.Database.Type {
+	case Sqlite:
+		return  sqlite.InsertUser(cfg.DB)
+	case Postgres:
+		return  postgres.InsertUser(cfg.DB)
+	default:
+		return  sqlite.InsertUser(cfg.DB)
+	}
+}
+
+func InsertTask(cfg models.Config) (*sql.Stmt, error) {
+
+	switch cfg.Database.Type {
+	case Sqlite:
+		return  sqlite.InsertTask(cfg.DB)
+	case Postgres:
+		return  postgres.InsertTask(cfg.DB)
+	default:
+		return  sqlite.InsertTask(cfg.DB)
+	}
+}
+
+func InsertAuth(cfg models.Config) (*sql.Stmt, error) {
+
+	switch cfg.Database.Type {
+	case Sqlite:
+		return  sqlite.InsertAuth(cfg.DB)
+	case Postgres:
+		return  postgres.InsertAuth(cfg.DB)
+	default:
+		return  sqlite.InsertAuth(cfg.DB)
+	}
+}
+
+func InsertSession(cfg models.Config) (*sql.Stmt, error) {
+
+	switch cfg.Database.Type {
+	case Sqlite:
+		return  sqlite.InsertSession(cfg.DB)
+	case Postgres:
+		return  postgres.InsertSession(cfg.DB)
+	default:
+		return  sqlite.InsertSession(cfg.DB)
+	}
+}
+
+func InsertTaskMessage(cfg models.Config) (*sql.Stmt, error) {
+
+	switch cfg.Database.Type {
+	case Sqlite:
+		return  sqlite.InsertTaskMessage(cfg.DB)
+	case Postgres:
+		return  postgres.InsertTaskMessage(cfg.DB)
+	default:
+		return  sqlite.InsertTaskMessage(cfg.DB)
+	}
+}
+
+func InsertUserSettings(cfg models.Config) (*sql.Stmt, error) {
+
+	switch cfg.Database.Type {
+	case Sqlite:
+		return  sqlite.InsertUserSettings(cfg.DB)
+	case Postgres:
+		return  postgres.InsertUserSettings(cfg.DB)
+	default:
+		return  sqlite.InsertUserSettings(cfg.DB)
+	}
+}
+
+func InsertTaskMute(cfg models.Config) (*sql.Stmt, error) {
+
+	switch cfg.Database.Type {
+	case Sqlite:
+		return  sqlite.InsertTaskMute(cfg.DB)
+	case Postgres:
+		return  postgres.InsertTaskMute(cfg.DB)
+	default:
+		return  sqlite.InsertTaskMute(cfg.DB)
+	}
+}
+
+func InsertTeamMute(cfg models.Config) (*sql.Stmt, error) {
+
+	switch cfg.Database.Type {
+	case Sqlite:
+		return  sqlite.InsertTeamMute(cfg.DB)
+	case Postgres:
+		return  postgres.InsertTeamMute(cfg.DB)
+	default:
+		return  sqlite.InsertTeamMute(cfg.DB)
+	}
+}
+
+func InsertNotificationChannel(cfg models.Config) (*sql.Stmt, error) {
+
+	switch cfg.Database.Type {
+	case Sqlite:
+		return  sqlite.InsertNotificationChannel(cfg.DB)
+	case Postgres:
+		return  postgres.InsertNotificationChannel(cfg.DB)
+	default:
+		return  sqlite.InsertNotificationChannel(cfg.DB)
+	}
+}
+
+func InsertNotificationLog(cfg models.Config) (*sql.Stmt, error) {
+
+	switch cfg.Database.Type {
+	case Sqlite:
+		return  sqlite.InsertNotificationLog(cfg.DB)
+	case Postgres:
+		return  postgres.InsertNotificationLog(cfg.DB)
+	default:
+		return  sqlite.InsertNotificationLog(cfg.DB)
+	}
+}
+
+func InsertWebhook(cfg models.Config) (*sql.Stmt, error) {
+
+	switch cfg.Database.Type {
+	case Sqlite:
+		return  sqlite.InsertWebhook(cfg.DB)
+	case Postgres:
+		return  postgres.InsertWebhook(cfg.DB)
+	default:
+		return  sqlite.InsertWebhook(cfg.DB)
+	}
+}
+
+func InsertWebhookDelivery(cfg models.Config) (*sql.Stmt, error) {
+
+	switch cfg.Database.Type {
+	case Sqlite:
+		return  sqlite.InsertWebhookDelivery(cfg.DB)
+	case Postgres:
+		return  postgres.InsertWebhookDelivery(cfg.DB)
+	default:
+		return  sqlite.InsertWebhookDelivery(cfg.DB)
+	}
+}
+
+func InsertAPIToken(cfg models.Config) (*sql.Stmt, error) {
+
+	switch cfg.Database.Type {
+	case Sqlite:
+		return  sqlite.InsertAPIToken(cfg.DB)
+	case Postgres:
+		return  postgres.InsertAPIToken(cfg.DB)
+	default:
+		return  sqlite.InsertAPIToken(cfg.DB)
+	}
+}
+
+func InsertCalendarToken(cfg models.Config) (*sql.Stmt, error) {
+
+	switch cfg.Database.Type {
+	case Sqlite:
+		return  sqlite.InsertCalendarToken(cfg.DB)
+	case Postgres:
+		return  postgres.InsertCalendarToken(cfg.DB)
+	default:
+		return  sqlite.InsertCalendarToken(cfg.DB)
+	}
+}
+
+func InsertTeam(cfg models.Config) (*sql.Stmt, error) {
+
+	switch cfg.Database.Type {
+	case Sqlite:
+		return  sqlite.InsertTeam(cfg.DB)
+	case Postgres:
+		return  postgres.InsertTeam(cfg.DB)
+	default:
+		return  sqlite.InsertTeam(cfg.DB)
+	}
+}
+
+func InsertTeamMember(cfg models.Config) (*sql.Stmt, error) {
+
+	switch cfg.Database.Type {
+	case Sqlite:
+		return  sqlite.InsertTeamMember(cfg.DB)
+	case Postgres:
+		return  postgres.InsertTeamMember(cfg.DB)
+	default:
+		return  sqlite.InsertTeamMember(cfg.DB)
+	}
+}
+
+func InsertInvite(cfg models.Config) (*sql.Stmt, error) {
+
+	switch cfg.Database.Type {
+	case Sqlite:
+		return  sqlite.InsertInvite(cfg.DB)
+	case Postgres:
+		return  postgres.InsertInvite(cfg.DB)
+	default:
+		return  sqlite.InsertInvite(cfg.DB)
+	}
+}
+
+//InsertTaskComment adds a comment with its own author and date, e.g. when Tasks are imported. Uses 4 params
+//1. taskid
+//2. tgid
+//3. date
+//4. comment
+func InsertTaskComment(cfg models.Config) (*sql.Stmt, error) {
+
+	switch cfg.Database.Type {
+	case Sqlite:
+		return  sqlite.InsertTaskComment(cfg.DB)
+	case Postgres:
+		return  postgres.InsertTaskComment(cfg.DB)
+	default:
+		return  sqlite.InsertTaskComment(cfg.DB)
+	}
+}
+
+func DeleteAuthByToken(cfg models.Config) (*sql.Stmt, error) {
+
+	switch cfg.Database.Type {
+	case Sqlite:
+		return  sqlite.DeleteAuthByToken(cfg.DB)
+	case Postgres:
+		return  postgres.DeleteAuthByToken(cfg.DB)
+	default:
+		return  sqlite.DeleteAuthByToken(cfg.DB)
+	}
+}
+
+func DeleteSessionByUUID(cfg models.Config) (*sql.Stmt, error) {
+
+	switch cfg.Database.Type {
+	case Sqlite:
+		return  sqlite.DeleteSessionByUUID(cfg.DB)
+	case Postgres:
+		return  postgres.DeleteSessionByUUID(cfg.DB)
+	default:
+		return  sqlite.DeleteSessionByUUID(cfg.DB)
+	}
+}
+
+func DeleteTaskMute(cfg models.Config) (*sql.Stmt, error) {
+
+	switch cfg.Database.Type {
+	case Sqlite:
+		return  sqlite.DeleteTaskMute(cfg.DB)
+	case Postgres:
+		return  postgres.DeleteTaskMute(cfg.DB)
+	default:
+		return  sqlite.DeleteTaskMute(cfg.DB)
+	}
+}
+
+func DeleteNotificationChannels(cfg models.Config) (*sql.Stmt, error) {
+
+	switch cfg.Database.Type {
+	case Sqlite:
+		return  sqlite.DeleteNotificationChannels(cfg.DB)
+	case Postgres:
+		return  postgres.DeleteNotificationChannels(cfg.DB)
+	default:
+		return  sqlite.DeleteNotificationChannels(cfg.DB)
+	}
+}
+
+func DeleteWebhook(cfg models.Config) (*sql.Stmt, error) {
+
+	switch cfg.Database.Type {
+	case Sqlite:
+		return  sqlite.DeleteWebhook(cfg.DB)
+	case Postgres:
+		return  postgres.DeleteWebhook(cfg.DB)
+	default:
+		return  sqlite.DeleteWebhook(cfg.DB)
+	}
+}
+
+func DeleteWebhookDeliveries(cfg models.Config) (*sql.Stmt, error) {
+
+	switch cfg.Database.Type {
+	case Sqlite:
+		return  sqlite.DeleteWebhookDeliveries(cfg.DB)
+	case Postgres:
+		return  postgres.DeleteWebhookDeliveries(cfg.DB)
+	default:
+		return  sqlite.DeleteWebhookDeliveries(cfg.DB)
+	}
+}
+
+func DeleteAPITokensByTelegramID(cfg models.Config) (*sql.Stmt, error) {
+
+	switch cfg.Database.Type {
+	case Sqlite:
+		return  sqlite.DeleteAPITokensByTelegramID(cfg.DB)
+	case Postgres:
+		return  postgres.DeleteAPITokensByTelegramID(cfg.DB)
+	default:
+		return  sqlite.DeleteAPITokensByTelegramID(cfg.DB)
+	}
+}
+
+func DeleteCalendarTokensByTelegramID(cfg models.Config) (*sql.Stmt, error) {
+
+	switch cfg.Database.Type {
+	case Sqlite:
+		return  sqlite.DeleteCalendarTokensByTelegramID(cfg.DB)
+	case Postgres:
+		return  postgres.DeleteCalendarTokensByTelegramID(cfg.DB)
+	default:
+		return  sqlite.DeleteCalendarTokensByTelegramID(cfg.DB)
+	}
+}
+
+func DeleteTeam(cfg models.Config) (*sql.Stmt, error) {
+
+	switch cfg.Database.Type {
+	case Sqlite:
+		return  sqlite.DeleteTeam(cfg.DB)
+	case Postgres:
+		return  postgres.DeleteTeam(cfg.DB)
+	default:
+		return  sqlite.DeleteTeam(cfg.DB)
+	}
+}
+
+func DeleteTeamMembers(cfg models.Config) (*sql.Stmt, error) {
+
+	switch cfg.Database.Type {
+	case Sqlite:
+		return  sqlite.DeleteTeamMembers(cfg.DB)
+	case Postgres:
+		return  postgres.DeleteTeamMembers(cfg.DB)
+	default:
+		return  sqlite.DeleteTeamMembers(cfg.DB)
+	}
+}
+
+func DeleteTeamMute(cfg models.Config) (*sql.Stmt, error) {
+
+	switch cfg.Database.Type {
+	case Sqlite:
+		return  sqlite.DeleteTeamMute(cfg.DB)
+	case Postgres:
+		return  postgres.DeleteTeamMute(cfg.DB)
+	default:
+		return  sqlite.DeleteTeamMute(cfg.DB)
+	}
+}
+
+func DeleteTeamMutesByTeamID(cfg models.Config) (*sql.Stmt, error) {
+
+	switch cfg.Database.Type {
+	case Sqlite:
+		return  sqlite.DeleteTeamMutesByTeamID(cfg.DB)
+	case Postgres:
+		return  postgres.DeleteTeamMutesByTeamID(cfg.DB)
+	default:
+		return  sqlite.DeleteTeamMutesByTeamID(cfg.DB)
+	}
+}
+
+func DeleteTeamMember(cfg models.Config) (*sql.Stmt, error) {
+
+	switch cfg.Database.Type {
+	case Sqlite:
+		return  sqlite.DeleteTeamMember(cfg.DB)
+	case Postgres:
+		return  postgres.DeleteTeamMember(cfg.DB)
+	default:
+		return  sqlite.DeleteTeamMember(cfg.DB)
+	}
+}
+
+func DeleteSessionByID(cfg models.Config) (*sql.Stmt, error) {
+
+	switch cfg.Database.Type {
+	case Sqlite:
+		return  sqlite.DeleteSessionByID(cfg.DB)
+	case Postgres:
+		return  postgres.DeleteSessionByID(cfg.DB)
+	default:
+		return  sqlite.DeleteSessionByID(cfg.DB)
+	}
+}
+
+func DeleteSessionsByTelegramID(cfg models.Config) (*sql.Stmt, error) {
+
+	switch cfg.Database.Type {
+	case Sqlite:
+		return  sqlite.DeleteSessionsByTelegramID(cfg.DB)
+	case Postgres:
+		return  postgres.DeleteSessionsByTelegramID(cfg.DB)
+	default:
+		return  sqlite.DeleteSessionsByTelegramID(cfg.DB)
+	}
+}
+
+func DeleteExpiredSessions(cfg models.Config) (*sql.Stmt, error) {
+
+	switch cfg.Database.Type {
+	case Sqlite:
+		return  sqlite.DeleteExpiredSessions(cfg.DB)
+	case Postgres:
+		return  postgres.DeleteExpiredSessions(cfg.DB)
+	default:
+		return  sqlite.DeleteExpiredSessions(cfg.DB)
+	}
+}
+
+//GetUserSettings returns user settings or defaults if user has never changed them
+func GetUserSettings(cfg models.Config, tgid int) models.DbUserSettings {
+
+	s := models.DbUserSettings{
+		TelegramID:    tgid,
+		Timezone:      "UTC",
+		DigestTime:    "09:00",
+		DigestWeekday: 1,
+		NotifyNewTask: 1,
+		NotifyStatus:  1,
+		NotifyComment: 1,
+		NotifyMention: 1,
+	}
+
+	rows, err := SelectUserSettingsByTelegramID(cfg, tgid)
+	if err != nil {
+		log.Println(err)
+		return s
+	}
+
+	if rows.Next() {
+		err := ScanUserSettings(rows, &s)
+		if err != nil {
+			log.Println(err)
+		}
+	}
+	rows.Close()
+
+	return s
+}
@@ -7,12 +7,12 @@ import (
 
 func ExecInsertUser(stmt *sql.Stmt, u models.DbUsers) (sql.Result, error) {
 
-	return stmt.Exec(u.TelegramID, u.FirstName, u.LastName, u.Admin, u.Status, u.ChangedAt.Time, u.ChangedBy, u.Comment, u.Userpic)
+	return stmt.Exec(u.TelegramID, u.FirstName, u.LastName, u.Admin, u.Status, u.ChangedAt.Time, u.ChangedBy, u.Comment, u.Userpic, u.Username, u.Role)
 }
 
 func ExecInsertTask(stmt *sql.Stmt, t models.DbTasks) (sql.Result, error) {
 
-	return stmt.Exec(t.FromUser, t.ToUser, t.Status, t.ChangedAt.Time, t.ChangedBy, t.Title, t.Description, t.Comment, t.CommentedAt.Time, t.CommentedBy, t.Images, t.Documents)
+	return stmt.Exec(t.FromUser, t.ToUser, t.Status, t.ChangedAt.Time, t.ChangedBy, t.Title, t.Description, t.Comment, t.CommentedAt.Time, t.CommentedBy, t.Images, t.Documents, t.DueDate, t.Priority)
 }
 
 func ExecInsertAuth(stmt *sql.Stmt, a models.DbAuth) (sql.Result, error) {
@@ -23,4 +23,69 @@ func ExecInsertAuth(stmt *sql.Stmt, a models.DbAuth) (sql.Result, error) {
 func ExecInsertSession(stmt *sql.Stmt, s models.DbSessions) (sql.Result, error) {
 
 	return stmt.Exec(s.UUID, s.TelegramID, s.StartedAt.Time, s.LastActivity.Time, s.IP, s.UserAgent)
-}
\ No newline at end of file
+}
+
+func ExecInsertTaskMessage(stmt *sql.Stmt, m models.DbTaskMessages) (sql.Result, error) {
+
+	return stmt.Exec(m.TaskID, m.ChatID, m.MessageID)
+}
+
+func ExecInsertUserSettings(stmt *sql.Stmt, s models.DbUserSettings) (sql.Result, error) {
+
+	return stmt.Exec(s.TelegramID, s.Timezone, s.Digest, s.DigestTime, s.DigestWeekday, s.DigestSentAt, s.NotifyNewTask, s.NotifyStatus, s.NotifyComment, s.NotifyMention, s.QuietFrom, s.QuietTo, s.Email, s.WebhookURL)
+}
+
+func ExecInsertNotificationChannel(stmt *sql.Stmt, nc models.DbNotificationChannels) (sql.Result, error) {
+
+	return stmt.Exec(nc.TelegramID, nc.Event, nc.Channel)
+}
+
+func ExecInsertNotificationLog(stmt *sql.Stmt, nl models.DbNotificationLog) (sql.Result, error) {
+
+	return stmt.Exec(nl.TelegramID, nl.Channel, nl.Event, nl.TaskID, nl.Status, nl.Attempts, nl.Error, nl.CreatedAt)
+}
+
+func ExecInsertTaskMute(stmt *sql.Stmt, m models.DbTaskMutes) (sql.Result, error) {
+
+	return stmt.Exec(m.TelegramID, m.TaskID)
+}
+
+func ExecInsertTeamMute(stmt *sql.Stmt, m models.DbTeamMutes) (sql.Result, error) {
+
+	return stmt.Exec(m.TelegramID, m.TeamID)
+}
+
+func ExecInsertWebhook(stmt *sql.Stmt, wh models.DbWebhooks) (sql.Result, error) {
+
+	return stmt.Exec(wh.URL, wh.Secret, wh.Events, wh.Active, wh.CreatedBy, wh.CreatedAt)
+}
+
+func ExecInsertWebhookDelivery(stmt *sql.Stmt, wd models.DbWebhookDeliveries) (sql.Result, error) {
+
+	return stmt.Exec(wd.WebhookID, wd.Event, wd.Payload, wd.Status, wd.Attempts, wd.ResponseCode, wd.Error, wd.CreatedAt)
+}
+
+func ExecInsertAPIToken(stmt *sql.Stmt, t models.DbAPITokens) (sql.Result, error) {
+
+	return stmt.Exec(t.TelegramID, t.Token, t.CreatedAt)
+}
+
+func ExecInsertTeam(stmt *sql.Stmt, t models.DbTeams) (sql.Result, error) {
+
+	return stmt.Exec(t.Name, t.CreatedBy, t.CreatedAt)
+}
+
+func ExecInsertTeamMember(stmt *sql.Stmt, m models.DbTeamMembers) (sql.Result, error) {
+
+	return stmt.Exec(m.TeamID, m.TelegramID, m.Lead)
+}
+
+func ExecInsertInvite(stmt *sql.Stmt, i models.DbInvites) (sql.Result, error) {
+
+	return stmt.Exec(i.Code, i.Role, i.TeamID, i.MaxUses, i.Uses, i.ExpiresAt, i.CreatedBy, i.CreatedAt, i.Revoked)
+}
+
+func ExecInsertTaskComment(stmt *sql.Stmt, c models.DbTaskComments) (sql.Result, error) {
+
+	return stmt.Exec(c.TaskID, c.UserID, c.Date.Time, c.Comment)
+}
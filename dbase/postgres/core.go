@@ -5,14 +5,22 @@ import (
 	"fmt"
 	"github.com/slevchyk/taskeram/models"
 	"log"
+	"net"
+	"net/url"
 	"strconv"
 	"time"
 )
 
 func ConntectDB(cfg models.Config) (*sql.DB, error) {
 
-	dbName := fmt.Sprintf("postgres://%v:%v@localhost/%v?sslmode=disable", cfg.Database.User, cfg.Database.Password, cfg.Database.Name)
-	db, err := sql.Open("postgres", dbName)
+	dbURL := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(cfg.Database.User, cfg.Database.Password),
+		Host:     net.JoinHostPort(cfg.Database.Host, strconv.Itoa(cfg.Database.Port)),
+		Path:     cfg.Database.Name,
+		RawQuery: url.Values{"sslmode": {cfg.Database.SSLMode}}.Encode(),
+	}
+	db, err := sql.Open("postgres", dbURL.String())
 
 	return db, err
 }
@@ -30,11 +38,16 @@ func InitDB(db *sql.DB, cfg models.Config) {
 			changed_at TIMESTAMP WITH TIME ZONE,
 			changed_by INT DEFAULT 0,
 			comment TEXT DEFAULT '',
-			userpic TEXT DEFAULT '');`)
+			userpic TEXT DEFAULT '',
+			username TEXT DEFAULT '',
+			role TEXT DEFAULT 'member');`)
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	addColumn(db, "users", "username", "TEXT DEFAULT ''")
+	addColumn(db, "users", "role", "TEXT DEFAULT ''")
+
 
 	_, err = db.Exec(`
 		CREATE TABLE IF NOT EXISTS user_history (
@@ -43,26 +56,39 @@ func InitDB(db *sql.DB, cfg models.Config) {
 			status TEXT,
 			changed_by INT DEFAULT 0,
 			changed_at TIMESTAMP WITH TIME ZONE,
-			admin INT DEFAULT 0);`)
+			admin INT DEFAULT 0,
+			role TEXT DEFAULT '',
+			comment TEXT DEFAULT '');`)
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	addColumn(db, "user_history", "role", "TEXT DEFAULT ''")
+	addColumn(db, "user_history", "comment", "TEXT DEFAULT ''")
+
 	_, err = db.Exec(`
 		DROP TRIGGER IF EXISTS update_user_history on public.users;`)
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	//користувачі з попередніх версій отримують роль відповідно до прапорця admin, поки тригер історії вимкнено
+	_, err = db.Exec(`
+		UPDATE users SET role=CASE WHEN admin=1 THEN $1 ELSE $2 END WHERE role='' OR role IS NULL;`, models.RoleAdmin, models.RoleMember)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	_, err = db.Exec(`
 		CREATE OR REPLACE FUNCTION update_user_history()
 		RETURNS trigger AS
 		$BODY$
 		BEGIN
-			IF NEW.status <> OLD.status THEN
-				INSERT INTO user_history(status, changed_by, changed_at, admin)
-				VALUES (NEW.status, NEW.changed_by, NEW.changed_at, NEW.admin);
+			IF NEW.status <> OLD.status OR NEW.role <> OLD.role THEN
+				INSERT INTO user_history(userid, status, changed_by, changed_at, admin, role, comment)
+				VALUES (NEW.id, NEW.status, NEW.changed_by, NEW.changed_at, NEW.admin, NEW.role, NEW.comment);
 			END IF;
+			RETURN NEW;
 		END;
 		$BODY$
  		LANGUAGE plpgsql;`)
@@ -94,11 +120,16 @@ func InitDB(db *sql.DB, cfg models.Config) {
 			commented_at TIMESTAMP WITH TIME ZONE,
 			commented_by INT,
 			images TEXT DEFAULT '',
-			documents TEXT DEFAULT '');`)
+			documents TEXT DEFAULT '',
+			due_date TIMESTAMP WITH TIME ZONE,
+			priority TEXT DEFAULT '');`)
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	addColumn(db, "tasks", "due_date", "TIMESTAMP WITH TIME ZONE")
+	addColumn(db, "tasks", "priority", "TEXT DEFAULT ''")
+
 	_, err = db.Exec(`
 		CREATE TABLE IF NOT EXISTS task_history (
 			id SERIAL PRIMARY KEY,
@@ -216,6 +247,179 @@ func InitDB(db *sql.DB, cfg models.Config) {
 		log.Fatal(err)
 	}
 
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS task_messages(
+			id SERIAL PRIMARY KEY,
+			taskid INT REFERENCES tasks(id),
+			chatid BIGINT NOT NULL,
+			msgid INT NOT NULL);`)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = db.Exec(`
+		CREATE INDEX IF NOT EXISTS task_messages_chat_msg ON task_messages(chatid, msgid);`)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS user_settings(
+			id SERIAL PRIMARY KEY,
+			tgid INT NOT NULL,
+			timezone TEXT DEFAULT 'UTC',
+			digest TEXT DEFAULT '',
+			digest_time TEXT DEFAULT '09:00',
+			digest_weekday INT DEFAULT 1,
+			digest_sent_at TIMESTAMP WITH TIME ZONE,
+			notify_new_task INT DEFAULT 1,
+			notify_status INT DEFAULT 1,
+			notify_comment INT DEFAULT 1,
+			notify_mention INT DEFAULT 1,
+			quiet_from TEXT DEFAULT '',
+			quiet_to TEXT DEFAULT '',
+			email TEXT DEFAULT '',
+			webhook_url TEXT DEFAULT '');`)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	addColumn(db, "user_settings", "notify_new_task", "INT DEFAULT 1")
+	addColumn(db, "user_settings", "notify_status", "INT DEFAULT 1")
+	addColumn(db, "user_settings", "notify_comment", "INT DEFAULT 1")
+	addColumn(db, "user_settings", "notify_mention", "INT DEFAULT 1")
+	addColumn(db, "user_settings", "quiet_from", "TEXT DEFAULT ''")
+	addColumn(db, "user_settings", "quiet_to", "TEXT DEFAULT ''")
+	addColumn(db, "user_settings", "email", "TEXT DEFAULT ''")
+	addColumn(db, "user_settings", "webhook_url", "TEXT DEFAULT ''")
+
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS notification_channels(
+			id SERIAL PRIMARY KEY,
+			tgid INT NOT NULL,
+			event TEXT NOT NULL,
+			channel TEXT NOT NULL);`)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS notification_log(
+			id SERIAL PRIMARY KEY,
+			tgid INT NOT NULL,
+			channel TEXT NOT NULL,
+			event TEXT NOT NULL,
+			taskid INT DEFAULT 0,
+			status TEXT NOT NULL,
+			attempts INT DEFAULT 0,
+			error TEXT DEFAULT '',
+			created_at TIMESTAMP WITH TIME ZONE);`)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS task_mutes(
+			id SERIAL PRIMARY KEY,
+			tgid INT NOT NULL,
+			taskid INT REFERENCES tasks(id));`)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS webhooks(
+			id SERIAL PRIMARY KEY,
+			url TEXT NOT NULL,
+			secret TEXT NOT NULL,
+			events TEXT DEFAULT '',
+			active INT DEFAULT 1,
+			created_by INT DEFAULT 0,
+			created_at TIMESTAMP WITH TIME ZONE);`)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS webhook_deliveries(
+			id SERIAL PRIMARY KEY,
+			webhookid INT REFERENCES webhooks(id),
+			event TEXT NOT NULL,
+			payload TEXT DEFAULT '',
+			status TEXT NOT NULL,
+			attempts INT DEFAULT 0,
+			response_code INT DEFAULT 0,
+			error TEXT DEFAULT '',
+			created_at TIMESTAMP WITH TIME ZONE);`)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS api_tokens(
+			id SERIAL PRIMARY KEY,
+			tgid INT NOT NULL,
+			token TEXT NOT NULL,
+			created_at TIMESTAMP WITH TIME ZONE);`)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS calendar_tokens(
+			id SERIAL PRIMARY KEY,
+			tgid INT NOT NULL,
+			token TEXT NOT NULL,
+			created_at TIMESTAMP WITH TIME ZONE);`)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS teams(
+			id SERIAL PRIMARY KEY,
+			name TEXT NOT NULL,
+			created_by INT DEFAULT 0,
+			created_at TIMESTAMP WITH TIME ZONE);`)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS team_members(
+			id SERIAL PRIMARY KEY,
+			teamid INT REFERENCES teams(id),
+			tgid INT NOT NULL,
+			lead INT DEFAULT 0);`)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS team_mutes(
+			id SERIAL PRIMARY KEY,
+			tgid INT NOT NULL,
+			teamid INT REFERENCES teams(id));`)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS invites(
+			id SERIAL PRIMARY KEY,
+			code TEXT NOT NULL,
+			role TEXT DEFAULT '',
+			teamid INT DEFAULT 0,
+			max_uses INT DEFAULT 1,
+			uses INT DEFAULT 0,
+			expires_at TIMESTAMP WITH TIME ZONE,
+			created_by INT DEFAULT 0,
+			created_at TIMESTAMP WITH TIME ZONE,
+			revoked INT DEFAULT 0);`)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS sessions (
 				id SERIAL PRIMARY KEY,
 				uuid TEXT NOT NULL,
@@ -261,15 +465,23 @@ func InitDB(db *sql.DB, cfg models.Config) {
 
 	if !rows.Next() {
 		stmt, err := db.Prepare(`
-			INSERT INTO users (tgid, first_name, last_name, admin, status, changed_at) VALUES ($1, $2, $3, $4, $5, $6)`)
+			INSERT INTO users (tgid, first_name, last_name, admin, status, changed_at, role) VALUES ($1, $2, $3, $4, $5, $6, $7)`)
 		if err != nil {
 			log.Fatal(err)
 		}
 
-		_, err = stmt.Exec(tgID, "admin", "admin", 1, models.UserApprowed, time.Now().UTC())
+		_, err = stmt.Exec(tgID, "admin", "admin", 1, models.UserApprowed, time.Now().UTC(), models.RoleAdmin)
 		if err != nil {
 			log.Fatal(err)
 		}
 	}
 }
 
+//addColumn adds a column to a table created by an older version of Taskeram
+func addColumn(db *sql.DB, table string, column string, definition string) {
+
+	_, err := db.Exec(fmt.Sprintf("ALTER TABLE %v ADD COLUMN IF NOT EXISTS %v %v;", table, column, definition))
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -19,3 +19,133 @@ func DeleteSessionByUUID(db *sql.DB) (*sql.Stmt, error)  {
 		WHERE
 			uuid=$1;`)
 }
+
+func DeleteTaskMute(db *sql.DB) (*sql.Stmt, error)  {
+
+	return db.Prepare(`
+		DELETE 
+		FROM task_mutes
+		WHERE
+			tgid=$1
+			AND taskid=$2;`)
+}
+
+func DeleteTeamMute(db *sql.DB) (*sql.Stmt, error)  {
+
+	return db.Prepare(`
+		DELETE 
+		FROM team_mutes
+		WHERE
+			tgid=$1
+			AND teamid=$2;`)
+}
+
+func DeleteTeamMutesByTeamID(db *sql.DB) (*sql.Stmt, error)  {
+
+	return db.Prepare(`
+		DELETE 
+		FROM team_mutes
+		WHERE
+			teamid=$1;`)
+}
+
+func DeleteNotificationChannels(db *sql.DB) (*sql.Stmt, error)  {
+
+	return db.Prepare(`
+		DELETE 
+		FROM notification_channels
+		WHERE
+			tgid=$1
+			AND event=$2;`)
+}
+
+func DeleteWebhook(db *sql.DB) (*sql.Stmt, error)  {
+
+	return db.Prepare(`
+		DELETE 
+		FROM webhooks
+		WHERE
+			id=$1;`)
+}
+
+func DeleteWebhookDeliveries(db *sql.DB) (*sql.Stmt, error)  {
+
+	return db.Prepare(`
+		DELETE 
+		FROM webhook_deliveries
+		WHERE
+			webhookid=$1;`)
+}
+
+func DeleteAPITokensByTelegramID(db *sql.DB) (*sql.Stmt, error)  {
+
+	return db.Prepare(`
+		DELETE 
+		FROM api_tokens
+		WHERE
+			tgid=$1;`)
+}
+
+func DeleteCalendarTokensByTelegramID(db *sql.DB) (*sql.Stmt, error)  {
+
+	return db.Prepare(`
+		DELETE 
+		FROM calendar_tokens
+		WHERE
+			tgid=$1;`)
+}
+
+func DeleteTeam(db *sql.DB) (*sql.Stmt, error)  {
+
+	return db.Prepare(`
+		DELETE 
+		FROM teams
+		WHERE
+			id=$1;`)
+}
+
+func DeleteTeamMembers(db *sql.DB) (*sql.Stmt, error)  {
+
+	return db.Prepare(`
+		DELETE 
+		FROM team_members
+		WHERE
+			teamid=$1;`)
+}
+
+func DeleteTeamMember(db *sql.DB) (*sql.Stmt, error)  {
+
+	return db.Prepare(`
+		DELETE 
+		FROM team_members
+		WHERE
+			id=$1;`)
+}
+
+func DeleteSessionByID(db *sql.DB) (*sql.Stmt, error)  {
+
+	return db.Prepare(`
+		DELETE 
+		FROM sessions
+		WHERE
+			id=$1;`)
+}
+
+func DeleteSessionsByTelegramID(db *sql.DB) (*sql.Stmt, error)  {
+
+	return db.Prepare(`
+		DELETE 
+		FROM sessions
+		WHERE
+			tgid=$1;`)
+}
+
+func DeleteExpiredSessions(db *sql.DB) (*sql.Stmt, error)  {
+
+	return db.Prepare(`
+		DELETE 
+		FROM sessions
+		WHERE
+			last_activity<$1
+			OR started_at<$2;`)
+}
@@ -15,8 +15,10 @@ func InsertUser(db *sql.DB) (*sql.Stmt, error)  {
 				changed_at,
 				changed_by,
 				comment,
-				userpic)
-		VALUES ($1, $2, $3, $4, $5, $6. $7, $8, $9);`)
+				userpic,
+				username,
+				role)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11);`)
 }
 
 func InsertTask(db *sql.DB) (*sql.Stmt, error) {
@@ -35,8 +37,10 @@ func InsertTask(db *sql.DB) (*sql.Stmt, error) {
 				commented_at,
 				commented_by,
 				images,
-				documents)
-		VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12);`)
+				documents,
+				due_date,
+				priority)
+		VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14);`)
 }
 
 func InsertAuth(db *sql.DB) (*sql.Stmt, error) {
@@ -63,4 +67,187 @@ func InsertSession(db *sql.DB) (*sql.Stmt, error) {
 				ip,
 				user_agent)
 		VALUES ($1, $2, $3, $4, $5, $6);`)
-}
\ No newline at end of file
+}
+
+func InsertTaskMessage(db *sql.DB) (*sql.Stmt, error) {
+
+	return db.Prepare(`
+		INSERT INTO
+			task_messages (
+				taskid,
+				chatid,
+				msgid)
+		VALUES ($1, $2, $3);`)
+}
+
+func InsertUserSettings(db *sql.DB) (*sql.Stmt, error) {
+
+	return db.Prepare(`
+		INSERT INTO
+			user_settings (
+				tgid,
+				timezone,
+				digest,
+				digest_time,
+				digest_weekday,
+				digest_sent_at,
+				notify_new_task,
+				notify_status,
+				notify_comment,
+				notify_mention,
+				quiet_from,
+				quiet_to,
+				email,
+				webhook_url)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14);`)
+}
+
+func InsertTaskMute(db *sql.DB) (*sql.Stmt, error) {
+
+	return db.Prepare(`
+		INSERT INTO
+			task_mutes (
+				tgid,
+				taskid)
+		VALUES ($1, $2);`)
+}
+
+func InsertTeamMute(db *sql.DB) (*sql.Stmt, error) {
+
+	return db.Prepare(`
+		INSERT INTO
+			team_mutes (
+				tgid,
+				teamid)
+		VALUES ($1, $2);`)
+}
+
+func InsertNotificationChannel(db *sql.DB) (*sql.Stmt, error) {
+
+	return db.Prepare(`
+		INSERT INTO
+			notification_channels (
+				tgid,
+				event,
+				channel)
+		VALUES ($1, $2, $3);`)
+}
+
+func InsertNotificationLog(db *sql.DB) (*sql.Stmt, error) {
+
+	return db.Prepare(`
+		INSERT INTO
+			notification_log (
+				tgid,
+				channel,
+				event,
+				taskid,
+				status,
+				attempts,
+				error,
+				created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8);`)
+}
+
+func InsertWebhook(db *sql.DB) (*sql.Stmt, error) {
+
+	return db.Prepare(`
+		INSERT INTO
+			webhooks (
+				url,
+				secret,
+				events,
+				active,
+				created_by,
+				created_at)
+		VALUES ($1, $2, $3, $4, $5, $6);`)
+}
+
+func InsertWebhookDelivery(db *sql.DB) (*sql.Stmt, error) {
+
+	return db.Prepare(`
+		INSERT INTO
+			webhook_deliveries (
+				webhookid,
+				event,
+				payload,
+				status,
+				attempts,
+				response_code,
+				error,
+				created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8);`)
+}
+
+func InsertAPIToken(db *sql.DB) (*sql.Stmt, error) {
+
+	return db.Prepare(`
+		INSERT INTO
+			api_tokens (
+				tgid,
+				token,
+				created_at)
+		VALUES ($1, $2, $3);`)
+}
+
+func InsertCalendarToken(db *sql.DB) (*sql.Stmt, error) {
+
+	return db.Prepare(`
+		INSERT INTO
+			calendar_tokens (
+				tgid,
+				token,
+				created_at)
+		VALUES ($1, $2, $3);`)
+}
+
+func InsertTeam(db *sql.DB) (*sql.Stmt, error) {
+
+	return db.Prepare(`
+		INSERT INTO
+			teams (
+				name,
+				created_by,
+				created_at)
+		VALUES ($1, $2, $3);`)
+}
+
+func InsertTeamMember(db *sql.DB) (*sql.Stmt, error) {
+
+	return db.Prepare(`
+		INSERT INTO
+			team_members (
+				teamid,
+				tgid,
+				lead)
+		VALUES ($1, $2, $3);`)
+}
+
+func InsertInvite(db *sql.DB) (*sql.Stmt, error) {
+
+	return db.Prepare(`
+		INSERT INTO
+			invites (
+				code,
+				role,
+				teamid,
+				max_uses,
+				uses,
+				expires_at,
+				created_by,
+				created_at,
+				revoked)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9);`)
+}
+
+func InsertTaskComment(db *sql.DB) (*sql.Stmt, error) {
+
+	return db.Prepare(`
+		INSERT INTO
+			task_comments (
+				taskid,
+				tgid,
+				date,
+				comment)
+		VALUES ($1, $2, $3, $4);`)
+}
@@ -3,6 +3,7 @@ package postgres
 import (
 	"database/sql"
 	"github.com/slevchyk/taskeram/models"
+	"time"
 )
 
 func SelectUsersByTelegramID(db *sql.DB, tgid int) (*sql.Rows, error) {
@@ -18,7 +19,9 @@ func SelectUsersByTelegramID(db *sql.DB, tgid int) (*sql.Rows, error) {
 			u.changed_by,
 			u.changed_at,
 			u.comment,
-			u.userpic		
+			u.userpic,
+			u.username,
+			u.role
 		FROM 
 			users u
 		WHERE
@@ -40,7 +43,9 @@ func SelectUsersByStatus(db *sql.DB, status string) (*sql.Rows, error) {
 			u.changed_by,
 			u.changed_at,
 			u.comment,
-			u.userpic			
+			u.userpic,
+			u.username,
+			u.role
 		FROM 
 			users u
 		WHERE
@@ -62,7 +67,9 @@ func SelectUsersByTelegramIDStatus(db *sql.DB, tgid int, status string) (*sql.Ro
 			u.changed_by,
 			u.changed_at,
 			u.comment,
-			u.userpic	
+			u.userpic,
+			u.username,
+			u.role
 		FROM 
 			users u
 		WHERE
@@ -85,13 +92,15 @@ func SelectAdminUsers(db *sql.DB) (*sql.Rows, error) {
 			u.changed_by,
 			u.changed_at,
 			u.comment,
-			u.userpic			
+			u.userpic,
+			u.username,
+			u.role
 		FROM 
 			users u
 		WHERE
-			admin=1
+			u.role IN ($1, $2)
 		ORDER BY
-				u.id`)
+				u.id`, models.RoleAdmin, models.RoleManager)
 }
 
 func SelectUsersForBan(db *sql.DB, tgid int) (*sql.Rows, error) {
@@ -107,7 +116,9 @@ func SelectUsersForBan(db *sql.DB, tgid int) (*sql.Rows, error) {
 			u.changed_by,
 			u.changed_at,
 			u.comment,
-			u.userpic		
+			u.userpic,
+			u.username,
+			u.role
 		FROM 
 			users u
 		WHERE
@@ -130,7 +141,9 @@ func SelectUsersForUnban(db *sql.DB, tgid int) (*sql.Rows, error) {
 			u.changed_by,
 			u.changed_at,
 			u.comment,
-			u.userpic	
+			u.userpic,
+			u.username,
+			u.role
 		FROM 
 			users u
 		WHERE
@@ -156,7 +169,9 @@ func SelectTasksByID(db *sql.DB, taskID int) (*sql.Rows, error) {
 			t.commented_at,
 			t.commented_by,
 			t.images,
-			t.documents			
+			t.documents,
+			t.due_date,
+			t.priority
 		FROM tasks t
 		WHERE
 			t.id=$1
@@ -180,7 +195,9 @@ func SelectTasksByIDUserTelegramID(db *sql.DB, taskID int, tgid int) (*sql.Rows,
 			t.commented_at,
 			t.commented_by,
 			t.images,
-			t.documents		
+			t.documents,
+			t.due_date,
+			t.priority
 		FROM tasks t
 		WHERE
 			t.id=$1
@@ -206,7 +223,9 @@ func SelectInboxTasks(db *sql.DB, tgid int, status string) (*sql.Rows, error) {
 			t.commented_at,
 			t.commented_by,
 			t.images,
-			t.documents			
+			t.documents,
+			t.due_date,
+			t.priority
 		FROM tasks t
 		WHERE
 			t.to_user=$1
@@ -231,7 +250,9 @@ func SelectSentTasks(db *sql.DB, tgid int, status string) (*sql.Rows, error) {
 			t.commented_at,
 			t.commented_by,
 			t.images,
-			t.documents			
+			t.documents,
+			t.due_date,
+			t.priority
 		FROM tasks t
 		WHERE
 			t.from_user=$1
@@ -335,9 +356,564 @@ func SelectUsersBySessionUUID(db *sql.DB, uuid string) (*sql.Rows, error) {
 			u.changed_by,
 			u.changed_at,
 			u.comment,
-			u.userpic	
+			u.userpic,
+			u.username,
+			u.role
 		FROM sessions s
 			LEFT JOIN users u
 			ON s.tgid = u.tgid
 		WHERE s.uuid = $1;`, uuid)
 }
+
+func SelectTaskMessage(db *sql.DB, chatID int64, msgID int) (*sql.Rows, error) {
+
+	return db.Query(`
+		SELECT
+			m.id,
+			m.taskid,
+			m.chatid,
+			m.msgid
+		FROM task_messages m
+		WHERE
+			m.chatid=$1
+			AND m.msgid=$2
+		ORDER BY
+			m.id DESC`, chatID, msgID)
+}
+
+func SelectCommentsSince(db *sql.DB, tgid int, since time.Time) (*sql.Rows, error) {
+
+	return db.Query(`
+		SELECT 
+			c.comment,
+			c.date,
+			c.taskid,							
+			c.tgid,
+			u.first_name,
+			u.last_name,
+			t.title
+		FROM
+			task_comments c
+		LEFT JOIN
+			users u
+			ON c.tgid = u.tgid
+		LEFT JOIN
+			tasks t 
+			ON c.taskid = t.id
+		WHERE
+			(t.from_user=$1
+				OR t.to_user=$2)
+			AND c.tgid!=$3
+			AND c.date>$4
+		ORDER BY 
+			c.taskid,
+			c.date`, tgid, tgid, tgid, since)
+}
+
+func SelectUserSettingsByTelegramID(db *sql.DB, tgid int) (*sql.Rows, error) {
+
+	return db.Query(`
+		SELECT
+			s.id,
+			s.tgid,
+			s.timezone,
+			s.digest,
+			s.digest_time,
+			s.digest_weekday,
+			s.digest_sent_at,
+			s.notify_new_task,
+			s.notify_status,
+			s.notify_comment,
+			s.notify_mention,
+			s.quiet_from,
+			s.quiet_to,
+			s.email,
+			s.webhook_url
+		FROM user_settings s
+		WHERE
+			s.tgid=$1
+		ORDER BY
+			s.id`, tgid)
+}
+
+func SelectUserSettingsWithDigest(db *sql.DB) (*sql.Rows, error) {
+
+	return db.Query(`
+		SELECT
+			s.id,
+			s.tgid,
+			s.timezone,
+			s.digest,
+			s.digest_time,
+			s.digest_weekday,
+			s.digest_sent_at,
+			s.notify_new_task,
+			s.notify_status,
+			s.notify_comment,
+			s.notify_mention,
+			s.quiet_from,
+			s.quiet_to,
+			s.email,
+			s.webhook_url
+		FROM user_settings s
+		LEFT JOIN
+			users u
+			ON s.tgid = u.tgid
+		WHERE
+			s.digest!=''
+			AND u.status=$1
+		ORDER BY
+			s.id`, models.UserApprowed)
+}
+
+func SelectTaskMutesByTelegramID(db *sql.DB, tgid int) (*sql.Rows, error) {
+
+	return db.Query(`
+		SELECT
+			m.id,
+			m.tgid,
+			m.taskid
+		FROM task_mutes m
+		WHERE
+			m.tgid=$1
+		ORDER BY
+			m.taskid`, tgid)
+}
+
+func SelectTaskMute(db *sql.DB, tgid int, taskID int) (*sql.Rows, error) {
+
+	return db.Query(`
+		SELECT
+			m.id,
+			m.tgid,
+			m.taskid
+		FROM task_mutes m
+		WHERE
+			m.tgid=$1
+			AND m.taskid=$2`, tgid, taskID)
+}
+
+func SelectTeamMutesByTelegramID(db *sql.DB, tgid int) (*sql.Rows, error) {
+
+	return db.Query(`
+		SELECT
+			m.id,
+			m.tgid,
+			m.teamid
+		FROM team_mutes m
+		WHERE
+			m.tgid=$1
+		ORDER BY
+			m.teamid`, tgid)
+}
+
+//SelectTeamMutesByTask selects mutes of the user for the teams where both author and assignee of the Task are members
+func SelectTeamMutesByTask(db *sql.DB, tgid int, taskID int) (*sql.Rows, error) {
+
+	return db.Query(`
+		SELECT
+			m.id,
+			m.tgid,
+			m.teamid
+		FROM team_mutes m
+			INNER JOIN team_members f ON f.teamid=m.teamid
+			INNER JOIN team_members a ON a.teamid=m.teamid
+			INNER JOIN tasks t ON t.from_user=f.tgid AND t.to_user=a.tgid
+		WHERE
+			m.tgid=$1
+			AND t.id=$2`, tgid, taskID)
+}
+
+func SelectNotificationChannelsByTelegramID(db *sql.DB, tgid int) (*sql.Rows, error) {
+
+	return db.Query(`
+		SELECT
+			nc.id,
+			nc.tgid,
+			nc.event,
+			nc.channel
+		FROM notification_channels nc
+		WHERE
+			nc.tgid=$1
+		ORDER BY
+			nc.id`, tgid)
+}
+
+func SelectNotificationLogByTelegramID(db *sql.DB, tgid int, limit int) (*sql.Rows, error) {
+
+	return db.Query(`
+		SELECT
+			nl.id,
+			nl.tgid,
+			nl.channel,
+			nl.event,
+			nl.taskid,
+			nl.status,
+			nl.attempts,
+			nl.error,
+			nl.created_at
+		FROM notification_log nl
+		WHERE
+			nl.tgid=$1
+		ORDER BY
+			nl.id DESC
+		LIMIT $2`, tgid, limit)
+}
+
+func SelectWebhooks(db *sql.DB) (*sql.Rows, error) {
+
+	return db.Query(`
+		SELECT
+			w.id,
+			w.url,
+			w.secret,
+			w.events,
+			w.active,
+			w.created_by,
+			w.created_at
+		FROM webhooks w
+		ORDER BY
+			w.id`)
+}
+
+func SelectWebhookByID(db *sql.DB, id int) (*sql.Rows, error) {
+
+	return db.Query(`
+		SELECT
+			w.id,
+			w.url,
+			w.secret,
+			w.events,
+			w.active,
+			w.created_by,
+			w.created_at
+		FROM webhooks w
+		WHERE
+			w.id=$1`, id)
+}
+
+func SelectWebhookDeliveries(db *sql.DB, webhookID int, limit int) (*sql.Rows, error) {
+
+	return db.Query(`
+		SELECT
+			wd.id,
+			wd.webhookid,
+			wd.event,
+			wd.payload,
+			wd.status,
+			wd.attempts,
+			wd.response_code,
+			wd.error,
+			wd.created_at
+		FROM webhook_deliveries wd
+		WHERE
+			wd.webhookid=$1
+		ORDER BY
+			wd.id DESC
+		LIMIT $2`, webhookID, limit)
+}
+
+func SelectAPITokenByToken(db *sql.DB, token string) (*sql.Rows, error) {
+
+	return db.Query(`
+		SELECT
+			t.id,
+			t.tgid,
+			t.token,
+			t.created_at
+		FROM api_tokens t
+		WHERE
+			t.token=$1`, token)
+}
+
+func SelectAPITokensByTelegramID(db *sql.DB, tgid int) (*sql.Rows, error) {
+
+	return db.Query(`
+		SELECT
+			t.id,
+			t.tgid,
+			t.token,
+			t.created_at
+		FROM api_tokens t
+		WHERE
+			t.tgid=$1`, tgid)
+}
+
+func SelectCalendarTokenByToken(db *sql.DB, token string) (*sql.Rows, error) {
+
+	return db.Query(`
+		SELECT
+			t.id,
+			t.tgid,
+			t.token,
+			t.created_at
+		FROM calendar_tokens t
+		WHERE
+			t.token=$1`, token)
+}
+
+func SelectCalendarTokensByTelegramID(db *sql.DB, tgid int) (*sql.Rows, error) {
+
+	return db.Query(`
+		SELECT
+			t.id,
+			t.tgid,
+			t.token,
+			t.created_at
+		FROM calendar_tokens t
+		WHERE
+			t.tgid=$1`, tgid)
+}
+
+func SelectTeams(db *sql.DB) (*sql.Rows, error) {
+
+	return db.Query(`
+		SELECT
+			tm.id,
+			tm.name,
+			tm.created_by,
+			tm.created_at
+		FROM teams tm
+		ORDER BY
+			tm.name`)
+}
+
+func SelectTeamByID(db *sql.DB, id int) (*sql.Rows, error) {
+
+	return db.Query(`
+		SELECT
+			tm.id,
+			tm.name,
+			tm.created_by,
+			tm.created_at
+		FROM teams tm
+		WHERE
+			tm.id=$1`, id)
+}
+
+func SelectTeamMembers(db *sql.DB, teamID int) (*sql.Rows, error) {
+
+	return db.Query(`
+		SELECT
+			m.id,
+			m.teamid,
+			m.tgid,
+			m.lead
+		FROM team_members m
+		WHERE
+			m.teamid=$1
+		ORDER BY
+			m.lead DESC,
+			m.id`, teamID)
+}
+
+func SelectTeamMembersByTelegramID(db *sql.DB, tgid int) (*sql.Rows, error) {
+
+	return db.Query(`
+		SELECT
+			m.id,
+			m.teamid,
+			m.tgid,
+			m.lead
+		FROM team_members m
+		WHERE
+			m.tgid=$1
+		ORDER BY
+			m.teamid`, tgid)
+}
+
+func SelectCommonTeamMembers(db *sql.DB, tgid int, otherTgid int) (*sql.Rows, error) {
+
+	return db.Query(`
+		SELECT
+			m.id,
+			m.teamid,
+			m.tgid,
+			m.lead
+		FROM team_members m
+			INNER JOIN team_members o ON o.teamid=m.teamid
+		WHERE
+			m.tgid=$1
+			AND o.tgid=$2`, tgid, otherTgid)
+}
+
+func SelectTeamTasks(db *sql.DB, tgid int, status string) (*sql.Rows, error) {
+
+	return db.Query(`
+		SELECT
+			t.ID,
+			t.from_user,
+			t.to_user,
+			t.status,
+			t.changed_at,
+			t.changed_by,
+			t.title,
+			t.description,
+			t.comment,
+			t.commented_at,
+			t.commented_by,
+			t.images,
+			t.documents,
+			t.due_date,
+			t.priority
+		FROM tasks t
+		WHERE
+			t.to_user IN (
+				SELECT
+					m.tgid
+				FROM team_members m
+					INNER JOIN team_members l ON l.teamid=m.teamid
+				WHERE
+					l.tgid=$1
+					AND l.lead=1)
+			AND t.status=$2
+		ORDER BY
+			t.id`, tgid, status)
+}
+
+func SelectUsersByStatusSearch(db *sql.DB, status string, search string) (*sql.Rows, error) {
+
+	return db.Query(`
+		SELECT
+			u.id,
+			u.tgid,
+			u.first_name,
+			u.last_name,
+			u.admin,
+			u.status,
+			u.changed_by,
+			u.changed_at,
+			u.comment,
+			u.userpic,
+			u.username,
+			u.role
+		FROM 
+			users u
+		WHERE
+			u.status=$1
+			AND (LOWER(u.first_name) LIKE $2
+				OR LOWER(u.last_name) LIKE $3
+				OR LOWER(u.username) LIKE $4
+				OR CAST(u.tgid AS TEXT) LIKE $5)
+		ORDER BY
+			u.id`, status, search, search, search, search)
+}
+
+func SelectUserHistory(db *sql.DB, userID int) (*sql.Rows, error) {
+
+	return db.Query(`
+		SELECT
+			h.id,
+			h.userid,
+			h.status,
+			h.role,
+			h.comment,
+			h.changed_by,
+			h.changed_at
+		FROM user_history h
+		WHERE
+			h.userid=$1
+		ORDER BY
+			h.id DESC`, userID)
+}
+
+func SelectOutstandingInvites(db *sql.DB) (*sql.Rows, error) {
+
+	return db.Query(`
+		SELECT
+			i.id,
+			i.code,
+			i.role,
+			i.teamid,
+			i.max_uses,
+			i.uses,
+			i.expires_at,
+			i.created_by,
+			i.created_at,
+			i.revoked
+		FROM invites i
+		WHERE
+			i.revoked=0
+			AND i.uses<i.max_uses
+		ORDER BY
+			i.id`)
+}
+
+func SelectInviteByCode(db *sql.DB, code string) (*sql.Rows, error) {
+
+	return db.Query(`
+		SELECT
+			i.id,
+			i.code,
+			i.role,
+			i.teamid,
+			i.max_uses,
+			i.uses,
+			i.expires_at,
+			i.created_by,
+			i.created_at,
+			i.revoked
+		FROM invites i
+		WHERE
+			i.code=$1`, code)
+}
+
+func SelectSessionByUUID(db *sql.DB, uuid string) (*sql.Rows, error) {
+
+	return db.Query(`
+		SELECT 
+			s.id,
+			s.uuid,
+			s.tgid,
+			s.started_at,
+			s.last_activity,
+			s.ip,
+			s.user_agent
+		FROM sessions s
+		WHERE
+			s.uuid=$1`, uuid)
+}
+
+func SelectSessionsByTelegramID(db *sql.DB, tgid int) (*sql.Rows, error) {
+
+	return db.Query(`
+		SELECT 
+			s.id,
+			s.uuid,
+			s.tgid,
+			s.started_at,
+			s.last_activity,
+			s.ip,
+			s.user_agent
+		FROM sessions s
+		WHERE
+			s.tgid=$1
+		ORDER BY
+			s.last_activity DESC`, tgid)
+}
+
+func SelectTasks(db *sql.DB, status string) (*sql.Rows, error) {
+
+	return db.Query(`
+		SELECT
+			t.ID,
+			t.from_user,
+			t.to_user,
+			t.status,
+			t.changed_at,
+			t.changed_by,
+			t.title,
+			t.description,
+			t.comment,
+			t.commented_at,
+			t.commented_by,
+			t.images,
+			t.documents,
+			t.due_date,
+			t.priority
+		FROM tasks t
+		WHERE
+			($1='' OR t.status=$1)
+		ORDER BY
+			t.id`, status)
+}
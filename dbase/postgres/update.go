@@ -30,6 +30,18 @@ func UpdateUserData(db *sql.DB) (*sql.Stmt, error) {
 			`)
 }
 
+func UpdateUserUsername(db *sql.DB) (*sql.Stmt, error) {
+
+	return db.Prepare(`
+			UPDATE
+				users
+			SET
+				username=$1
+			WHERE
+				tgid=$2
+			`)
+}
+
 func UpdateTaskStatus(db *sql.DB) (*sql.Stmt, error) {
 
 	return db.Prepare(`
@@ -76,4 +88,111 @@ func UpdateSessionLastActivityByUuid(db *sql.DB) (*sql.Stmt, error) {
 			last_activity=$1
 		WHERE
 			uuid=$2;`)
-}
\ No newline at end of file
+}
+
+func UpdateUserSettings(db *sql.DB) (*sql.Stmt, error) {
+
+	return db.Prepare(`
+		UPDATE 
+			user_settings
+		SET
+			timezone=$1,
+			digest=$2,
+			digest_time=$3,
+			digest_weekday=$4,
+			notify_new_task=$5,
+			notify_status=$6,
+			notify_comment=$7,
+			notify_mention=$8,
+			quiet_from=$9,
+			quiet_to=$10,
+			email=$11,
+			webhook_url=$12
+		WHERE
+			tgid=$13;`)
+}
+
+func UpdateUserSettingsDigestSentAt(db *sql.DB) (*sql.Stmt, error) {
+
+	return db.Prepare(`
+		UPDATE 
+			user_settings
+		SET
+			digest_sent_at=$1
+		WHERE
+			tgid=$2;`)
+}
+
+func UpdateWebhookActive(db *sql.DB) (*sql.Stmt, error) {
+
+	return db.Prepare(`
+		UPDATE 
+			webhooks
+		SET
+			active=$1
+		WHERE
+			id=$2;`)
+}
+
+func UpdateUserRole(db *sql.DB) (*sql.Stmt, error) {
+
+	return db.Prepare(`
+		UPDATE 
+			users
+		SET
+			role=$1,
+			admin=$2,
+			changed_at=$3,
+			changed_by=$4
+		WHERE
+			tgid=$5;`)
+}
+
+func UpdateTeamMemberLead(db *sql.DB) (*sql.Stmt, error) {
+
+	return db.Prepare(`
+		UPDATE 
+			team_members
+		SET
+			lead=$1
+		WHERE
+			id=$2;`)
+}
+
+func UpdateUserStatusComment(db *sql.DB) (*sql.Stmt, error) {
+
+	return db.Prepare(`
+		UPDATE 
+			users
+		SET
+			status=$1,
+			comment=$2,
+			changed_at=$3,
+			changed_by=$4
+		WHERE
+			tgid=$5;`)
+}
+
+func UpdateInviteUse(db *sql.DB) (*sql.Stmt, error) {
+
+	return db.Prepare(`
+		UPDATE 
+			invites
+		SET
+			uses=uses+1
+		WHERE
+			id=$1
+			AND revoked=0
+			AND uses<max_uses;`)
+}
+
+func UpdateInviteRevoked(db *sql.DB) (*sql.Stmt, error) {
+
+	return db.Prepare(`
+		UPDATE 
+			invites
+		SET
+			revoked=1
+		WHERE
+			id=$1;`)
+}
@@ -6,11 +6,11 @@ import (
 )
 
 func ScanUser(rows *sql.Rows, u *models.DbUsers) error {
-	return rows.Scan(&u.ID, &u.TelegramID, &u.FirstName, &u.LastName, &u.Admin, &u.Status, &u.ChangedBy, &u.ChangedAt, &u.Comment, &u.Userpic)
+	return rows.Scan(&u.ID, &u.TelegramID, &u.FirstName, &u.LastName, &u.Admin, &u.Status, &u.ChangedBy, &u.ChangedAt, &u.Comment, &u.Userpic, &u.Username, &u.Role)
 }
 
 func ScanTask(rows *sql.Rows, t *models.DbTasks) error {
-	return rows.Scan(&t.ID, &t.FromUser, &t.ToUser, &t.Status, &t.ChangedAt, &t.ChangedBy, &t.Title, &t.Description, &t.Comment, &t.CommentedAt, &t.CommentedBy, &t.Images, &t.Documents)
+	return rows.Scan(&t.ID, &t.FromUser, &t.ToUser, &t.Status, &t.ChangedAt, &t.ChangedBy, &t.Title, &t.Description, &t.Comment, &t.CommentedAt, &t.CommentedBy, &t.Images, &t.Documents, &t.DueDate, &t.Priority)
 }
 
 func ScanHistory(rows *sql.Rows, h *models.DbHistory) error {
@@ -29,3 +29,54 @@ func ScanSession(rows *sql.Rows, s *models.DbSessions) error {
 	return rows.Scan(&s.ID, &s.UUID, &s.TelegramID, &s.StartedAt, &s.LastActivity, &s.IP, &s.UserAgent)
 }
 
+func ScanTaskMessage(rows *sql.Rows, m *models.DbTaskMessages) error {
+	return rows.Scan(&m.ID, &m.TaskID, &m.ChatID, &m.MessageID)
+}
+
+func ScanUserSettings(rows *sql.Rows, s *models.DbUserSettings) error {
+	return rows.Scan(&s.ID, &s.TelegramID, &s.Timezone, &s.Digest, &s.DigestTime, &s.DigestWeekday, &s.DigestSentAt, &s.NotifyNewTask, &s.NotifyStatus, &s.NotifyComment, &s.NotifyMention, &s.QuietFrom, &s.QuietTo, &s.Email, &s.WebhookURL)
+}
+
+func ScanNotificationChannel(rows *sql.Rows, nc *models.DbNotificationChannels) error {
+	return rows.Scan(&nc.ID, &nc.TelegramID, &nc.Event, &nc.Channel)
+}
+
+func ScanNotificationLog(rows *sql.Rows, nl *models.DbNotificationLog) error {
+	return rows.Scan(&nl.ID, &nl.TelegramID, &nl.Channel, &nl.Event, &nl.TaskID, &nl.Status, &nl.Attempts, &nl.Error, &nl.CreatedAt)
+}
+
+func ScanTaskMute(rows *sql.Rows, m *models.DbTaskMutes) error {
+	return rows.Scan(&m.ID, &m.TelegramID, &m.TaskID)
+}
+
+func ScanTeamMute(rows *sql.Rows, m *models.DbTeamMutes) error {
+	return rows.Scan(&m.ID, &m.TelegramID, &m.TeamID)
+}
+
+func ScanWebhook(rows *sql.Rows, wh *models.DbWebhooks) error {
+	return rows.Scan(&wh.ID, &wh.URL, &wh.Secret, &wh.Events, &wh.Active, &wh.CreatedBy, &wh.CreatedAt)
+}
+
+func ScanWebhookDelivery(rows *sql.Rows, wd *models.DbWebhookDeliveries) error {
+	return rows.Scan(&wd.ID, &wd.WebhookID, &wd.Event, &wd.Payload, &wd.Status, &wd.Attempts, &wd.ResponseCode, &wd.Error, &wd.CreatedAt)
+}
+
+func ScanAPIToken(rows *sql.Rows, t *models.DbAPITokens) error {
+	return rows.Scan(&t.ID, &t.TelegramID, &t.Token, &t.CreatedAt)
+}
+
+func ScanTeam(rows *sql.Rows, t *models.DbTeams) error {
+	return rows.Scan(&t.ID, &t.Name, &t.CreatedBy, &t.CreatedAt)
+}
+
+func ScanTeamMember(rows *sql.Rows, m *models.DbTeamMembers) error {
+	return rows.Scan(&m.ID, &m.TeamID, &m.TelegramID, &m.Lead)
+}
+
+func ScanUserHistory(rows *sql.Rows, h *models.DbUserHistory) error {
+	return rows.Scan(&h.ID, &h.UserID, &h.Status, &h.Role, &h.Comment, &h.ChangedBy, &h.ChangedAt)
+}
+
+func ScanInvite(rows *sql.Rows, i *models.DbInvites) error {
+	return rows.Scan(&i.ID, &i.Code, &i.Role, &i.TeamID, &i.MaxUses, &i.Uses, &i.ExpiresAt, &i.CreatedBy, &i.CreatedAt, &i.Revoked)
+}
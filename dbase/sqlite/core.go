@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"github.com/slevchyk/taskeram/models"
 	"log"
+	"os"
 	"strconv"
 	"time"
 )
@@ -30,11 +31,16 @@ func InitDB(db *sql.DB, cfg models.Config) {
 			'changed_at' DATE,
 			'changed_by' INTEGER DEFAULT 0,
 			'comment' TEXT DEFAULT '',
-			'userpic' TEXT DEFAULT '');`)
+			'userpic' TEXT DEFAULT '',
+			'username' TEXT DEFAULT '',
+			'role' TEXT DEFAULT 'member');`)
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	addColumn(db, "users", "username", "TEXT DEFAULT ''")
+	addColumn(db, "users", "role", "TEXT DEFAULT ''")
+
 	_, err = db.Exec(`
 		CREATE TABLE IF NOT EXISTS 'user_history'(
 			'id' INTEGER PRIMARY KEY AUTOINCREMENT,
@@ -42,15 +48,34 @@ func InitDB(db *sql.DB, cfg models.Config) {
 			'status' TEXT,
 			'changed_by' INTEGER DEFAULT 0,
 			'changed_at' DATE,
-			'admin' INTEGER);`)
+			'admin' INTEGER,
+			'role' TEXT DEFAULT '',
+			'comment' TEXT DEFAULT '');`)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	addColumn(db, "user_history", "role", "TEXT DEFAULT ''")
+	addColumn(db, "user_history", "comment", "TEXT DEFAULT ''")
+
+	//попередня версія тригера не зберігала userid, тому перестворюємо його
+	_, err = db.Exec(`
+		DROP TRIGGER IF EXISTS update_user_history;`)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	//користувачі з попередніх версій отримують роль відповідно до прапорця admin, поки тригер історії вимкнено
+	_, err = db.Exec(`
+		UPDATE users SET role=CASE WHEN admin=1 THEN ? ELSE ? END WHERE role='' OR role IS NULL;`, models.RoleAdmin, models.RoleMember)
 	if err != nil {
 		log.Fatal(err)
 	}
 
 	_, err = db.Exec(`
-		CREATE TRIGGER IF NOT EXISTS update_user_history AFTER UPDATE ON users WHEN (old.status <> new.status)
+		CREATE TRIGGER IF NOT EXISTS update_user_history AFTER UPDATE ON users WHEN (old.status <> new.status OR old.role <> new.role)
 		BEGIN
-			INSERT INTO user_history(status, changed_by, changed_at, admin) values (new.status, new.changed_by, new.changed_at, new.admin);
+			INSERT INTO user_history(userid, status, changed_by, changed_at, admin, role, comment) values (new.id, new.status, new.changed_by, new.changed_at, new.admin, new.role, new.comment);
 		END;`)
 	if err != nil {
 		log.Fatal(err)
@@ -70,11 +95,16 @@ func InitDB(db *sql.DB, cfg models.Config) {
 			'commented_at' DATE,
 			'commented_by' INTEGER,
 			'images' TEXT DEFAULT '',
-			'documents' TEXT DEFAULT '');`)
+			'documents' TEXT DEFAULT '',
+			'due_date' DATE,
+			'priority' TEXT DEFAULT '');`)
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	addColumn(db, "tasks", "due_date", "DATE")
+	addColumn(db, "tasks", "priority", "TEXT DEFAULT ''")
+
 	_, err = db.Exec(`
 		CREATE TABLE IF NOT EXISTS 'task_history'(
 			'id' INTEGER PRIMARY KEY AUTOINCREMENT,
@@ -125,6 +155,179 @@ func InitDB(db *sql.DB, cfg models.Config) {
 		log.Fatal(err)
 	}
 
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS 'task_messages'(
+			'id' INTEGER PRIMARY KEY AUTOINCREMENT,
+			'taskid' INTEGER REFERENCES tasks,
+			'chatid' INTEGER NOT NULL,
+			'msgid' INTEGER NOT NULL);`)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = db.Exec(`
+		CREATE INDEX IF NOT EXISTS task_messages_chat_msg ON task_messages(chatid, msgid);`)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS 'user_settings'(
+			'id' INTEGER PRIMARY KEY AUTOINCREMENT,
+			'tgid' INTEGER NOT NULL,
+			'timezone' TEXT DEFAULT 'UTC',
+			'digest' TEXT DEFAULT '',
+			'digest_time' TEXT DEFAULT '09:00',
+			'digest_weekday' INTEGER DEFAULT 1,
+			'digest_sent_at' DATE,
+			'notify_new_task' INTEGER DEFAULT 1,
+			'notify_status' INTEGER DEFAULT 1,
+			'notify_comment' INTEGER DEFAULT 1,
+			'notify_mention' INTEGER DEFAULT 1,
+			'quiet_from' TEXT DEFAULT '',
+			'quiet_to' TEXT DEFAULT '',
+			'email' TEXT DEFAULT '',
+			'webhook_url' TEXT DEFAULT '');`)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	addColumn(db, "user_settings", "notify_new_task", "INTEGER DEFAULT 1")
+	addColumn(db, "user_settings", "notify_status", "INTEGER DEFAULT 1")
+	addColumn(db, "user_settings", "notify_comment", "INTEGER DEFAULT 1")
+	addColumn(db, "user_settings", "notify_mention", "INTEGER DEFAULT 1")
+	addColumn(db, "user_settings", "quiet_from", "TEXT DEFAULT ''")
+	addColumn(db, "user_settings", "quiet_to", "TEXT DEFAULT ''")
+	addColumn(db, "user_settings", "email", "TEXT DEFAULT ''")
+	addColumn(db, "user_settings", "webhook_url", "TEXT DEFAULT ''")
+
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS 'notification_channels'(
+			'id' INTEGER PRIMARY KEY AUTOINCREMENT,
+			'tgid' INTEGER NOT NULL,
+			'event' TEXT NOT NULL,
+			'channel' TEXT NOT NULL);`)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS 'notification_log'(
+			'id' INTEGER PRIMARY KEY AUTOINCREMENT,
+			'tgid' INTEGER NOT NULL,
+			'channel' TEXT NOT NULL,
+			'event' TEXT NOT NULL,
+			'taskid' INTEGER DEFAULT 0,
+			'status' TEXT NOT NULL,
+			'attempts' INTEGER DEFAULT 0,
+			'error' TEXT DEFAULT '',
+			'created_at' DATE);`)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS 'task_mutes'(
+			'id' INTEGER PRIMARY KEY AUTOINCREMENT,
+			'tgid' INTEGER NOT NULL,
+			'taskid' INTEGER REFERENCES tasks(id));`)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS 'webhooks'(
+			'id' INTEGER PRIMARY KEY AUTOINCREMENT,
+			'url' TEXT NOT NULL,
+			'secret' TEXT NOT NULL,
+			'events' TEXT DEFAULT '',
+			'active' INTEGER DEFAULT 1,
+			'created_by' INTEGER DEFAULT 0,
+			'created_at' DATE);`)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS 'webhook_deliveries'(
+			'id' INTEGER PRIMARY KEY AUTOINCREMENT,
+			'webhookid' INTEGER REFERENCES webhooks(id),
+			'event' TEXT NOT NULL,
+			'payload' TEXT DEFAULT '',
+			'status' TEXT NOT NULL,
+			'attempts' INTEGER DEFAULT 0,
+			'response_code' INTEGER DEFAULT 0,
+			'error' TEXT DEFAULT '',
+			'created_at' DATE);`)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS 'api_tokens'(
+			'id' INTEGER PRIMARY KEY AUTOINCREMENT,
+			'tgid' INTEGER NOT NULL,
+			'token' TEXT NOT NULL,
+			'created_at' DATE);`)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS 'calendar_tokens'(
+			'id' INTEGER PRIMARY KEY AUTOINCREMENT,
+			'tgid' INTEGER NOT NULL,
+			'token' TEXT NOT NULL,
+			'created_at' DATE);`)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS 'teams'(
+			'id' INTEGER PRIMARY KEY AUTOINCREMENT,
+			'name' TEXT NOT NULL,
+			'created_by' INTEGER DEFAULT 0,
+			'created_at' DATE);`)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS 'team_members'(
+			'id' INTEGER PRIMARY KEY AUTOINCREMENT,
+			'teamid' INTEGER REFERENCES teams(id),
+			'tgid' INTEGER NOT NULL,
+			'lead' INTEGER DEFAULT 0);`)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS 'team_mutes'(
+			'id' INTEGER PRIMARY KEY AUTOINCREMENT,
+			'tgid' INTEGER NOT NULL,
+			'teamid' INTEGER REFERENCES teams(id));`)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS 'invites'(
+			'id' INTEGER PRIMARY KEY AUTOINCREMENT,
+			'code' TEXT NOT NULL,
+			'role' TEXT DEFAULT '',
+			'teamid' INTEGER DEFAULT 0,
+			'max_uses' INTEGER DEFAULT 1,
+			'uses' INTEGER DEFAULT 0,
+			'expires_at' DATE,
+			'created_by' INTEGER DEFAULT 0,
+			'created_at' DATE,
+			'revoked' INTEGER DEFAULT 0);`)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS 'sessions' (
 				'id' INTEGER PRIMARY KEY AUTOINCREMENT,
 				'uuid' TEXT NOT NULL,
@@ -170,14 +373,137 @@ func InitDB(db *sql.DB, cfg models.Config) {
 
 	if !rows.Next() {
 		stmt, err := db.Prepare(`
-			INSERT into 'users'(tgid, first_name, last_name, admin, status, changed_at) VALUES (?,?,?,?,?,?)`)
+			INSERT into 'users'(tgid, first_name, last_name, admin, status, changed_at, role) VALUES (?,?,?,?,?,?,?)`)
 		if err != nil {
 			log.Fatal(err)
 		}
 
-		_, err = stmt.Exec(tgID, "admin", "admin", 1, models.UserApprowed, time.Now().UTC())
+		_, err = stmt.Exec(tgID, "admin", "admin", 1, models.UserApprowed, time.Now().UTC(), models.RoleAdmin)
 		if err != nil {
 			log.Fatal(err)
 		}
 	}
 }
+
+//addColumn adds a column to a table created by an older version of Taskeram.
+//sqlite has no "ADD COLUMN IF NOT EXISTS", so we check table info first
+func addColumn(db *sql.DB, table string, column string, definition string) {
+
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info('%v')", table))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var (
+		cid       int
+		name      string
+		colType   string
+		notNull   int
+		dfltValue sql.NullString
+		pk        int
+	)
+
+	for rows.Next() {
+		err := rows.Scan(&cid, &name, &colType, &notNull, &dfltValue, &pk)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if name == column {
+			rows.Close()
+			return
+		}
+	}
+	rows.Close()
+
+	_, err = db.Exec(fmt.Sprintf("ALTER TABLE '%v' ADD COLUMN '%v' %v;", table, column, definition))
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+//Backup writes a consistent copy of the database to path while it is in use and puts userpics into it. path must not exist
+func Backup(db *sql.DB, path string, userpics map[string][]byte) error {
+
+	//the snapshot has API tokens and sessions, so it is readable by the owner only.
+	//VACUUM INTO creates the file with default permissions, but accepts an empty one
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	f.Close()
+
+	_, err = db.Exec(`VACUUM INTO ?`, path)
+	if err != nil {
+		os.Remove(path)
+		return err
+	}
+
+	snapshot, err := Open(path)
+	if err != nil {
+		return err
+	}
+	defer snapshot.Close()
+
+	_, err = snapshot.Exec(`
+		CREATE TABLE 'userpics'(
+			'name' TEXT PRIMARY KEY,
+			'data' BLOB);`)
+	if err != nil {
+		return err
+	}
+
+	for name, data := range userpics {
+		_, err := snapshot.Exec(`INSERT INTO userpics(name, data) VALUES (?, ?)`, name, data)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//Open opens sqlite database file, e.g. a snapshot made by Backup
+func Open(path string) (*sql.DB, error) {
+
+	return sql.Open("sqlite3", path)
+}
+
+//HasTable tells if the table exists, snapshots made by older versions don't have newer tables
+func HasTable(db *sql.DB, name string) (bool, error) {
+
+	var n int
+	err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name=?`, name).Scan(&n)
+
+	return n != 0, err
+}
+
+//ReadUserpics returns userpics kept in the snapshot made by Backup
+func ReadUserpics(db *sql.DB) (map[string][]byte, error) {
+
+	xs := make(map[string][]byte)
+
+	ok, err := HasTable(db, "userpics")
+	if err != nil || !ok {
+		return xs, err
+	}
+
+	rows, err := db.Query(`SELECT name, data FROM userpics`)
+	if err != nil {
+		return xs, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		var data []byte
+
+		err := rows.Scan(&name, &data)
+		if err != nil {
+			return xs, err
+		}
+		xs[name] = data
+	}
+
+	return xs, rows.Err()
+}
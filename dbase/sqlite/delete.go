@@ -19,3 +19,133 @@ func DeleteSessionByUUID(db *sql.DB) (*sql.Stmt, error)  {
 		WHERE
 			uuid=?;`)
 }
+
+func DeleteTaskMute(db *sql.DB) (*sql.Stmt, error)  {
+
+	return db.Prepare(`
+		DELETE 
+		FROM task_mutes
+		WHERE
+			tgid=?
+			AND taskid=?;`)
+}
+
+func DeleteTeamMute(db *sql.DB) (*sql.Stmt, error)  {
+
+	return db.Prepare(`
+		DELETE 
+		FROM team_mutes
+		WHERE
+			tgid=?
+			AND teamid=?;`)
+}
+
+func DeleteTeamMutesByTeamID(db *sql.DB) (*sql.Stmt, error)  {
+
+	return db.Prepare(`
+		DELETE 
+		FROM team_mutes
+		WHERE
+			teamid=?;`)
+}
+
+func DeleteNotificationChannels(db *sql.DB) (*sql.Stmt, error)  {
+
+	return db.Prepare(`
+		DELETE 
+		FROM notification_channels
+		WHERE
+			tgid=?
+			AND event=?;`)
+}
+
+func DeleteWebhook(db *sql.DB) (*sql.Stmt, error)  {
+
+	return db.Prepare(`
+		DELETE 
+		FROM webhooks
+		WHERE
+			id=?;`)
+}
+
+func DeleteWebhookDeliveries(db *sql.DB) (*sql.Stmt, error)  {
+
+	return db.Prepare(`
+		DELETE 
+		FROM webhook_deliveries
+		WHERE
+			webhookid=?;`)
+}
+
+func DeleteAPITokensByTelegramID(db *sql.DB) (*sql.Stmt, error)  {
+
+	return db.Prepare(`
+		DELETE 
+		FROM api_tokens
+		WHERE
+			tgid=?;`)
+}
+
+func DeleteCalendarTokensByTelegramID(db *sql.DB) (*sql.Stmt, error)  {
+
+	return db.Prepare(`
+		DELETE 
+		FROM calendar_tokens
+		WHERE
+			tgid=?;`)
+}
+
+func DeleteTeam(db *sql.DB) (*sql.Stmt, error)  {
+
+	return db.Prepare(`
+		DELETE 
+		FROM teams
+		WHERE
+			id=?;`)
+}
+
+func DeleteTeamMembers(db *sql.DB) (*sql.Stmt, error)  {
+
+	return db.Prepare(`
+		DELETE 
+		FROM team_members
+		WHERE
+			teamid=?;`)
+}
+
+func DeleteTeamMember(db *sql.DB) (*sql.Stmt, error)  {
+
+	return db.Prepare(`
+		DELETE 
+		FROM team_members
+		WHERE
+			id=?;`)
+}
+
+func DeleteSessionByID(db *sql.DB) (*sql.Stmt, error)  {
+
+	return db.Prepare(`
+		DELETE 
+		FROM sessions
+		WHERE
+			id=?;`)
+}
+
+func DeleteSessionsByTelegramID(db *sql.DB) (*sql.Stmt, error)  {
+
+	return db.Prepare(`
+		DELETE 
+		FROM sessions
+		WHERE
+			tgid=?;`)
+}
+
+func DeleteExpiredSessions(db *sql.DB) (*sql.Stmt, error)  {
+
+	return db.Prepare(`
+		DELETE 
+		FROM sessions
+		WHERE
+			last_activity<?
+			OR started_at<?;`)
+}
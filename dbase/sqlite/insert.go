@@ -15,8 +15,10 @@ func InsertUser(db *sql.DB) (*sql.Stmt, error)  {
 				changed_at,
 				changed_by,
 				comment,
-				userpic)
-		VALUES (?, ?, ?, ?, ?, ?. ?, ?, ?);`)
+				userpic,
+				username,
+				role)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`)
 }
 
 func InsertTask(db *sql.DB) (*sql.Stmt, error) {
@@ -35,8 +37,10 @@ func InsertTask(db *sql.DB) (*sql.Stmt, error) {
 				commented_at,
 				commented_by,
 				images,
-				documents)
-		VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`)
+				documents,
+				due_date,
+				priority)
+		VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`)
 }
 
 func InsertAuth(db *sql.DB) (*sql.Stmt, error) {
@@ -63,4 +67,187 @@ func InsertSession(db *sql.DB) (*sql.Stmt, error) {
 				ip,
 				user_agent)
 		VALUES (?, ?, ?, ?, ?, ?);`)
-}
\ No newline at end of file
+}
+
+func InsertTaskMessage(db *sql.DB) (*sql.Stmt, error) {
+
+	return db.Prepare(`
+		INSERT INTO
+			'task_messages' (
+				taskid,
+				chatid,
+				msgid)
+		VALUES (?, ?, ?);`)
+}
+
+func InsertUserSettings(db *sql.DB) (*sql.Stmt, error) {
+
+	return db.Prepare(`
+		INSERT INTO
+			'user_settings' (
+				tgid,
+				timezone,
+				digest,
+				digest_time,
+				digest_weekday,
+				digest_sent_at,
+				notify_new_task,
+				notify_status,
+				notify_comment,
+				notify_mention,
+				quiet_from,
+				quiet_to,
+				email,
+				webhook_url)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`)
+}
+
+func InsertTaskMute(db *sql.DB) (*sql.Stmt, error) {
+
+	return db.Prepare(`
+		INSERT INTO
+			'task_mutes' (
+				tgid,
+				taskid)
+		VALUES (?, ?);`)
+}
+
+func InsertTeamMute(db *sql.DB) (*sql.Stmt, error) {
+
+	return db.Prepare(`
+		INSERT INTO
+			'team_mutes' (
+				tgid,
+				teamid)
+		VALUES (?, ?);`)
+}
+
+func InsertNotificationChannel(db *sql.DB) (*sql.Stmt, error) {
+
+	return db.Prepare(`
+		INSERT INTO
+			'notification_channels' (
+				tgid,
+				event,
+				channel)
+		VALUES (?, ?, ?);`)
+}
+
+func InsertNotificationLog(db *sql.DB) (*sql.Stmt, error) {
+
+	return db.Prepare(`
+		INSERT INTO
+			'notification_log' (
+				tgid,
+				channel,
+				event,
+				taskid,
+				status,
+				attempts,
+				error,
+				created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?);`)
+}
+
+func InsertWebhook(db *sql.DB) (*sql.Stmt, error) {
+
+	return db.Prepare(`
+		INSERT INTO
+			'webhooks' (
+				url,
+				secret,
+				events,
+				active,
+				created_by,
+				created_at)
+		VALUES (?, ?, ?, ?, ?, ?);`)
+}
+
+func InsertWebhookDelivery(db *sql.DB) (*sql.Stmt, error) {
+
+	return db.Prepare(`
+		INSERT INTO
+			'webhook_deliveries' (
+				webhookid,
+				event,
+				payload,
+				status,
+				attempts,
+				response_code,
+				error,
+				created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?);`)
+}
+
+func InsertAPIToken(db *sql.DB) (*sql.Stmt, error) {
+
+	return db.Prepare(`
+		INSERT INTO
+			'api_tokens' (
+				tgid,
+				token,
+				created_at)
+		VALUES (?, ?, ?);`)
+}
+
+func InsertCalendarToken(db *sql.DB) (*sql.Stmt, error) {
+
+	return db.Prepare(`
+		INSERT INTO
+			'calendar_tokens' (
+				tgid,
+				token,
+				created_at)
+		VALUES (?, ?, ?);`)
+}
+
+func InsertTeam(db *sql.DB) (*sql.Stmt, error) {
+
+	return db.Prepare(`
+		INSERT INTO
+			'teams' (
+				name,
+				created_by,
+				created_at)
+		VALUES (?, ?, ?);`)
+}
+
+func InsertTeamMember(db *sql.DB) (*sql.Stmt, error) {
+
+	return db.Prepare(`
+		INSERT INTO
+			'team_members' (
+				teamid,
+				tgid,
+				lead)
+		VALUES (?, ?, ?);`)
+}
+
+func InsertInvite(db *sql.DB) (*sql.Stmt, error) {
+
+	return db.Prepare(`
+		INSERT INTO
+			'invites' (
+				code,
+				role,
+				teamid,
+				max_uses,
+				uses,
+				expires_at,
+				created_by,
+				created_at,
+				revoked)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?);`)
+}
+
+func InsertTaskComment(db *sql.DB) (*sql.Stmt, error) {
+
+	return db.Prepare(`
+		INSERT INTO
+			'task_comments' (
+				taskid,
+				tgid,
+				date,
+				comment)
+		VALUES (?, ?, ?, ?);`)
+}
@@ -30,6 +30,18 @@ func UpdateUserData(db *sql.DB) (*sql.Stmt, error) {
 			`)
 }
 
+func UpdateUserUsername(db *sql.DB) (*sql.Stmt, error) {
+
+	return db.Prepare(`
+			UPDATE
+				users
+			SET
+				username=?
+			WHERE
+				tgid=?
+			`)
+}
+
 func UpdateTaskStatus(db *sql.DB) (*sql.Stmt, error) {
 
 	return db.Prepare(`
@@ -76,4 +88,111 @@ func UpdateSessionLastActivityByUuid(db *sql.DB) (*sql.Stmt, error) {
 			last_activity=?
 		WHERE
 			uuid=?;`)
-}
\ No newline at end of file
+}
+
+func UpdateUserSettings(db *sql.DB) (*sql.Stmt, error) {
+
+	return db.Prepare(`
+		UPDATE 
+			user_settings
+		SET
+			timezone=?,
+			digest=?,
+			digest_time=?,
+			digest_weekday=?,
+			notify_new_task=?,
+			notify_status=?,
+			notify_comment=?,
+			notify_mention=?,
+			quiet_from=?,
+			quiet_to=?,
+			email=?,
+			webhook_url=?
+		WHERE
+			tgid=?;`)
+}
+
+func UpdateUserSettingsDigestSentAt(db *sql.DB) (*sql.Stmt, error) {
+
+	return db.Prepare(`
+		UPDATE 
+			user_settings
+		SET
+			digest_sent_at=?
+		WHERE
+			tgid=?;`)
+}
+
+func UpdateWebhookActive(db *sql.DB) (*sql.Stmt, error) {
+
+	return db.Prepare(`
+		UPDATE 
+			webhooks
+		SET
+			active=?
+		WHERE
+			id=?;`)
+}
+
+func UpdateUserRole(db *sql.DB) (*sql.Stmt, error) {
+
+	return db.Prepare(`
+		UPDATE 
+			users
+		SET
+			role=?,
+			admin=?,
+			changed_at=?,
+			changed_by=?
+		WHERE
+			tgid=?;`)
+}
+
+func UpdateTeamMemberLead(db *sql.DB) (*sql.Stmt, error) {
+
+	return db.Prepare(`
+		UPDATE 
+			team_members
+		SET
+			lead=?
+		WHERE
+			id=?;`)
+}
+
+func UpdateUserStatusComment(db *sql.DB) (*sql.Stmt, error) {
+
+	return db.Prepare(`
+		UPDATE 
+			users
+		SET
+			status=?,
+			comment=?,
+			changed_at=?,
+			changed_by=?
+		WHERE
+			tgid=?;`)
+}
+
+func UpdateInviteUse(db *sql.DB) (*sql.Stmt, error) {
+
+	return db.Prepare(`
+		UPDATE 
+			invites
+		SET
+			uses=uses+1
+		WHERE
+			id=?
+			AND revoked=0
+			AND uses<max_uses;`)
+}
+
+func UpdateInviteRevoked(db *sql.DB) (*sql.Stmt, error) {
+
+	return db.Prepare(`
+		UPDATE 
+			invites
+		SET
+			revoked=1
+		WHERE
+			id=?;`)
+}
@@ -17,6 +17,16 @@ func ExecUpdateUserData(stmt *sql.Stmt, u models.DbUsers) (sql.Result, error) {
 
 }
 
+func ExecUpdateUserUsername(stmt *sql.Stmt, u models.DbUsers) (sql.Result, error) {
+
+	return stmt.Exec(u.Username, u.TelegramID)
+}
+
+func ExecUpdateUserRole(stmt *sql.Stmt, u models.DbUsers) (sql.Result, error) {
+
+	return stmt.Exec(u.Role, u.Admin, u.ChangedAt, u.ChangedBy, u.TelegramID)
+}
+
 func ExecUpdateTaskStatus(stmt *sql.Stmt, t models.DbTasks) (sql.Result, error) {
 
 	return stmt.Exec(t.Status, t.ChangedAt, t.ChangedBy, t.ID)
@@ -37,4 +47,19 @@ func ExecUpdateSessionLastActivityByUuid(stmt *sql.Stmt, s models.DbSessions) (s
 
 	return stmt.Exec(s.LastActivity, s.UUID)
 
-}
\ No newline at end of file
+}
+
+func ExecUpdateUserSettings(stmt *sql.Stmt, s models.DbUserSettings) (sql.Result, error) {
+
+	return stmt.Exec(s.Timezone, s.Digest, s.DigestTime, s.DigestWeekday, s.NotifyNewTask, s.NotifyStatus, s.NotifyComment, s.NotifyMention, s.QuietFrom, s.QuietTo, s.Email, s.WebhookURL, s.TelegramID)
+}
+
+func ExecUpdateUserSettingsDigestSentAt(stmt *sql.Stmt, s models.DbUserSettings) (sql.Result, error) {
+
+	return stmt.Exec(s.DigestSentAt, s.TelegramID)
+}
+
+func ExecUpdateUserStatusComment(stmt *sql.Stmt, u models.DbUsers) (sql.Result, error) {
+
+	return stmt.Exec(u.Status, u.Comment, u.ChangedAt, u.ChangedBy, u.TelegramID)
+}
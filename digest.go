@@ -0,0 +1,257 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"html"
+	"log"
+	"time"
+
+	"github.com/slevchyk/taskeram/dbase"
+	"github.com/slevchyk/taskeram/models"
+)
+
+const (
+	digestTimeLayout = "15:04"
+	//defaultDigestTime is used when the digest is on but its time isn't set, the same as DB default
+	defaultDigestTime = "09:00"
+)
+
+//startDigestScheduler checks every minute whose digest time has come and sends it
+func startDigestScheduler() {
+
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		sendDigests(now)
+	}
+}
+
+func sendDigests(now time.Time) {
+
+	rows, err := dbase.SelectUserSettingsWithDigest(cfg)
+	if err != nil {
+		log.Println(fmt.Errorf("SelectUserSettingsWithDigest: %v", err))
+		return
+	}
+
+	var s models.DbUserSettings
+	var xs []models.DbUserSettings
+
+	for rows.Next() {
+		err := dbase.ScanUserSettings(rows, &s)
+		if err != nil {
+			log.Println(err)
+		} else {
+			xs = append(xs, s)
+		}
+	}
+	rows.Close()
+
+	for _, s := range xs {
+		if !isDigestDue(s, now) {
+			continue
+		}
+
+		u := dbase.GetUserByTelegramID(cfg, s.TelegramID)
+		if u.ID == 0 {
+			continue
+		}
+
+		subject := "Taskeram daily digest"
+		if s.Digest == models.DigestWeekly {
+			subject = "Taskeram weekly digest"
+		}
+
+		if !notifyUser(u.TelegramID, models.NotifyDigest, 0, subject, buildDigest(u, s, now)) {
+			continue
+		}
+
+		s.DigestSentAt = models.NullTime{Time: now.UTC(), Valid: true}
+
+		stmt, err := dbase.UpdateUserSettingsDigestSentAt(cfg)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+
+		_, err = dbase.ExecUpdateUserSettingsDigestSentAt(stmt, s)
+		if err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+//userLocation returns user time zone, UTC if it's not set or wrong
+func userLocation(s models.DbUserSettings) *time.Location {
+
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+
+	return loc
+}
+
+//isDigestDue reports whether the scheduled digest time has already come today in user time zone
+//and the digest wasn't sent since then
+func isDigestDue(s models.DbUserSettings, now time.Time) bool {
+
+	if s.Digest != models.DigestDaily && s.Digest != models.DigestWeekly {
+		return false
+	}
+
+	digestTime := s.DigestTime
+	if digestTime == "" {
+		digestTime = defaultDigestTime
+	}
+
+	at, err := time.Parse(digestTimeLayout, digestTime)
+	if err != nil {
+		return false
+	}
+
+	loc := userLocation(s)
+	local := now.In(loc)
+
+	if s.Digest == models.DigestWeekly && int(local.Weekday()) != s.DigestWeekday {
+		return false
+	}
+
+	scheduled := time.Date(local.Year(), local.Month(), local.Day(), at.Hour(), at.Minute(), 0, 0, loc)
+	if local.Before(scheduled) {
+		return false
+	}
+
+	return !s.DigestSentAt.Valid || s.DigestSentAt.Time.Before(scheduled)
+}
+
+func buildDigest(u models.DbUsers, s models.DbUserSettings, now time.Time) string {
+
+	loc := userLocation(s)
+	local := now.In(loc)
+	dayStart := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+
+	title := "Daily digest"
+	period := 24 * time.Hour
+	dueTitle := "Due today"
+	completedTitle := "Completed yesterday, waiting for Close or Reject"
+
+	if s.Digest == models.DigestWeekly {
+		title = "Weekly digest"
+		period = 7 * 24 * time.Hour
+		dueTitle = "Due this week"
+		completedTitle = "Completed last week, waiting for Close or Reject"
+	}
+
+	var due, overdue, completed []models.DbTasks
+
+	for _, status := range []string{models.TaskStatusNew, models.TaskStatusStarted, models.TaskStatusRejected} {
+		for _, t := range selectDigestTasks(dbase.SelectInboxTasks, u.TelegramID, status) {
+			if !t.DueDate.Valid {
+				continue
+			}
+
+			//due date is a calendar date, so we compare it in user time zone
+			dueDay := time.Date(t.DueDate.Time.Year(), t.DueDate.Time.Month(), t.DueDate.Time.Day(), 0, 0, 0, 0, loc)
+
+			if dueDay.Before(dayStart) {
+				overdue = append(overdue, t)
+			} else if dueDay.Before(dayStart.Add(period)) {
+				due = append(due, t)
+			}
+		}
+	}
+
+	for _, t := range selectDigestTasks(dbase.SelectSentTasks, u.TelegramID, models.TaskStatusCompleted) {
+		if !t.ChangedAt.Time.Before(dayStart.Add(-period)) && t.ChangedAt.Time.Before(dayStart) {
+			completed = append(completed, t)
+		}
+	}
+
+	since := dayStart.Add(-period)
+	if s.DigestSentAt.Valid {
+		since = s.DigestSentAt.Time
+	}
+
+	var comments []models.DbComment
+	var record models.DbComment
+
+	rows, err := dbase.SelectCommentsSince(cfg, u.TelegramID, since.UTC())
+	if err != nil {
+		log.Println(fmt.Errorf("SelectCommentsSince: %v", err))
+	} else {
+		for rows.Next() {
+			err := dbase.ScanComments(rows, &record)
+			if err != nil {
+				log.Println(err)
+			} else {
+				comments = append(comments, record)
+			}
+		}
+		rows.Close()
+	}
+
+	reply := fmt.Sprintf("<b>%v</b> for %v\n", title, dayStart.Format(models.DueDateLayout))
+
+	if len(due)+len(overdue)+len(completed)+len(comments) == 0 {
+		reply += "\nNothing to report, have a nice day!"
+		return reply
+	}
+
+	reply += digestSection(dueTitle, due, true)
+	reply += digestSection("Overdue", overdue, true)
+	reply += digestSection(completedTitle, completed, false)
+
+	if len(comments) > 0 {
+		reply += fmt.Sprintf("\n<b>New comments (%v):</b>\n", len(comments))
+		for _, val := range comments {
+			reply += fmt.Sprintf("#%v %v - <a href=\"tg://user?id=%v\">%v %v</a>: %v\n", val.CDb.TaskID, html.EscapeString(val.TDb.Title), val.UDb.TelegramID, html.EscapeString(val.UDb.FirstName), html.EscapeString(val.UDb.LastName), mentionsToBotHTML(val.CDb.Comment, nil))
+		}
+	}
+
+	return reply
+}
+
+func selectDigestTasks(selectTasks func(cfg models.Config, tgid int, status string) (*sql.Rows, error), tgid int, status string) []models.DbTasks {
+
+	var t models.DbTasks
+	var xs []models.DbTasks
+
+	rows, err := selectTasks(cfg, tgid, status)
+	if err != nil {
+		log.Println(fmt.Errorf("select %v tasks for digest: %v", status, err))
+		return xs
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		err := dbase.ScanTask(rows, &t)
+		if err != nil {
+			log.Println(err)
+		} else {
+			xs = append(xs, t)
+		}
+	}
+
+	return xs
+}
+
+func digestSection(title string, xs []models.DbTasks, withDueDate bool) string {
+
+	if len(xs) == 0 {
+		return ""
+	}
+
+	section := fmt.Sprintf("\n<b>%v (%v):</b>\n", title, len(xs))
+	for _, t := range xs {
+		if withDueDate {
+			section += fmt.Sprintf("#%v %v <i>(due %v)</i>\n", t.ID, html.EscapeString(t.Title), t.DueDate.Time.Format(models.DueDateLayout))
+		} else {
+			section += fmt.Sprintf("#%v %v\n", t.ID, html.EscapeString(t.Title))
+		}
+	}
+
+	return section
+}
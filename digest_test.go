@@ -0,0 +1,97 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/slevchyk/taskeram/dbase"
+	"github.com/slevchyk/taskeram/models"
+)
+
+func TestIsDigestDue(t *testing.T) {
+
+	now := time.Date(2020, 3, 2, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		s    models.DbUserSettings
+		due  bool
+	}{
+		{"off", models.DbUserSettings{Digest: models.DigestOff, DigestTime: "09:00"}, false},
+		{"daily", models.DbUserSettings{Digest: models.DigestDaily, DigestTime: "09:00"}, true},
+		{"later today", models.DbUserSettings{Digest: models.DigestDaily, DigestTime: "11:00"}, false},
+		{"without time", models.DbUserSettings{Digest: models.DigestDaily}, true},
+		{"sent today", models.DbUserSettings{Digest: models.DigestDaily, DigestTime: "09:00", DigestSentAt: models.NullTime{Time: now.Add(-time.Hour / 2), Valid: true}}, false},
+		{"weekly on other day", models.DbUserSettings{Digest: models.DigestWeekly, DigestTime: "09:00", DigestWeekday: int(time.Sunday)}, false},
+		{"weekly", models.DbUserSettings{Digest: models.DigestWeekly, DigestTime: "09:00", DigestWeekday: int(time.Monday)}, true},
+	}
+
+	for _, tt := range tests {
+		if due := isDigestDue(tt.s, now); due != tt.due {
+			t.Errorf("%v: due %v, want %v", tt.name, due, tt.due)
+		}
+	}
+}
+
+func TestDigestSectionEscapes(t *testing.T) {
+
+	section := digestSection("Due today", []models.DbTasks{{ID: 1, Title: "<b>fix</b> a & b"}}, false)
+
+	if !strings.Contains(section, "#1 &lt;b&gt;fix&lt;/b&gt; a &amp; b") {
+		t.Errorf("Task title isn't escaped: %q", section)
+	}
+}
+
+func TestBuildDigest(t *testing.T) {
+
+	setupTestDB(t)
+	addTestUser(t, 3, "Idle", models.RoleMember)
+
+	now := time.Date(2020, 3, 10, 10, 0, 0, 0, time.UTC)
+	day := func(d int) models.NullTime {
+		return models.NullTime{Time: time.Date(2020, 3, d, 0, 0, 0, 0, time.UTC), Valid: true}
+	}
+
+	for _, task := range []models.DbTasks{
+		{FromUser: testAdminID, ToUser: testAssigneeID, Status: models.TaskStatusNew, Title: "due today", DueDate: day(10)},
+		{FromUser: testAdminID, ToUser: testAssigneeID, Status: models.TaskStatusStarted, Title: "overdue", DueDate: day(5)},
+		{FromUser: testAdminID, ToUser: testAssigneeID, Status: models.TaskStatusNew, Title: "due this week", DueDate: day(15)},
+		{FromUser: testAdminID, ToUser: testAssigneeID, Status: models.TaskStatusClosed, Title: "closed", DueDate: day(10)},
+		{FromUser: testAssigneeID, ToUser: testAdminID, Status: models.TaskStatusCompleted, Title: "completed", ChangedAt: models.NullTime{Time: now.Add(-20 * time.Hour), Valid: true}},
+	} {
+		_, err := insertTask(task)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	u := dbase.GetUserByTelegramID(cfg, testAssigneeID)
+
+	tests := []struct {
+		digest string
+		want   []string
+	}{
+		{models.DigestDaily, []string{"<b>Daily digest</b> for 2020-03-10", "Due today (1):</b>\n#2 due today", "Overdue (1):</b>\n#3 overdue", "Completed yesterday, waiting for Close or Reject (1):</b>\n#6 completed"}},
+		{models.DigestWeekly, []string{"<b>Weekly digest</b>", "Due this week (2):", "#4 due this week", "Overdue (1):"}},
+	}
+
+	for _, tt := range tests {
+		digest := buildDigest(u, models.DbUserSettings{Digest: tt.digest}, now)
+
+		for _, want := range tt.want {
+			if !strings.Contains(digest, want) {
+				t.Errorf("%v digest doesn't contain %q:\n%v", tt.digest, want, digest)
+			}
+		}
+
+		if strings.Contains(digest, "closed") {
+			t.Errorf("%v digest contains closed Task:\n%v", tt.digest, digest)
+		}
+	}
+
+	digest := buildDigest(dbase.GetUserByTelegramID(cfg, 3), models.DbUserSettings{Digest: models.DigestDaily}, now)
+	if !strings.Contains(digest, "Nothing to report") {
+		t.Errorf("digest of the user without Tasks:\n%v", digest)
+	}
+}
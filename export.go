@@ -0,0 +1,413 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/slevchyk/taskeram/dbase"
+	"github.com/slevchyk/taskeram/models"
+	"github.com/slevchyk/taskeram/utils"
+	"gopkg.in/telegram-bot-api.v4"
+)
+
+const (
+	exportCSV  = "csv"
+	exportXLSX = "xlsx"
+)
+
+const exportHelp = `<i>/export</i> - export your Sent Tasks of all statuses to Excel
+<i>/export inbox new csv</i> - export your new Inbox Tasks to CSV
+<i>/export team 2019-01-01 2019-01-31</i> - export Tasks of your teams created in January 2019
+Types are inbox, sent and team, formats are xlsx and csv`
+
+//taskStatuses lists all Task statuses in the order of the Task life
+var taskStatuses = []string{models.TaskStatusNew, models.TaskStatusStarted, models.TaskStatusRejected, models.TaskStatusCompleted, models.TaskStatusClosed}
+
+//exportFilter selects Tasks for export. Empty Status means all statuses, From and To limit the creation date
+type exportFilter struct {
+	Type   string
+	Status string
+	From   time.Time
+	To     time.Time
+}
+
+//exportRow is a Task with the data which isn't kept in tasks table
+type exportRow struct {
+	Task      models.DbTasks
+	FromUser  models.DbUsers
+	ToUser    models.DbUsers
+	CreatedAt time.Time
+	//StatusAt has the last time the Task got each status
+	StatusAt map[string]time.Time
+	Comments int
+}
+
+//selectExportTasks returns Tasks of the user matching the filter. Type is inbox, sent or team
+func selectExportTasks(u models.DbUsers, f exportFilter) ([]exportRow, error) {
+
+	var t models.DbTasks
+	var xs []models.DbTasks
+
+	statuses := taskStatuses
+	if f.Status != "" {
+		statuses = []string{f.Status}
+	}
+
+	for _, status := range statuses {
+		var rows *sql.Rows
+		var err error
+
+		switch f.Type {
+		case "inbox":
+			rows, err = dbase.SelectInboxTasks(cfg, u.TelegramID, status)
+		case "team":
+			rows, err = dbase.SelectTeamTasks(cfg, u.TelegramID, status)
+		default:
+			rows, err = dbase.SelectSentTasks(cfg, u.TelegramID, status)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for rows.Next() {
+			err := dbase.ScanTask(rows, &t)
+			if err != nil {
+				rows.Close()
+				return nil, err
+			}
+			xs = append(xs, t)
+		}
+		rows.Close()
+	}
+
+	sort.Slice(xs, func(i, j int) bool { return xs[i].ID < xs[j].ID })
+
+	return buildExportRows(xs, f)
+}
+
+//buildExportRows adds participants, status history and comment counts to the Tasks created in the date range of the filter
+func buildExportRows(tasks []models.DbTasks, f exportFilter) ([]exportRow, error) {
+
+	var h models.DbHistory
+	var c models.DbComment
+	var xs []exportRow
+
+	users := make(map[int]models.DbUsers)
+	getUser := func(tgid int) models.DbUsers {
+		if _, ok := users[tgid]; !ok {
+			users[tgid] = dbase.GetUserByTelegramID(cfg, tgid)
+		}
+		return users[tgid]
+	}
+
+	for _, t := range tasks {
+		row := exportRow{
+			Task:      t,
+			FromUser:  getUser(t.FromUser),
+			ToUser:    getUser(t.ToUser),
+			CreatedAt: t.ChangedAt.Time,
+			StatusAt:  make(map[string]time.Time),
+		}
+
+		rows, err := dbase.SelectHistory(cfg, t.ID, t.FromUser)
+		if err != nil {
+			return xs, err
+		}
+
+		first := true
+		for rows.Next() {
+			err := dbase.ScanHistory(rows, &h)
+			if err != nil {
+				rows.Close()
+				return xs, err
+			}
+
+			if first {
+				row.CreatedAt = h.HDb.Date.Time
+				first = false
+			}
+			row.StatusAt[h.HDb.Status] = h.HDb.Date.Time
+		}
+		rows.Close()
+
+		if !f.From.IsZero() && row.CreatedAt.Before(f.From) {
+			continue
+		}
+		if !f.To.IsZero() && !row.CreatedAt.Before(f.To.AddDate(0, 0, 1)) {
+			continue
+		}
+
+		rows, err = dbase.SelectComments(cfg, t.ID, t.FromUser)
+		if err != nil {
+			return xs, err
+		}
+
+		for rows.Next() {
+			err := dbase.ScanComments(rows, &c)
+			if err != nil {
+				rows.Close()
+				return xs, err
+			}
+			row.Comments++
+		}
+		rows.Close()
+
+		xs = append(xs, row)
+	}
+
+	return xs, nil
+}
+
+func exportTime(t time.Time) string {
+
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.UTC().Format("2006-01-02 15:04")
+}
+
+//exportText keeps user's text from being run as a formula when the file is opened in a spreadsheet
+func exportText(s string) string {
+
+	if s != "" && strings.ContainsAny(s[:1], "=+-@\t\r") {
+		return "'" + s
+	}
+
+	return s
+}
+
+//exportRecords returns the header and a record for every row
+func exportRecords(rows []exportRow) [][]string {
+
+	header := []string{"id", "title", "description", "status", "priority", "from", "to", "created at", "due date"}
+	for _, status := range taskStatuses[1:] {
+		header = append(header, strings.ToLower(status)+" at")
+	}
+	header = append(header, "comments")
+
+	xs := [][]string{header}
+
+	for _, row := range rows {
+		t := row.Task
+
+		var due string
+		if t.DueDate.Valid {
+			due = t.DueDate.Time.Format(models.DueDateLayout)
+		}
+
+		record := []string{
+			strconv.Itoa(t.ID),
+			exportText(t.Title),
+			exportText(t.Description),
+			t.Status,
+			t.Priority,
+			exportText(strings.TrimSpace(row.FromUser.FirstName + " " + row.FromUser.LastName)),
+			exportText(strings.TrimSpace(row.ToUser.FirstName + " " + row.ToUser.LastName)),
+			exportTime(row.CreatedAt),
+			due,
+		}
+		for _, status := range taskStatuses[1:] {
+			record = append(record, exportTime(row.StatusAt[status]))
+		}
+		record = append(record, strconv.Itoa(row.Comments))
+
+		xs = append(xs, record)
+	}
+
+	return xs
+}
+
+//writeExport writes rows as CSV or XLSX
+func writeExport(w io.Writer, format string, rows []exportRow) error {
+
+	records := exportRecords(rows)
+
+	switch format {
+	case exportCSV:
+		cw := csv.NewWriter(w)
+		err := cw.WriteAll(records)
+		if err != nil {
+			return err
+		}
+		return cw.Error()
+	case exportXLSX:
+		return utils.WriteXLSX(w, "Tasks", records)
+	default:
+		return fmt.Errorf("export format should be %v or %v", exportCSV, exportXLSX)
+	}
+}
+
+//exportContentType returns MIME type of the export format
+func exportContentType(format string) string {
+
+	if format == exportXLSX {
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+
+	return "text/csv; charset=utf-8"
+}
+
+//exportFileName returns file name like taskeram-sent-20190131.xlsx
+func exportFileName(f exportFilter, format string) string {
+
+	return fmt.Sprintf("taskeram-%v-%v.%v", f.Type, time.Now().Format("20060102"), format)
+}
+
+//exportStatus returns Task status by its name in any case, "all" and empty mean all statuses
+func exportStatus(val string) (string, bool) {
+
+	if val == "" || strings.ToLower(val) == "all" {
+		return "", true
+	}
+
+	for _, status := range taskStatuses {
+		if strings.EqualFold(status, val) {
+			return status, true
+		}
+	}
+
+	return "", false
+}
+
+//exportTasks answers the tasks page with ?export=csv or ?export=xlsx by the file with Tasks of the page type.
+//status=all exports Tasks of all statuses, from and to limit the creation date
+func exportTasks(w http.ResponseWriter, r *http.Request, user models.DbUsers) {
+
+	var err error
+
+	format := r.FormValue("export")
+	if format != exportCSV && format != exportXLSX {
+		http.Error(w, fmt.Sprintf("Exporting tasks. Err: wrong format %v", format), http.StatusBadRequest)
+		return
+	}
+
+	f := exportFilter{Type: r.FormValue("type")}
+	switch f.Type {
+	case "inbox", "sent", "team":
+	default:
+		f.Type = "inbox"
+	}
+
+	status, ok := exportStatus(r.FormValue("status"))
+	if !ok {
+		http.Error(w, fmt.Sprintf("Exporting tasks. Err: wrong status %v", r.FormValue("status")), http.StatusBadRequest)
+		return
+	}
+	f.Status = status
+
+	if val := r.FormValue("from"); val != "" {
+		f.From, err = time.Parse(models.DueDateLayout, val)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Exporting tasks. Err: wrong date %v", val), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if val := r.FormValue("to"); val != "" {
+		f.To, err = time.Parse(models.DueDateLayout, val)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Exporting tasks. Err: wrong date %v", val), http.StatusBadRequest)
+			return
+		}
+	}
+
+	rows, err := selectExportTasks(user, f)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Exporting tasks. Err: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	var b bytes.Buffer
+
+	err = writeExport(&b, format, rows)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Exporting tasks. Err: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", exportContentType(format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%v"`, exportFileName(f, format)))
+
+	_, err = b.WriteTo(w)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+//handleCommandExport sends Tasks to the user as CSV or XLSX document
+func handleCommandExport(c *models.UserCache) {
+
+	f := exportFilter{Type: "sent"}
+	format := exportXLSX
+	reply := ""
+
+	for _, val := range strings.Fields(c.Arguments) {
+		val = strings.ToLower(val)
+
+		if status, ok := exportStatus(val); ok {
+			f.Status = status
+			continue
+		}
+
+		switch val {
+		case "inbox", "sent", "team":
+			f.Type = val
+			continue
+		case exportCSV, exportXLSX:
+			format = val
+			continue
+		}
+
+		date, err := time.Parse(models.DueDateLayout, val)
+		if err == nil && f.From.IsZero() {
+			f.From = date
+		} else if err == nil && f.To.IsZero() {
+			f.To = date
+		} else {
+			reply = exportHelp
+			break
+		}
+	}
+
+	var b bytes.Buffer
+
+	if reply == "" {
+		rows, err := selectExportTasks(c.User, f)
+		if err == nil {
+			err = writeExport(&b, format, rows)
+		}
+
+		if err != nil {
+			log.Println(fmt.Errorf("export: %v", err))
+			reply = "Something went wrong while exporting Tasks"
+		} else if len(rows) == 0 {
+			reply = "There are no Tasks to export"
+		}
+	}
+
+	if reply != "" {
+		msg := tgbotapi.NewMessage(c.ChatID, reply)
+		msg.ParseMode = "HTML"
+		_, err := bot.Send(msg)
+		if err != nil {
+			log.Println(err)
+		}
+		return
+	}
+
+	doc := tgbotapi.NewDocumentUpload(c.ChatID, tgbotapi.FileBytes{Name: exportFileName(f, format), Bytes: b.Bytes()})
+	_, err := bot.Send(doc)
+	if err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+
+	"github.com/slevchyk/taskeram/models"
+)
+
+func TestExportFormulas(t *testing.T) {
+
+	rows := []exportRow{{
+		Task: models.DbTasks{
+			ID:          1,
+			Title:       `=HYPERLINK("http://evil.example","click")`,
+			Description: "-2+3",
+			Status:      models.TaskStatusNew,
+		},
+		FromUser: models.DbUsers{FirstName: "@SUM(A1)"},
+		ToUser:   models.DbUsers{FirstName: "Ірина"},
+	}}
+
+	var b bytes.Buffer
+	err := writeExport(&b, exportCSV, rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := csv.NewReader(&b).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"1", `'=HYPERLINK("http://evil.example","click")`, "'-2+3", models.TaskStatusNew, "", "'@SUM(A1)", "Ірина"}
+	for i, val := range want {
+		if records[1][i] != val {
+			t.Errorf("%v is exported as %q, want %q", records[0][i], records[1][i], val)
+		}
+	}
+
+	for _, s := range []string{"+1", "\tx", "\rx"} {
+		if got := exportText(s); got != "'"+s {
+			t.Errorf("exportText(%q) = %q", s, got)
+		}
+	}
+}
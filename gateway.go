@@ -0,0 +1,494 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/slevchyk/taskeram/dbase"
+	"github.com/slevchyk/taskeram/models"
+)
+
+//taskInputError is returned by createTask when the Task data is wrong, so the caller can answer with Bad Request
+type taskInputError string
+
+func (e taskInputError) Error() string {
+	return string(e)
+}
+
+//errTaskForbidden is returned by createTask when the user role doesn't allow to assign the Task to the recipient
+var errTaskForbidden = errors.New("you have no permission to create Tasks for this user")
+
+//Gateway requests are limited in size, bigger ones get Request Entity Too Large
+const (
+	apiTaskMaxSize = 1 << 20
+	apiMailMaxSize = 10 << 20
+)
+
+//errStatusForbidden is returned by changeTaskStatus when the rules don't allow the action for the current Task status
+var errStatusForbidden = errors.New("it isn't allowed to change the status")
+
+//apiTask is the JSON payload of /api/tasks
+type apiTask struct {
+	ToUser      string `json:"to_user"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	DueDate     string `json:"due_date"`
+}
+
+//createTask validates and saves a new Task from fromUser and informs participants about it.
+//toUser is a Telegram ID or a username of an approved user, dueDate is YYYY-MM-DD or empty
+func createTask(fromUser models.DbUsers, toUser string, title string, description string, dueDate string) (models.DbTasks, error) {
+
+	t, u, err := prepareTask(fromUser, toUser, title, description, dueDate)
+	if err != nil {
+		return t, err
+	}
+
+	t, err = insertTask(t)
+	if err != nil {
+		return t, err
+	}
+
+	informNewTask(int64(t.ID), t, fromUser, u)
+
+	return t, nil
+}
+
+//taskTypeOf returns Inbox for the assignee of the Task, Sent for its author and empty string for others
+func taskTypeOf(t models.DbTasks, tgid int) string {
+
+	switch tgid {
+	case t.ToUser:
+		return "Inbox"
+	case t.FromUser:
+		return "Sent"
+	}
+
+	return ""
+}
+
+//statusAction finds the action by its name or by the status it sets, e.g. start or started, ignoring case
+func statusAction(val string) string {
+
+	val = strings.Title(strings.ToLower(strings.TrimSpace(val)))
+
+	for action, status := range actionStatus {
+		if val == action || val == status {
+			return action
+		}
+	}
+
+	return ""
+}
+
+//changeTaskStatus applies the action of the user to the Task if the task rules allow it.
+//All status changes go this way: the Task is saved, the other participant is notified and webhooks are fired
+func changeTaskStatus(t models.DbTasks, by models.DbUsers, action string) (models.DbTasks, error) {
+
+	if !taskRules[taskTypeOf(t, by.TelegramID)][t.Status].Contains(action) {
+		return t, errStatusForbidden
+	}
+
+	return saveTaskStatus(t, by, action)
+}
+
+//overrideTaskStatus is changeTaskStatus for admin tools, e.g. the CLI, where by isn't a participant of the Task.
+//The action has to be allowed to the author or to the assignee for the current status, and both of them are notified
+func overrideTaskStatus(t models.DbTasks, by models.DbUsers, action string) (models.DbTasks, error) {
+
+	if !taskRules["Inbox"][t.Status].Contains(action) && !taskRules["Sent"][t.Status].Contains(action) {
+		return t, errStatusForbidden
+	}
+
+	return saveTaskStatus(t, by, action)
+}
+
+func saveTaskStatus(t models.DbTasks, by models.DbUsers, action string) (models.DbTasks, error) {
+
+	newStatus, ok := actionStatus[action]
+	if !ok {
+		return t, errStatusForbidden
+	}
+
+	oldStatus := t.Status
+	t.Status = newStatus
+	t.ChangedAt = models.NullTime{Time: time.Now().UTC(), Valid: true}
+	t.ChangedBy = by.TelegramID
+
+	stmt, err := dbase.UpdateTaskStatus(cfg)
+	if err != nil {
+		return t, err
+	}
+
+	_, err = dbase.ExecUpdateTaskStatus(stmt, t)
+	if err != nil {
+		return t, err
+	}
+
+	informStatusChange(t, by, oldStatus)
+
+	return t, nil
+}
+
+//prepareTask validates a new Task the same way as createTask but doesn't save it. It returns the Task and its recipient
+func prepareTask(fromUser models.DbUsers, toUser string, title string, description string, dueDate string) (models.DbTasks, models.DbUsers, error) {
+
+	var t models.DbTasks
+
+	u := findTaskRecipient(toUser)
+	if u.ID == 0 || u.Status != models.UserApprowed {
+		return t, u, taskInputError(fmt.Sprintf("can't find any approved user %v", toUser))
+	}
+
+	if !canAssignTask(fromUser, u) {
+		return t, u, errTaskForbidden
+	}
+
+	title = strings.TrimSpace(title)
+	if title == "" {
+		return t, u, taskInputError("title is empty")
+	}
+
+	t.FromUser = fromUser.TelegramID
+	t.ToUser = u.TelegramID
+	t.Status = models.TaskStatusNew
+	t.ChangedAt = models.NullTime{Time: time.Now().UTC(), Valid: true}
+	t.ChangedBy = fromUser.TelegramID
+	t.Title = title
+	t.Description = strings.TrimSpace(description)
+
+	if dueDate != "" {
+		due, err := time.Parse(models.DueDateLayout, dueDate)
+		if err != nil {
+			return t, u, taskInputError(fmt.Sprintf("wrong due date %v, expected YYYY-MM-DD", dueDate))
+		}
+		t.DueDate = models.NullTime{Time: due, Valid: true}
+	}
+
+	return t, u, nil
+}
+
+//insertTask saves the Task prepared by prepareTask and returns it with ID
+func insertTask(t models.DbTasks) (models.DbTasks, error) {
+
+	stmt, err := dbase.InsertTask(cfg)
+	if err != nil {
+		return t, err
+	}
+
+	res, err := dbase.ExecInsertTask(stmt, t)
+	if err != nil {
+		return t, err
+	}
+
+	newTaskID, err := res.LastInsertId()
+	if err != nil {
+		return t, err
+	}
+	t.ID = int(newTaskID)
+
+	return t, nil
+}
+
+//findTaskRecipient looks for a user by Telegram ID or by username/first name the same way as @mentions
+func findTaskRecipient(toUser string) models.DbUsers {
+
+	toUser = strings.TrimSpace(toUser)
+
+	tgid, err := strconv.Atoi(toUser)
+	if err == nil {
+		return dbase.GetUserByTelegramID(cfg, tgid)
+	}
+
+	mention := "@" + strings.TrimPrefix(toUser, "@")
+	if u, ok := resolveMentions(mention)[mention]; ok {
+		return u
+	}
+
+	return models.DbUsers{}
+}
+
+//apiUser authenticates a request by "Authorization: Bearer <token>" header
+func apiUser(r *http.Request) (models.DbUsers, bool) {
+
+	var t models.DbAPITokens
+
+	token := strings.TrimSpace(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+	if token == "" {
+		return models.DbUsers{}, false
+	}
+
+	rows, err := dbase.SelectAPITokenByToken(cfg, hashToken(token))
+	if err != nil {
+		log.Println(fmt.Errorf("SelectAPITokenByToken: %v", err))
+		return models.DbUsers{}, false
+	}
+
+	if rows.Next() {
+		err := dbase.ScanAPIToken(rows, &t)
+		if err != nil {
+			log.Println(err)
+		}
+	}
+	rows.Close()
+
+	if t.ID == 0 {
+		return models.DbUsers{}, false
+	}
+
+	u := dbase.GetUserByTelegramID(cfg, t.TelegramID)
+	if u.ID == 0 || u.Status != models.UserApprowed {
+		return models.DbUsers{}, false
+	}
+
+	return u, true
+}
+
+func hashToken(token string) string {
+
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+//newAPIToken replaces user API token with a new one and returns it. Only the hash is kept in DB
+func newAPIToken(tgid int) (string, error) {
+
+	b := make([]byte, 24)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+
+	stmt, err := dbase.DeleteAPITokensByTelegramID(cfg)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = stmt.Exec(tgid)
+	if err != nil {
+		return "", err
+	}
+
+	stmt, err = dbase.InsertAPIToken(cfg)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = dbase.ExecInsertAPIToken(stmt, models.DbAPITokens{
+		TelegramID: tgid,
+		Token:      hashToken(token),
+		CreatedAt:  models.NullTime{Time: time.Now().UTC(), Valid: true},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+//apiTasksHandler creates a Task from JSON or form payload with to_user, title, description and due_date
+func apiTasksHandler(w http.ResponseWriter, r *http.Request) {
+
+	var at apiTask
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := apiUser(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, apiTaskMaxSize)
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		err := json.NewDecoder(r.Body).Decode(&at)
+		if isBodyTooLarge(err) {
+			http.Error(w, fmt.Sprintf("Request is bigger than %v KB", apiTaskMaxSize>>10), http.StatusRequestEntityTooLarge)
+			return
+		} else if err != nil {
+			http.Error(w, fmt.Sprintf("Wrong JSON: %v", err), http.StatusBadRequest)
+			return
+		}
+	} else {
+		err := r.ParseForm()
+		if isBodyTooLarge(err) {
+			http.Error(w, fmt.Sprintf("Request is bigger than %v KB", apiTaskMaxSize>>10), http.StatusRequestEntityTooLarge)
+			return
+		} else if err != nil {
+			http.Error(w, fmt.Sprintf("Wrong form: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		at.ToUser = r.FormValue("to_user")
+		at.Title = r.FormValue("title")
+		at.Description = r.FormValue("description")
+		at.DueDate = r.FormValue("due_date")
+	}
+
+	t, err := createTask(user, at.ToUser, at.Title, at.Description, at.DueDate)
+	writeCreatedTask(w, t, err)
+}
+
+//isBodyTooLarge reports whether reading of the request body stopped at http.MaxBytesReader limit
+func isBodyTooLarge(err error) bool {
+
+	return err != nil && strings.Contains(err.Error(), "http: request body too large")
+}
+
+//apiTasksMailHandler creates a Task from a raw RFC 822 message, e.g. piped by a mail server.
+//Subject is the Task title and the text part is the description. The recipient is taken from
+//X-Taskeram-To header or from the To address tag, e.g. tasks+username@example.com
+func apiTasksMailHandler(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := apiUser(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, apiMailMaxSize)
+
+	msg, err := mail.ReadMessage(r.Body)
+	if isBodyTooLarge(err) {
+		http.Error(w, fmt.Sprintf("Message is bigger than %v KB", apiMailMaxSize>>10), http.StatusRequestEntityTooLarge)
+		return
+	} else if err != nil {
+		http.Error(w, fmt.Sprintf("Wrong message: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	dec := new(mime.WordDecoder)
+
+	subject, err := dec.DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		subject = msg.Header.Get("Subject")
+	}
+
+	toUser := msg.Header.Get("X-Taskeram-To")
+	if toUser == "" {
+		toUser = mailRecipientTag(msg.Header)
+	}
+
+	description, err := mailText(msg.Header.Get("Content-Type"), msg.Header.Get("Content-Transfer-Encoding"), msg.Body)
+	if isBodyTooLarge(err) {
+		http.Error(w, fmt.Sprintf("Message is bigger than %v KB", apiMailMaxSize>>10), http.StatusRequestEntityTooLarge)
+		return
+	} else if err != nil {
+		http.Error(w, fmt.Sprintf("Can't read message body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	t, err := createTask(user, toUser, subject, description, msg.Header.Get("X-Taskeram-Due"))
+	writeCreatedTask(w, t, err)
+}
+
+func writeCreatedTask(w http.ResponseWriter, t models.DbTasks, err error) {
+
+	if _, ok := err.(taskInputError); ok {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if err == errTaskForbidden {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(t)
+}
+
+//mailRecipientTag returns the tag of the first To address with one, "username" for tasks+username@example.com
+func mailRecipientTag(h mail.Header) string {
+
+	addresses, err := h.AddressList("To")
+	if err != nil {
+		return ""
+	}
+
+	for _, val := range addresses {
+		local := strings.SplitN(val.Address, "@", 2)[0]
+		if i := strings.Index(local, "+"); i != -1 {
+			return local[i+1:]
+		}
+	}
+
+	return ""
+}
+
+//mailText returns the first text/plain part of the message body
+func mailText(contentType string, encoding string, body io.Reader) (string, error) {
+
+	if contentType == "" {
+		contentType = "text/plain"
+	}
+
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", err
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			p, err := mr.NextPart()
+			if err == io.EOF {
+				return "", nil
+			}
+			if err != nil {
+				return "", err
+			}
+
+			text, err := mailText(p.Header.Get("Content-Type"), p.Header.Get("Content-Transfer-Encoding"), p)
+			if err != nil || text != "" {
+				return text, err
+			}
+		}
+	}
+
+	if mediaType != "text/plain" {
+		return "", nil
+	}
+
+	switch strings.ToLower(encoding) {
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	}
+
+	text, err := ioutil.ReadAll(body)
+	if err != nil {
+		return "", err
+	}
+
+	return string(text), nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAPITasksBodyLimit(t *testing.T) {
+
+	setupTestDB(t)
+	rt := newWebRouter()
+
+	token, err := newAPIToken(testAdminID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	big := strings.Repeat("a", apiMailMaxSize)
+
+	tests := []struct {
+		path        string
+		contentType string
+		body        string
+	}{
+		{"/api/tasks", "application/json", `{"to_user": "2", "title": "big", "description": "` + big + `"}`},
+		{"/api/tasks", "application/x-www-form-urlencoded", "to_user=2&title=big&description=" + big},
+		{"/api/tasks/mail", "", "Subject: big\r\nX-Taskeram-To: 2\r\n\r\n" + big},
+		{"/api/tasks/mail", "", "Subject: big\r\nX-Taskeram-To: 2\r\nX-Padding: " + big + "\r\n\r\nbody"},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+		r.Header.Set("Authorization", "Bearer "+token)
+		if tt.contentType != "" {
+			r.Header.Set("Content-Type", tt.contentType)
+		}
+
+		w := httptest.NewRecorder()
+		rt.ServeHTTP(w, r)
+
+		if w.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("%v %v: status %v, want %v", tt.path, tt.contentType, w.Code, http.StatusRequestEntityTooLarge)
+		}
+	}
+
+	if n := countRows(t, "tasks"); n != 1 {
+		t.Errorf("%v Tasks in DB, want 1", n)
+	}
+}
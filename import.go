@@ -0,0 +1,459 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/slevchyk/taskeram/dbase"
+	"github.com/slevchyk/taskeram/models"
+	"gopkg.in/telegram-bot-api.v4"
+)
+
+//importMaxSize limits uploaded CSV files
+const importMaxSize = 1 << 20
+
+//importMaxPreview limits rows listed in the bot preview message
+const importMaxPreview = 30
+
+const importHelp = `Send me CSV file to create many Tasks at once. The first row names the columns:
+<i>assignee</i> - Telegram ID, username or first name of the user
+<i>title</i>
+<i>description</i> - optional
+<i>due date</i> - optional, YYYY-MM-DD
+<i>priority</i> - optional, low, normal or high`
+
+//importColumns maps column names of CSV file to the fields
+var importColumns = map[string]string{
+	"assignee":    "assignee",
+	"to":          "assignee",
+	"touser":      "assignee",
+	"user":        "assignee",
+	"title":       "title",
+	"description": "description",
+	"duedate":     "due",
+	"due":         "due",
+	"priority":    "priority",
+}
+
+//parseImportCSV reads Tasks from fromUser in CSV file and validates every row the same way as createTask.
+//The error is returned if the file can't be read at all, problems of rows are in their Error
+func parseImportCSV(r io.Reader, fromUser models.DbUsers) ([]models.ImportRow, error) {
+
+	var xs []models.ImportRow
+
+	b, err := ioutil.ReadAll(io.LimitReader(r, importMaxSize+1))
+	if err != nil {
+		return xs, err
+	}
+	if len(b) > importMaxSize {
+		return xs, fmt.Errorf("file is bigger than %v KB", importMaxSize>>10)
+	}
+	b = bytes.TrimPrefix(b, []byte("\xef\xbb\xbf"))
+
+	cr := csv.NewReader(bytes.NewReader(b))
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	//Excel saves CSV with semicolons in many locales
+	firstLine := string(b)
+	if i := strings.IndexByte(firstLine, '\n'); i >= 0 {
+		firstLine = firstLine[:i]
+	}
+	if strings.Count(firstLine, ";") > strings.Count(firstLine, ",") {
+		cr.Comma = ';'
+	}
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return xs, errors.New("file is empty")
+	} else if err != nil {
+		return xs, err
+	}
+
+	columns := make(map[string]int)
+	for i, val := range header {
+		name := strings.NewReplacer(" ", "", "_", "", "-", "").Replace(strings.ToLower(strings.TrimSpace(val)))
+		if field, ok := importColumns[name]; ok {
+			columns[field] = i
+		}
+	}
+
+	for _, field := range []string{"assignee", "title"} {
+		if _, ok := columns[field]; !ok {
+			return xs, fmt.Errorf("there is no %v column", field)
+		}
+	}
+
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+
+		line, _ := cr.FieldPos(0)
+
+		if err != nil {
+			xs = append(xs, models.ImportRow{Line: line, Error: err.Error()})
+			continue
+		}
+
+		value := func(field string) string {
+			i, ok := columns[field]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+
+		if strings.TrimSpace(strings.Join(record, "")) == "" {
+			continue
+		}
+
+		row := models.ImportRow{Line: line}
+
+		row.Task, row.ToUser, err = prepareTask(fromUser, value("assignee"), value("title"), value("description"), value("due"))
+		if err != nil {
+			row.Error = err.Error()
+		}
+
+		priority := strings.ToLower(value("priority"))
+		switch priority {
+		case "", models.PriorityLow, models.PriorityNormal, models.PriorityHigh:
+			row.Task.Priority = priority
+		default:
+			if row.Error == "" {
+				row.Error = fmt.Sprintf("wrong priority %v, expected %v, %v or %v", priority, models.PriorityLow, models.PriorityNormal, models.PriorityHigh)
+			}
+		}
+
+		xs = append(xs, row)
+	}
+
+	if len(xs) == 0 {
+		return xs, errors.New("there are no Tasks in the file")
+	}
+
+	return xs, nil
+}
+
+//importErrors returns number of rows with errors
+func importErrors(rows []models.ImportRow) int {
+
+	var n int
+	for _, row := range rows {
+		if row.Error != "" {
+			n++
+		}
+	}
+
+	return n
+}
+
+//importTasks saves Tasks from fromUser and informs every recipient once about all the new Tasks.
+//Nothing is saved if any row has an error
+func importTasks(fromUser models.DbUsers, rows []models.ImportRow) ([]models.DbTasks, error) {
+
+	var xs []models.DbTasks
+
+	if n := importErrors(rows); n != 0 {
+		return xs, fmt.Errorf("%v rows have errors", n)
+	}
+
+	for _, row := range rows {
+		t, err := insertTask(row.Task)
+		if err != nil {
+			informImportedTasks(fromUser, xs)
+			return xs, err
+		}
+		xs = append(xs, t)
+	}
+
+	informImportedTasks(fromUser, xs)
+
+	return xs, nil
+}
+
+//informImportedTasks does the same as informNewTask for imported Tasks, but every recipient gets one message
+func informImportedTasks(fromUser models.DbUsers, tasks []models.DbTasks) {
+
+	var order []int
+	seen := make(map[int]bool)
+
+	for i := range tasks {
+		t := tasks[i]
+		fireWebhooks(webhookPayload{Event: models.WebhookTaskCreated, Task: &t, By: &fromUser})
+
+		mentioned := resolveMentions(t.Description)
+		informMentions(t, fromUser, t.Description, mentioned)
+
+		if !seen[t.ToUser] {
+			seen[t.ToUser] = true
+			order = append(order, t.ToUser)
+		}
+	}
+
+	for _, tgid := range order {
+		if tgid == fromUser.TelegramID {
+			continue
+		}
+
+		var list []string
+		for _, t := range tasks {
+			if t.ToUser != tgid {
+				continue
+			}
+
+			line := fmt.Sprintf("<b>#%v</b> %v", t.ID, html.EscapeString(t.Title))
+			if t.DueDate.Valid {
+				line += fmt.Sprintf(", due %v", t.DueDate.Time.Format(models.DueDateLayout))
+			}
+			if t.Priority == models.PriorityHigh {
+				line += ", high priority"
+			}
+			list = append(list, line)
+		}
+
+		reply := fmt.Sprintf(`<b>You have %v new Tasks</b>
+%v
+
+Task manager: <a href="tg://user?id=%v">%v %v</a>`, len(list), strings.Join(list, "\n"), fromUser.TelegramID, fromUser.FirstName, fromUser.LastName)
+
+		notifyUser(tgid, models.NotifyNewTask, 0, fmt.Sprintf("%v new Tasks from %v %v", len(list), fromUser.FirstName, fromUser.LastName), reply)
+	}
+}
+
+//handleImportDocument previews Tasks from CSV document sent to the bot. It returns false if the document isn't CSV
+func handleImportDocument(c *models.UserCache) bool {
+
+	doc := c.Message.Document
+	if doc == nil || !strings.HasSuffix(strings.ToLower(doc.FileName), ".csv") {
+		return false
+	}
+
+	reply := func(text string) {
+		msg := tgbotapi.NewMessage(c.ChatID, text)
+		msg.ParseMode = "HTML"
+		_, err := bot.Send(msg)
+		if err != nil {
+			log.Println(err)
+		}
+	}
+
+	if !can(c.User, permCreateTasks) {
+		reply("You have no permission to create Tasks")
+		return true
+	}
+
+	if doc.FileSize > importMaxSize {
+		reply(fmt.Sprintf("File is bigger than %v KB", importMaxSize>>10))
+		return true
+	}
+
+	url, err := bot.GetFileDirectURL(doc.FileID)
+	if err != nil {
+		log.Println(err)
+		reply("Something went wrong while downloading the file")
+		return true
+	}
+
+	resp, err := http.Get(url)
+	if err != nil {
+		log.Println(err)
+		reply("Something went wrong while downloading the file")
+		return true
+	}
+	defer resp.Body.Close()
+
+	rows, err := parseImportCSV(resp.Body, c.User)
+	if err != nil {
+		reply(fmt.Sprintf("Can't import Tasks: %v\n\n%v", html.EscapeString(err.Error()), importHelp))
+		return true
+	}
+
+	c.ImportRows = nil
+
+	errs := importErrors(rows)
+
+	var text []string
+	for i, row := range rows {
+		if i == importMaxPreview {
+			text = append(text, fmt.Sprintf("and %v more", len(rows)-importMaxPreview))
+			break
+		}
+
+		if row.Error != "" {
+			text = append(text, fmt.Sprintf("❌ line %v: %v", row.Line, html.EscapeString(row.Error)))
+		} else {
+			text = append(text, fmt.Sprintf("✅ line %v: %v → %v %v", row.Line, html.EscapeString(row.Task.Title), row.ToUser.FirstName, row.ToUser.LastName))
+		}
+	}
+
+	if errs != 0 {
+		reply(fmt.Sprintf("<b>%v of %v rows have errors</b>\n%v\n\nFix the file and send it again", errs, len(rows), strings.Join(text, "\n")))
+		return true
+	}
+
+	c.ImportRows = rows
+
+	msg := tgbotapi.NewMessage(c.ChatID, fmt.Sprintf("<b>%v Tasks are ready to be created</b>\n%v", len(rows), strings.Join(text, "\n")))
+	msg.ParseMode = "HTML"
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("Create %v Tasks", len(rows)), models.ImportCreate),
+		tgbotapi.NewInlineKeyboardButtonData("Cancel", models.ImportCancel),
+	))
+	_, err = bot.Send(msg)
+	if err != nil {
+		log.Println(err)
+	}
+
+	return true
+}
+
+//handleCommandImport explains how to import Tasks from CSV file
+func handleCommandImport(c *models.UserCache) {
+
+	if !can(c.User, permCreateTasks) {
+		return
+	}
+
+	msg := tgbotapi.NewMessage(c.ChatID, importHelp)
+	msg.ParseMode = "HTML"
+	_, err := bot.Send(msg)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+//importCreate creates Tasks previewed by handleImportDocument
+func importCreate(c *models.UserCache) {
+
+	rows := c.ImportRows
+	c.ImportRows = nil
+
+	var text string
+
+	if len(rows) == 0 {
+		text = "There are no Tasks to create, send the file again"
+	} else if tasks, err := importTasks(c.User, rows); err != nil {
+		log.Println(fmt.Errorf("import: %v", err))
+		text = fmt.Sprintf("%v of %v Tasks are created, then something went wrong", len(tasks), len(rows))
+	} else {
+		text = fmt.Sprintf("%v Tasks are created", len(tasks))
+	}
+
+	editImportMessage(c, text)
+}
+
+func importCancel(c *models.UserCache) {
+
+	c.ImportRows = nil
+	editImportMessage(c, "Import is canceled")
+}
+
+//editImportMessage replaces the preview with the result, so the buttons can't be pressed twice
+func editImportMessage(c *models.UserCache, text string) {
+
+	msg := tgbotapi.NewEditMessageText(c.ChatID, c.MessageID, text)
+	_, err := bot.Send(msg)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+func importHandler(w http.ResponseWriter, r *http.Request) {
+
+	var td models.TplImport
+
+	user := currentUser(r)
+
+	if !can(user, permCreateTasks) {
+		http.Error(w, "Access denied", http.StatusNotFound)
+		return
+	}
+
+	td.NavBar.LoggedIn = true
+	td.NavBar.User = user
+	td.NavBar.MainMenu = getMainMenu("import")
+	td.NavBar.CSRF = csrfToken(r)
+	td.Users = importUsers(user)
+
+	do := r.FormValue("do")
+	switch do {
+	case "preview":
+		mf, _, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Importing tasks. Err: %v", err), http.StatusBadRequest)
+			return
+		}
+		defer mf.Close()
+
+		b, err := ioutil.ReadAll(io.LimitReader(mf, importMaxSize+1))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Importing tasks. Err: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		td.CSV = string(b)
+	case "create":
+		td.CSV = r.FormValue("csv")
+	}
+
+	if do == "preview" || do == "create" {
+		rows, err := parseImportCSV(strings.NewReader(td.CSV), user)
+		if err != nil {
+			td.Error = err.Error()
+		}
+
+		td.Rows = rows
+		td.Errors = importErrors(rows)
+	}
+
+	if do == "create" && td.Error == "" && td.Errors == 0 {
+		tasks, err := importTasks(user, td.Rows)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Importing tasks. %v tasks are created, then Err: %v", len(tasks), err), http.StatusInternalServerError)
+			return
+		}
+
+		http.Redirect(w, r, fmt.Sprintf("/tasks?type=sent&status=%v", strings.ToLower(models.TaskStatusNew)), http.StatusSeeOther)
+		return
+	}
+
+	err := tpl.ExecuteTemplate(w, "import.gohtml", td)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+//importUsers returns users who may be assignees of the imported Tasks, it is shown as a hint on the import page
+func importUsers(user models.DbUsers) []models.DbUsers {
+
+	var u models.DbUsers
+	var xs []models.DbUsers
+
+	rows, err := dbase.SelectUsersByStatus(cfg, models.UserApprowed)
+	if err != nil {
+		log.Println(err)
+		return xs
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		err := dbase.ScanUser(rows, &u)
+		if err != nil {
+			log.Println(err)
+		} else if canAssignTask(user, u) {
+			xs = append(xs, u)
+		}
+	}
+
+	return xs
+}
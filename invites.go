@@ -0,0 +1,389 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/slevchyk/taskeram/dbase"
+	"github.com/slevchyk/taskeram/models"
+	"gopkg.in/telegram-bot-api.v4"
+)
+
+const inviteHelp = `<i>/invite</i> - show outstanding invites
+<i>/invite new</i> - create a single-use invite
+<i>/invite new uses=5 days=7 role=guest team=2</i> - create an invite for 5 users valid for 7 days, they join team #2 as guests
+<i>/invite revoke 3</i> - revoke invite #3`
+
+var errInviteRole = errors.New("you can't invite users with this role")
+
+//inviteLink returns deep link to the bot which passes the invite code to /start
+func inviteLink(code string) string {
+	return fmt.Sprintf("https://t.me/%v?start=%v", bot.Self.UserName, code)
+}
+
+//inviteValid reports whether the invite can still be used
+func inviteValid(i models.DbInvites) bool {
+
+	if i.ID == 0 || i.Revoked == 1 || i.Uses >= i.MaxUses {
+		return false
+	}
+
+	return !i.ExpiresAt.Valid || i.ExpiresAt.Time.After(time.Now().UTC())
+}
+
+//selectInvites returns invites which still can be used
+func selectInvites() []models.DbInvites {
+
+	var i models.DbInvites
+	var xs []models.DbInvites
+
+	rows, err := dbase.SelectOutstandingInvites(cfg)
+	if err != nil {
+		log.Println(fmt.Errorf("SelectOutstandingInvites: %v", err))
+		return xs
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		err := dbase.ScanInvite(rows, &i)
+		if err != nil {
+			log.Println(err)
+		} else if inviteValid(i) {
+			xs = append(xs, i)
+		}
+	}
+
+	return xs
+}
+
+func getInviteByCode(code string) models.DbInvites {
+
+	var i models.DbInvites
+
+	rows, err := dbase.SelectInviteByCode(cfg, code)
+	if err != nil {
+		log.Println(fmt.Errorf("SelectInviteByCode: %v", err))
+		return i
+	}
+	defer rows.Close()
+
+	if rows.Next() {
+		err := dbase.ScanInvite(rows, &i)
+		if err != nil {
+			log.Println(err)
+		}
+	}
+
+	return i
+}
+
+//newInvite creates an invite on behalf of by. Only users who can change roles may invite with roles above member
+func newInvite(by models.DbUsers, role string, teamID int, maxUses int, days int) (models.DbInvites, error) {
+
+	var i models.DbInvites
+
+	if role == "" {
+		role = models.RoleMember
+	}
+
+	if !isRole(role) || (role != models.RoleMember && role != models.RoleGuest && !can(by, permChangeRoles)) {
+		return i, errInviteRole
+	}
+
+	if teamID != 0 && getTeamByID(teamID).ID == 0 {
+		return i, fmt.Errorf("can't find any team with ID: %v", teamID)
+	}
+
+	if maxUses < 1 {
+		maxUses = 1
+	}
+
+	b := make([]byte, 8)
+	_, err := rand.Read(b)
+	if err != nil {
+		return i, err
+	}
+
+	i = models.DbInvites{
+		Code:      hex.EncodeToString(b),
+		Role:      role,
+		TeamID:    teamID,
+		MaxUses:   maxUses,
+		CreatedBy: by.TelegramID,
+		CreatedAt: models.NullTime{Time: time.Now().UTC(), Valid: true},
+	}
+
+	if days > 0 {
+		i.ExpiresAt = models.NullTime{Time: i.CreatedAt.Time.AddDate(0, 0, days), Valid: true}
+	}
+
+	stmt, err := dbase.InsertInvite(cfg)
+	if err != nil {
+		return i, err
+	}
+
+	_, err = dbase.ExecInsertInvite(stmt, i)
+	if err != nil {
+		return i, err
+	}
+
+	return getInviteByCode(i.Code), nil
+}
+
+func revokeInvite(id int) error {
+
+	stmt, err := dbase.UpdateInviteRevoked(cfg)
+	if err != nil {
+		return err
+	}
+
+	_, err = stmt.Exec(id)
+	return err
+}
+
+//useInvite counts one more usage of the invite. It fails if somebody else has used the last one meanwhile
+func useInvite(i models.DbInvites) error {
+
+	stmt, err := dbase.UpdateInviteUse(cfg)
+	if err != nil {
+		return err
+	}
+
+	res, err := stmt.Exec(i.ID)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return errors.New("invite has been used up")
+	}
+
+	return nil
+}
+
+//acceptInvite approves new or requested user who came by invite link /start <code>
+//It returns false if there is no valid invite, so the user is served as usual
+func acceptInvite(update tgbotapi.Update) bool {
+
+	if !update.Message.IsCommand() || update.Message.Command() != "start" {
+		return false
+	}
+
+	code := strings.TrimSpace(update.Message.CommandArguments())
+	if code == "" || strings.HasPrefix(code, taskStartPrefix) {
+		return false
+	}
+
+	ut := update.Message.From
+
+	i := getInviteByCode(code)
+	if !inviteValid(i) {
+		msg := tgbotapi.NewMessage(update.Message.Chat.ID, fmt.Sprintf("Hello, %s %s. This invite link is expired or has been revoked. Ask admins for a new one", ut.FirstName, ut.LastName))
+		_, err := bot.Send(msg)
+		if err != nil {
+			log.Println(err)
+		}
+		return true
+	}
+
+	u := dbase.GetUserByTelegramID(cfg, ut.ID)
+	if u.Status == models.UserBanned {
+		return false
+	}
+
+	err := useInvite(i)
+	if err != nil {
+		log.Println(err)
+		return false
+	}
+
+	by := dbase.GetUserByTelegramID(cfg, i.CreatedBy)
+
+	if u.ID == 0 {
+		stmt, err := dbase.InsertUser(cfg)
+		if err != nil {
+			log.Println(err)
+			return false
+		}
+
+		u = models.DbUsers{
+			TelegramID: ut.ID,
+			FirstName:  ut.FirstName,
+			LastName:   ut.LastName,
+			Username:   ut.UserName,
+			Status:     models.UserRequested,
+			Role:       models.RoleMember,
+			ChangedBy:  ut.ID,
+			ChangedAt:  models.NullTime{Time: time.Now().UTC(), Valid: true},
+		}
+
+		_, err = dbase.ExecInsertUser(stmt, u)
+		if err != nil {
+			log.Println(err)
+			return false
+		}
+
+		u = dbase.GetUserByTelegramID(cfg, ut.ID)
+	}
+
+	if u.Role != i.Role {
+		u, err = setUserRole(u, by, i.Role)
+		if err != nil {
+			log.Println(err)
+		}
+	}
+
+	if i.TeamID != 0 {
+		err = addTeamMember(i.TeamID, u.TelegramID)
+		if err != nil {
+			log.Println(err)
+		}
+	}
+
+	if u.Status != models.UserApprowed {
+		u, err = changeUserStatus(u, by, models.UserApprowed, fmt.Sprintf("invite #%v", i.ID))
+		if err != nil {
+			log.Println(err)
+			return false
+		}
+	}
+
+	if c, ok := cache[u.TelegramID]; ok {
+		c.User = u
+	}
+
+	reply := fmt.Sprintf(`<a href="tg://user?id=%v">%v %v</a> has joined Taskeram by your invite #%v`, u.TelegramID, u.FirstName, u.LastName, i.ID)
+	msg := tgbotapi.NewMessage(int64(by.TelegramID), reply)
+	msg.ParseMode = "HTML"
+	_, err = bot.Send(msg)
+	if err != nil {
+		log.Println(err)
+	}
+
+	return true
+}
+
+//handleCommandInvite lets admins create, list and revoke invites from the bot
+func handleCommandInvite(c *models.UserCache) {
+
+	if !can(c.User, permApproveUsers) {
+		return
+	}
+
+	args := strings.Fields(c.Arguments)
+
+	if len(args) == 0 {
+		showInvites(c)
+		return
+	}
+
+	var reply string
+
+	switch strings.ToLower(args[0]) {
+	case "new":
+		var role string
+		var teamID, uses, days int
+
+		for _, val := range args[1:] {
+			kv := strings.SplitN(val, "=", 2)
+			if len(kv) != 2 {
+				reply = inviteHelp
+				break
+			}
+
+			switch strings.ToLower(kv[0]) {
+			case "role":
+				role = strings.ToLower(kv[1])
+			case "team":
+				teamID = teamIDArg(kv[1])
+			case "uses":
+				uses, _ = strconv.Atoi(kv[1])
+			case "days":
+				days, _ = strconv.Atoi(kv[1])
+			default:
+				reply = inviteHelp
+			}
+		}
+		if reply != "" {
+			break
+		}
+
+		i, err := newInvite(c.User, role, teamID, uses, days)
+		if err != nil {
+			log.Println(err)
+			reply = fmt.Sprintf("Can't create invite: %v", err)
+			break
+		}
+
+		reply = fmt.Sprintf("Invite #%v for %v user(s) as %v:\n%v", i.ID, i.MaxUses, i.Role, inviteLink(i.Code))
+	case "revoke":
+		if len(args) != 2 {
+			reply = inviteHelp
+			break
+		}
+
+		err := revokeInvite(teamIDArg(args[1]))
+		if err != nil {
+			log.Println(err)
+			reply = "Something went wrong while revoking invite"
+			break
+		}
+
+		showInvites(c)
+		return
+	default:
+		reply = inviteHelp
+	}
+
+	msg := tgbotapi.NewMessage(c.ChatID, reply)
+	msg.ParseMode = "HTML"
+	msg.DisableWebPagePreview = true
+	_, err := bot.Send(msg)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+//showInvites sends the list of outstanding invites
+func showInvites(c *models.UserCache) {
+
+	reply := "<b>Invites</b>\n"
+
+	invites := selectInvites()
+	if len(invites) == 0 {
+		reply += "There are no outstanding invites\n"
+	}
+
+	for _, i := range invites {
+		reply += fmt.Sprintf("\n<b>#%v</b> %v, used %v of %v", i.ID, i.Role, i.Uses, i.MaxUses)
+		if i.TeamID != 0 {
+			reply += fmt.Sprintf(", team %v", html.EscapeString(getTeamByID(i.TeamID).Name))
+		}
+		if i.ExpiresAt.Valid {
+			reply += fmt.Sprintf(", expires %v", i.ExpiresAt.Time.Format("2006-01-02 15:04"))
+		}
+		reply += "\n" + inviteLink(i.Code) + "\n"
+	}
+
+	reply += "\n" + inviteHelp
+
+	msg := tgbotapi.NewMessage(c.ChatID, reply)
+	msg.ParseMode = "HTML"
+	msg.DisableWebPagePreview = true
+	_, err := bot.Send(msg)
+	if err != nil {
+		log.Println(err)
+	}
+}
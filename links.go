@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/telegram-bot-api.v4"
+)
+
+//taskStartPrefix marks /start argument which opens a Task, e.g. /start task_42
+const taskStartPrefix = "task_"
+
+//taskDeepLink returns link which opens the Task in the bot
+func taskDeepLink(taskID int) string {
+	return fmt.Sprintf("https://t.me/%v?start=%v%v", bot.Self.UserName, taskStartPrefix, taskID)
+}
+
+//taskWebURL returns link to the Task in the web app. It is empty if the public URL isn't configured
+func taskWebURL(taskID int) string {
+
+	if taskID == 0 || cfg.Web.URL == "" {
+		return ""
+	}
+
+	return fmt.Sprintf("%v/task?id=%v", strings.TrimRight(cfg.Web.URL, "/"), taskID)
+}
+
+//taskWebButtons returns inline keyboard row with "Open in web" button or nil if there is no link
+func taskWebButtons(taskID int) []tgbotapi.InlineKeyboardButton {
+
+	url := taskWebURL(taskID)
+	if url == "" {
+		return nil
+	}
+
+	return tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonURL("Open in web", url))
+}
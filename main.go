@@ -1,15 +1,20 @@
 package main
 
 import (
+	"context"
 	"database/sql"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"html"
 	"html/template"
-	"io/ioutil"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
@@ -28,30 +33,28 @@ var (
 	taskRules    map[string]map[string]models.AllowedActions
 	actionStatus map[string]string
 	buttons      models.Buttons
-	lastSessionCleaned time.Time
+	//inFlight counts bot updates which are being handled, shutdown waits for them
+	inFlight sync.WaitGroup
 )
 
 const (
 	authSessionLengt = 60
-	sessionLenght = 300
+	telegramAuthMaxAge = 24 * time.Hour
+	webRequestTimeout = 60 * time.Second
+	shutdownTimeout = 30 * time.Second
 )
 
-func init() {
-	var err error
+//setup loads config from file, env and command line flags, connects DB and the bot
+func setup(args []string) {
 
-	cfg, err = LoadConfiguration("taskeram.cfg")
+	c, err := loadConfig(args)
 	if err != nil {
-		log.Fatal("Can't load configuration file config.json", err.Error())
+		log.Fatal("Can't load configuration: ", err)
 	}
 
-	cfg.DB, err = dbase.ConnectDB(cfg)
+	err = connectDB(c)
 	if err != nil {
-		log.Fatal("Can't connect to DB")
-	}
-	db = cfg.DB
-
-	if cfg.Telegram.Token == "" {
-		log.Fatal("Telegram token does not exist in config file")
+		log.Fatal(err)
 	}
 
 	bot, err = tgbotapi.NewBotAPI(cfg.Telegram.Token)
@@ -59,18 +62,48 @@ func init() {
 		log.Fatal(err)
 	}
 
-	tpl = template.Must(template.ParseGlob("templates/*.gohtml"))
+	tpl = template.Must(template.New("").Funcs(template.FuncMap{"can": can, "leadsTeam": leadsTeam}).ParseGlob("templates/*.gohtml"))
+}
+
+//connectDB validates config and connects to DB. It doesn't touch Telegram, so command line tools use it as well
+func connectDB(c models.Config) error {
+	var err error
+
+	if !printConfigReport(validateConfig(c)) {
+		return errors.New("fix the configuration and try again, taskeram config check shows the problems")
+	}
+
+	cfg = c
+	cfg.DB, err = dbase.ConnectDB(cfg)
+	if err != nil {
+		return fmt.Errorf("can't connect to DB: %v", err)
+	}
+	db = cfg.DB
 
-	lastSessionCleaned = time.Now()
+	return nil
 }
 
 func main() {
 
-	defer db.Close()
+	args := os.Args[1:]
+
+	//flags without a command run the bot as before
+	if len(args) > 0 && args[0] == "serve" {
+		args = args[1:]
+	} else if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		code := runCommand(args)
+		//команди, що змінюють задачі чи користувачів, запускають вебхуки і сповіщення у фоні
+		waitWebhooks()
+		waitNotifications()
+		os.Exit(code)
+	}
 
+	setup(args)
 	initialization()
 
-	go startWebApp()
+	srv := startWebApp()
+	go startDigestScheduler()
+	go startSessionCleaner()
 
 	bot.Debug = false
 	log.Printf("Authorized on account %s", bot.Self.UserName)
@@ -80,11 +113,21 @@ func main() {
 	ucfg.Timeout = 60
 
 	upd, _ := bot.GetUpdatesChan(ucfg)
-	// читаем обновления из канала
 
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+
+	// читаем обновления из канала
+loop:
 	for {
 
-		update := <-upd
+		var update tgbotapi.Update
+
+		select {
+		case <-stop:
+			break loop
+		case update = <-upd:
+		}
 
 		var tgid int
 
@@ -101,6 +144,23 @@ func main() {
 
 		u := dbase.GetUserByTelegramID(cfg, tgid)
 
+		//тримаємо username актуальним, по ньому шукаємо @згадки
+		if update.Message != nil && u.ID != 0 && u.Username != update.Message.From.UserName {
+			u.Username = update.Message.From.UserName
+
+			stmt, err := dbase.UpdateUserUsername(cfg)
+			if err == nil {
+				_, err = dbase.ExecUpdateUserUsername(stmt, u)
+			}
+			if err != nil {
+				log.Println(err)
+			}
+
+			if c, ok := cache[tgid]; ok {
+				c.User.Username = u.Username
+			}
+		}
+
 		//Якщо в цього користувача ще не має власних налаштувань сесії, то ініціалізуємо їх
 		if _, ok := cache[tgid]; !ok {
 			cache[tgid] = &models.UserCache{User: u}
@@ -114,6 +174,7 @@ func main() {
 				c.User.TelegramID = tgid
 				c.User.FirstName = update.CallbackQuery.From.FirstName
 				c.User.LastName = update.CallbackQuery.From.LastName
+				c.User.Username = update.CallbackQuery.From.UserName
 			}
 
 			c.Message = update.CallbackQuery.Message
@@ -123,37 +184,85 @@ func main() {
 			c.CallbackID = update.CallbackQuery.ID
 			c.CallbackData = update.CallbackQuery.Data
 
-			go handleCallbackQuery(c)
+			handleAsync(func() { handleCallbackQuery(c) })
 			continue
 		}
 
 		if update.Message != nil {
 			//новий користвуач якого немає ще в нас в базі даних
 			if u.ID == 0 {
-				go serveNewUser(update)
+				handleAsync(func() { serveNewUser(update) })
 				continue
 			}
 
 			//користувач забанений але шось пише боту
 			if u.Status == models.UserBanned {
-				go serveBannedUser(update)
+				handleAsync(func() { serveBannedUser(update) })
 			}
 
 			//користувач надіслав запит на активацію але це ще не активований
 			if u.Status != models.UserApprowed {
-				go serveNonApprovedUser(update)
+				handleAsync(func() { serveNonApprovedUser(update) })
 				continue
 			}
 			c.Message = update.Message
 
-			go serveUser(c)
+			handleAsync(func() { serveUser(c) })
 		}
 	}
+
+	shutdown(srv)
+}
+
+//handleAsync handles bot update in a goroutine which shutdown waits for
+func handleAsync(f func()) {
+
+	inFlight.Add(1)
+	go func() {
+		defer inFlight.Done()
+		f()
+	}()
+}
+
+//shutdown stops receiving bot updates and web requests, waits for in-flight handlers and deliveries and closes DB
+func shutdown(srv *http.Server) {
+
+	log.Println("Shutting down...")
+
+	bot.StopReceivingUpdates()
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	err := srv.Shutdown(ctx)
+	if err != nil {
+		log.Println(fmt.Errorf("web server shutdown: %v", err))
+	}
+
+	done := make(chan struct{})
+	go func() {
+		inFlight.Wait()
+		waitWebhooks()
+		waitNotifications()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		log.Println("Bot handlers and deliveries didn't finish in time")
+	}
+
+	err = db.Close()
+	if err != nil {
+		log.Println(err)
+	}
 }
 
 func initialization() {
 	dbase.InitDB(cfg)
 	initData()
+	initNotifiers()
 }
 
 func initData() {
@@ -196,6 +305,7 @@ func initData() {
 	buttons.Approve = tgbotapi.NewKeyboardButton(models.Approve)
 	buttons.Ban = tgbotapi.NewKeyboardButton(models.Ban)
 	buttons.Unban = tgbotapi.NewKeyboardButton(models.Unban)
+	buttons.Teams = tgbotapi.NewKeyboardButton(models.Teams)
 	buttons.Inbox = tgbotapi.NewKeyboardButton(models.Inbox)
 	buttons.Sent = tgbotapi.NewKeyboardButton(models.Sent)
 	buttons.New = tgbotapi.NewKeyboardButton(models.New)
@@ -210,11 +320,17 @@ func initData() {
 	buttons.History = tgbotapi.NewKeyboardButton(models.History)
 	buttons.Close = tgbotapi.NewKeyboardButton(models.Close)
 	buttons.Reject = tgbotapi.NewKeyboardButton(models.Reject)
+	buttons.Skip = tgbotapi.NewKeyboardButton(models.Skip)
 }
 
 //запропонуємо користувачу зробити запит на активацію в програмі
 func serveNewUser(update tgbotapi.Update) {
 
+	//користувач прийшов за посиланням-запрошенням
+	if acceptInvite(update) {
+		return
+	}
+
 	ut := update.Message.From
 
 	reply := fmt.Sprintf("Hello, %s %s. I can see you are new one here. Would you like to send request to approve your account in Taskeram?\n", ut.FirstName, ut.LastName)
@@ -247,6 +363,10 @@ func serveBannedUser(update tgbotapi.Update) {
 
 func serveNonApprovedUser(update tgbotapi.Update) {
 
+	if acceptInvite(update) {
+		return
+	}
+
 	ut := update.Message.From
 
 	reply := fmt.Sprintf("Hello, %s %s. Keep calm and wait for approval message!\n", ut.FirstName, ut.LastName)
@@ -274,6 +394,16 @@ func serveUser(c *models.UserCache) {
 			return
 		}
 
+		//CSV файл із задачами для імпорту
+		if c.Message.Document != nil && handleImportDocument(c) {
+			return
+		}
+
+		//користувач відповів на повідомлення бота про задачу - зберігаємо відповідь як коментар
+		if c.Message.ReplyToMessage != nil && handleReply(c) {
+			return
+		}
+
 		cm := c.CurrentMenu
 		msg := c.Text
 
@@ -291,6 +421,8 @@ func serveUser(c *models.UserCache) {
 			handleUsersViewBanned(c)
 		} else if cm == models.MenuUsers && msg == models.Edit {
 			handleUsersEdit(c)
+		} else if cm == models.MenuUsers && msg == models.Teams {
+			showTeams(c)
 		} else if cm == models.MenuUsersEdit && msg == models.Back {
 			handleUsers(c)
 		} else if cm == models.MenuUsersEdit && msg == models.Approve {
@@ -373,7 +505,7 @@ func serveUser(c *models.UserCache) {
 			handleSentTasks(c, cm, models.TaskStatusCompleted)
 		} else if cm == models.MenuSentClosed {
 			handleSentTasks(c, cm, models.TaskStatusClosed)
-		} else if cm == models.MenuMain && msg == models.New {
+		} else if cm == models.MenuMain && msg == models.New && can(c.User, permCreateTasks) {
 			handleNew(c)
 		} else if cm == models.MenuNew {
 			handleNew(c)
@@ -399,18 +531,51 @@ func handleCommand(c *models.UserCache) {
 	case "comments":
 		handleTaskComment(c)
 		return
+	case "settings":
+		handleCommandSettings(c)
+		return
+	case "team":
+		handleCommandTeam(c)
+		return
+	case "invite":
+		handleCommandInvite(c)
+		return
+	case "logout":
+		handleCommandLogout(c)
+		return
+	case "backup":
+		handleCommandBackup(c)
+		return
+	case "export":
+		handleCommandExport(c)
+		return
+	case "import":
+		handleCommandImport(c)
+		return
 	}
 }
 
 func handleCommandStart(c *models.UserCache) {
 
-	//команда старт в нас обробляється тільки для адміністратора
-	envID := os.Getenv("TELEGRAM_TASKERAM_ADMIN")
-	if envID == "" {
-		log.Fatal("Env variable TELEGRAM_TASKERAM_ADMIN does not exist")
+	//посилання на задачу t.me/<bot>?start=task_<id>
+	if strings.HasPrefix(c.Arguments, taskStartPrefix) {
+		c.Arguments = strings.TrimPrefix(c.Arguments, taskStartPrefix)
+		handleCommandTask(c)
+		return
+	}
+
+	//запрошення від вже активованого користувача нічого не змінює
+	if c.Arguments != "" && getInviteByCode(c.Arguments).ID != 0 {
+		msg := tgbotapi.NewMessage(c.ChatID, "You already have access to Taskeram")
+		_, err := bot.Send(msg)
+		if err != nil {
+			log.Println(err)
+		}
+		return
 	}
 
-	tgID, err := strconv.Atoi(envID)
+	//команда старт в нас обробляється тільки для адміністратора
+	tgID, err := strconv.Atoi(cfg.Telegram.AdminID)
 	if err != nil {
 		return
 	}
@@ -548,9 +713,13 @@ func handleMain(c *models.UserCache) {
 
 	var kbrd [][]tgbotapi.KeyboardButton
 
-	kbrd = append(kbrd, tgbotapi.NewKeyboardButtonRow(buttons.Inbox, buttons.Sent, buttons.New))
+	if can(c.User, permCreateTasks) {
+		kbrd = append(kbrd, tgbotapi.NewKeyboardButtonRow(buttons.Inbox, buttons.Sent, buttons.New))
+	} else {
+		kbrd = append(kbrd, tgbotapi.NewKeyboardButtonRow(buttons.Inbox, buttons.Sent))
+	}
 
-	if c.User.Admin == 1 {
+	if can(c.User, permViewUsers) {
 		kbrd = append(kbrd, tgbotapi.NewKeyboardButtonRow(buttons.Users))
 	}
 
@@ -569,7 +738,7 @@ func handleMain(c *models.UserCache) {
 
 func handleUsers(c *models.UserCache) {
 
-	if c.User.Admin == 0 {
+	if !can(c.User, permViewUsers) {
 		c.CurrentMenu = models.MenuMain
 		handleMain(c)
 		return
@@ -579,6 +748,9 @@ func handleUsers(c *models.UserCache) {
 
 	btnRow1 := tgbotapi.NewKeyboardButtonRow(buttons.Back)
 	btnRow2 := tgbotapi.NewKeyboardButtonRow(buttons.View, buttons.Edit)
+	if can(c.User, permManageTeams) {
+		btnRow2 = append(btnRow2, buttons.Teams)
+	}
 
 	markup := tgbotapi.NewReplyKeyboard(btnRow1, btnRow2)
 	//markup.Selective = true
@@ -595,7 +767,7 @@ func handleUsers(c *models.UserCache) {
 
 func handleUsersView(c *models.UserCache) {
 
-	if c.User.Admin == 0 {
+	if !can(c.User, permViewUsers) {
 		c.CurrentMenu = models.MenuMain
 		handleMain(c)
 		return
@@ -621,7 +793,7 @@ func handleUsersView(c *models.UserCache) {
 
 func handleUsersViewALl(c *models.UserCache) {
 
-	if c.User.Admin == 0 {
+	if !can(c.User, permViewUsers) {
 		c.CurrentMenu = models.MenuMain
 		handleMain(c)
 		return
@@ -686,7 +858,7 @@ func handleUsersViewALl(c *models.UserCache) {
 
 func handleUsersViewRequests(c *models.UserCache) {
 
-	if c.User.Admin == 0 {
+	if !can(c.User, permViewUsers) {
 		c.CurrentMenu = models.MenuMain
 		handleMain(c)
 		return
@@ -749,7 +921,7 @@ func handleUsersViewRequests(c *models.UserCache) {
 
 func handleUsersViewBanned(c *models.UserCache) {
 
-	if c.User.Admin == 0 {
+	if !can(c.User, permViewUsers) {
 		c.CurrentMenu = models.MenuMain
 		handleMain(c)
 		return
@@ -812,7 +984,7 @@ func handleUsersViewBanned(c *models.UserCache) {
 
 func handleUsersEdit(c *models.UserCache) {
 
-	if c.User.Admin == 0 {
+	if !(can(c.User, permApproveUsers) || can(c.User, permBanUsers)) {
 		c.CurrentMenu = models.MenuMain
 		handleMain(c)
 		return
@@ -822,7 +994,13 @@ func handleUsersEdit(c *models.UserCache) {
 	c.UserSlider.EditingUserIndx = 0
 
 	btnRow1 := tgbotapi.NewKeyboardButtonRow(buttons.Back)
-	btnRow2 := tgbotapi.NewKeyboardButtonRow(buttons.Approve, buttons.Ban, buttons.Unban)
+	var btnRow2 []tgbotapi.KeyboardButton
+	if can(c.User, permApproveUsers) {
+		btnRow2 = append(btnRow2, buttons.Approve)
+	}
+	if can(c.User, permBanUsers) {
+		btnRow2 = append(btnRow2, buttons.Ban, buttons.Unban)
+	}
 
 	markup := tgbotapi.NewReplyKeyboard(btnRow1, btnRow2)
 	//markup.Selective = true
@@ -839,7 +1017,7 @@ func handleUsersEdit(c *models.UserCache) {
 
 func handleUsersEditApprove(c *models.UserCache) {
 
-	if c.User.Admin == 0 {
+	if !can(c.User, permApproveUsers) {
 		c.CurrentMenu = models.MenuMain
 		handleMain(c)
 		return
@@ -890,7 +1068,7 @@ func handleUsersEditApprove(c *models.UserCache) {
 
 		i := 1
 		for rows.Next() {
-			err := rows.Scan(&u.ID, &u.TelegramID, &u.FirstName, &u.LastName)
+			err := dbase.ScanUser(rows, &u)
 			if err != nil {
 				log.Println(err)
 			} else {
@@ -929,8 +1107,9 @@ func handleUsersEditApprove(c *models.UserCache) {
 
 		u := users[editingUser]
 
-		stmt, err := dbase.UpdateUserStatus(cfg)
+		_, err := changeUserStatus(u, c.User, models.UserApprowed, "")
 		if err != nil {
+			log.Println(err)
 			c.UserSlider.EditingUserIndx = 0
 			c.CurrentMenu = models.MenuUsersEdit
 
@@ -945,32 +1124,8 @@ func handleUsersEditApprove(c *models.UserCache) {
 			return
 		}
 
-		timeNow := time.Now().UTC()
-		_, err = stmt.Exec(models.UserApprowed, timeNow, c.User.TelegramID, u.TelegramID)
-		if err != nil {
-			c.CurrentMenu = models.MenuUsersEdit
-
-			msg := tgbotapi.NewMessage(c.ChatID, "Something went wrong while approving user :(")
-			msg.ReplyToMessageID = c.MessageID
-			_, err := bot.Send(msg)
-			if err != nil {
-				log.Println(err)
-			}
-
-			handleUsersEdit(c)
-			return
-		}
-
-		reply := fmt.Sprintf(`Your account has been <b>approved</b> by <a href="tg://user?id=%v">%v %v</a> at %v`, c.User.TelegramID, c.User.FirstName, c.User.LastName, timeNow)
-		msg := tgbotapi.NewMessage(int64(u.TelegramID), reply)
-		msg.ParseMode = "HTML"
-		_, err = bot.Send(msg)
-		if err != nil {
-			log.Println(err)
-		}
-
-		reply = fmt.Sprintf("Account %v %v has been <b>approved</b>", u.FirstName, u.LastName)
-		msg = tgbotapi.NewMessage(c.ChatID, reply)
+		reply := fmt.Sprintf("Account %v %v has been <b>approved</b>", u.FirstName, u.LastName)
+		msg := tgbotapi.NewMessage(c.ChatID, reply)
 		msg.ParseMode = "HTML"
 		_, err = bot.Send(msg)
 		if err != nil {
@@ -1035,7 +1190,7 @@ func handleUsersEditApprove(c *models.UserCache) {
 
 func handleUsersEditBan(c *models.UserCache) {
 
-	if c.User.Admin == 0 {
+	if !can(c.User, permBanUsers) {
 		c.CurrentMenu = models.MenuMain
 		handleMain(c)
 		return
@@ -1128,25 +1283,9 @@ func handleUsersEditBan(c *models.UserCache) {
 
 		u := users[currentUserIndx]
 
-		stmt, err := dbase.UpdateUserStatus(cfg)
-		if err != nil {
-			c.UserSlider.EditingUserIndx = 0
-			c.CurrentMenu = models.MenuUsersEdit
-
-			msg := tgbotapi.NewMessage(c.ChatID, "Something went wrong while banning user :(")
-			msg.ReplyToMessageID = c.MessageID
-			_, err := bot.Send(msg)
-			if err != nil {
-				log.Println(err)
-			}
-
-			handleUsersEdit(c)
-			return
-		}
-
-		timeNow := time.Now().UTC()
-		_, err = stmt.Exec(models.UserBanned, timeNow, c.User.TelegramID, u.TelegramID)
+		_, err := changeUserStatus(u, c.User, models.UserBanned, "")
 		if err != nil {
+			log.Println(err)
 			c.UserSlider.EditingUserIndx = 0
 			c.CurrentMenu = models.MenuUsersEdit
 
@@ -1161,21 +1300,8 @@ func handleUsersEditBan(c *models.UserCache) {
 			return
 		}
 
-		if u.Status != models.UserApprowed {
-			reply = fmt.Sprintf(`Unfortunately your request has been <b>declined</b> by <a href="tg://user?id=%v">%v %v</a> at %v. Try to text to admin`, c.User.TelegramID, c.User.FirstName, c.User.LastName, timeNow)
-		} else {
-			reply = fmt.Sprintf(`Unfortunately your account has been <b>banned</b> by <a href="tg://user?id=%v">%v %v</a> at %v. Try to text to admin`, c.User.TelegramID, c.User.FirstName, c.User.LastName, timeNow)
-		}
-
-		msg := tgbotapi.NewMessage(int64(u.TelegramID), reply)
-		msg.ParseMode = "HTML"
-		_, err = bot.Send(msg)
-		if err != nil {
-			log.Println(err)
-		}
-
 		reply = fmt.Sprintf("Account %v %v has been <b>banned</b>", u.FirstName, u.LastName)
-		msg = tgbotapi.NewMessage(c.ChatID, reply)
+		msg := tgbotapi.NewMessage(c.ChatID, reply)
 		msg.ParseMode = "HTML"
 		_, err = bot.Send(msg)
 		if err != nil {
@@ -1240,7 +1366,7 @@ func handleUsersEditBan(c *models.UserCache) {
 
 func handleUsersEditUnban(c *models.UserCache) {
 
-	if c.User.Admin == 0 {
+	if !can(c.User, permBanUsers) {
 		c.CurrentMenu = models.MenuMain
 		handleMain(c)
 		return
@@ -1331,25 +1457,9 @@ func handleUsersEditUnban(c *models.UserCache) {
 
 		u := users[currentUserIndx]
 
-		stmt, err := dbase.UpdateUserStatus(cfg)
-		if err != nil {
-			c.UserSlider.EditingUserIndx = 0
-			c.CurrentMenu = models.MenuUsersEdit
-
-			msg := tgbotapi.NewMessage(c.ChatID, "Something went wrong while unbanning user :(")
-			msg.ReplyToMessageID = c.MessageID
-			_, err := bot.Send(msg)
-			if err != nil {
-				log.Println(err)
-			}
-
-			handleUsersEdit(c)
-			return
-		}
-
-		timeNow := time.Now().UTC()
-		_, err = stmt.Exec(models.UserApprowed, timeNow, c.User.TelegramID, u.TelegramID)
+		_, err := changeUserStatus(u, c.User, models.UserApprowed, "")
 		if err != nil {
+			log.Println(err)
 			c.UserSlider.EditingUserIndx = 0
 			c.CurrentMenu = models.MenuUsersEdit
 
@@ -1364,16 +1474,8 @@ func handleUsersEditUnban(c *models.UserCache) {
 			return
 		}
 
-		reply := fmt.Sprintf(`Your account has been <b>unbanned</b> by <a href="tg://user?id=%v">%v %v</a> at %v. Try to text to admin`, c.User.TelegramID, c.User.FirstName, c.User.LastName, timeNow)
-		msg := tgbotapi.NewMessage(int64(u.TelegramID), reply)
-		msg.ParseMode = "HTML"
-		_, err = bot.Send(msg)
-		if err != nil {
-			log.Println(err)
-		}
-
-		reply = fmt.Sprintf("Account %v %v has been <b>unbanned</b>", u.FirstName, u.LastName)
-		msg = tgbotapi.NewMessage(c.ChatID, reply)
+		reply := fmt.Sprintf("Account %v %v has been <b>unbanned</b>", u.FirstName, u.LastName)
+		msg := tgbotapi.NewMessage(c.ChatID, reply)
 		msg.ParseMode = "HTML"
 		_, err = bot.Send(msg)
 		if err != nil {
@@ -1858,7 +1960,7 @@ func handleNew(c *models.UserCache) {
 				err := dbase.ScanUser(rows, &u)
 				if err != nil {
 					log.Println(err)
-				} else {
+				} else if canAssignTask(c.User, u) {
 					users[i] = u
 					i++
 				}
@@ -2036,9 +2138,73 @@ func handleNew(c *models.UserCache) {
 			return
 		default:
 			c.NewTask.Description = c.Text
+			c.NewTask.Step = models.NewTaskStepDueDate
+
+			c.Text = ""
+			handleNew(c)
+			return
+		}
+	case models.NewTaskStepDueDate:
+		switch c.Text {
+		case models.Cancel:
+			c.NewTask = &models.Task{}
+			c.CurrentMenu = models.MenuMain
+			handleMain(c)
+			return
+		case models.Back:
+			c.NewTask.Description = ""
+			c.NewTask.Step = models.NewTaskStepDescription
+
+			c.Text = ""
+			handleNew(c)
+			return
+		case "":
+			toUser := c.NewTask.ToUser
+
+			row1 := tgbotapi.NewKeyboardButtonRow(buttons.Back, buttons.Cancel, buttons.Skip)
+			markup := tgbotapi.NewReplyKeyboard(row1)
+
+			reply := fmt.Sprintf(`<b>New Task</b>
+			To user: <a href="tg://user?id=%v">%v %v</a>
+			Title: %v
+			Description: %v
+			
+			Enter due date <i>(YYYY-MM-DD)</i> or press Skip:`, toUser.TelegramID, toUser.FirstName, toUser.LastName, c.NewTask.Title, c.NewTask.Description)
+
+			msg := tgbotapi.NewMessage(c.ChatID, reply)
+			msg.ParseMode = "HTML"
+			msg.ReplyMarkup = markup
+			_, err := bot.Send(msg)
+			if err != nil {
+				log.Println(err)
+			}
+
+			return
+		default:
+			if c.Text == models.Skip {
+				c.NewTask.DueDate = time.Time{}
+			} else {
+				dueDate, err := time.Parse(models.DueDateLayout, c.Text)
+				if err != nil {
+					msg := tgbotapi.NewMessage(c.ChatID, fmt.Sprintf("%v - wrong date, use YYYY-MM-DD format", c.Text))
+					msg.ReplyToMessageID = c.MessageID
+					_, err := bot.Send(msg)
+					if err != nil {
+						log.Println(err)
+					}
+					return
+				}
+				c.NewTask.DueDate = dueDate
+			}
+
 			c.NewTask.Step = models.NewTaskStepSaveToDB
 			toUser := c.NewTask.ToUser
 
+			dueDate := "-"
+			if !c.NewTask.DueDate.IsZero() {
+				dueDate = c.NewTask.DueDate.Format(models.DueDateLayout)
+			}
+
 			row1 := tgbotapi.NewKeyboardButtonRow(buttons.Back, buttons.Cancel, buttons.Save)
 			markup := tgbotapi.NewReplyKeyboard(row1)
 			//markup.Selective = true
@@ -2046,7 +2212,8 @@ func handleNew(c *models.UserCache) {
 			reply := fmt.Sprintf(`<b>New Task</b>
 			To user: <a href="tg://user?id=%v">%v %v</a>
 			Title: %v
-			Description: %v`, toUser.TelegramID, toUser.FirstName, toUser.LastName, c.NewTask.Title, c.Text)
+			Description: %v
+			Due date: %v`, toUser.TelegramID, toUser.FirstName, toUser.LastName, c.NewTask.Title, c.NewTask.Description, dueDate)
 			msg := tgbotapi.NewMessage(c.ChatID, reply)
 			msg.ParseMode = "HTML"
 			msg.ReplyMarkup = markup
@@ -2064,8 +2231,8 @@ func handleNew(c *models.UserCache) {
 			handleMain(c)
 			return
 		case models.Back:
-			c.NewTask.Description = ""
-			c.NewTask.Step = models.NewTaskStepDescription
+			c.NewTask.DueDate = time.Time{}
+			c.NewTask.Step = models.NewTaskStepDueDate
 
 			c.Text = ""
 			handleNew(c)
@@ -2102,6 +2269,10 @@ func handleNew(c *models.UserCache) {
 				ChangedBy:   c.User.TelegramID,
 				Title:       c.NewTask.Title,
 				Description: c.NewTask.Description,
+				DueDate: models.NullTime{
+					Time:  c.NewTask.DueDate,
+					Valid: !c.NewTask.DueDate.IsZero(),
+				},
 			}
 
 			res, err := dbase.ExecInsertTask(stmt, nt)
@@ -2224,6 +2395,25 @@ func handleComment(c *models.UserCache) {
 		return
 	}
 
+	rows, err := dbase.SelectTasksByIDUserTelegramID(cfg, c.TaskID, c.User.TelegramID)
+	if err != nil {
+		log.Println(err)
+	} else {
+		var t models.DbTasks
+
+		if rows.Next() {
+			err := dbase.ScanTask(rows, &t)
+			if err != nil {
+				log.Println(err)
+			}
+		}
+		rows.Close()
+
+		if t.ID != 0 {
+			informNewComment(t, c.User, c.Text)
+		}
+	}
+
 	handleMain(c)
 }
 
@@ -2241,6 +2431,10 @@ func handleCallbackQuery(c *models.UserCache) {
 	do := xs[0]
 
 	switch do {
+	case models.ImportCreate:
+		importCreate(c)
+	case models.ImportCancel:
+		importCancel(c)
 	case models.NewUserCancel:
 		newUserCancel(c)
 	case models.NewUserRequest:
@@ -2385,6 +2579,10 @@ func handleCallbackQuery(c *models.UserCache) {
 
 			handleMain(c)
 		}
+	case models.Settings:
+		if len(xs) == 2 {
+			toggleSetting(c, xs[1])
+		}
 	case models.Confirm:
 		if len(xs) == 2 {
 			authConfirm(c, xs[1])
@@ -2472,8 +2670,11 @@ func newUserAdd(c *models.UserCache) {
 	nu := models.DbUsers{
 		TelegramID: c.User.TelegramID,
 		FirstName:  c.User.FirstName,
+		LastName:   c.User.LastName,
+		Username:   c.User.Username,
 		Status:     models.UserRequested,
 		Admin:      0,
+		Role:       models.RoleMember,
 		ChangedBy:  c.User.TelegramID,
 		ChangedAt: models.NullTime{
 			Time:  time.Now(),
@@ -2558,6 +2759,17 @@ func newUserDecline(c *models.UserCache, ID string) {
 
 	var cbConfig tgbotapi.CallbackConfig
 
+	if !can(c.User, permApproveUsers) {
+		cbConfig.CallbackQueryID = c.CallbackID
+		cbConfig.Text = "You have no permission to moderate users"
+		cbConfig.ShowAlert = true
+		_, err := bot.AnswerCallbackQuery(cbConfig)
+		if err != nil {
+			log.Println(err)
+		}
+		return
+	}
+
 	userID, err := strconv.Atoi(ID)
 	if err != nil {
 		msg := tgbotapi.NewMessage(c.ChatID, "Sorry, something went wrong")
@@ -2621,26 +2833,13 @@ func newUserDecline(c *models.UserCache, ID string) {
 		return
 	}
 
-	stmt, err := dbase.UpdateUserStatus(cfg)
-	if err != nil {
-		return
-	}
-
-	timeNow := time.Now().UTC()
-	_, err = stmt.Exec(models.UserBanned, timeNow, c.User.TelegramID, userID)
-	if err != nil {
-		log.Println(err)
-	}
-
-	reply := fmt.Sprintf(`Unfortunately your request has been <b>declined</b> by <a href="tg://user?id=%v">%v %v</a> at %v. Try to text to admin`, c.User.TelegramID, c.User.FirstName, c.User.LastName, timeNow)
-	msg := tgbotapi.NewMessage(int64(userID), reply)
-	msg.ParseMode = "HTML"
-	_, err = bot.Send(msg)
+	_, err = changeUserStatus(u, c.User, models.UserBanned, "")
 	if err != nil {
 		log.Println(err)
+		return
 	}
 
-	reply = fmt.Sprintf(`<a href="tg://user?id=%v">%v %v</a> has been <b>banned</b>`, u.TelegramID, u.FirstName, u.LastName)
+	reply := fmt.Sprintf(`<a href="tg://user?id=%v">%v %v</a> has been <b>banned</b>`, u.TelegramID, u.FirstName, u.LastName)
 	msgEdited := tgbotapi.NewEditMessageText(c.ChatID, c.MessageID, reply)
 	msgEdited.ParseMode = "HTML"
 	_, err = bot.Send(msgEdited)
@@ -2660,6 +2859,17 @@ func newUserAccpet(c *models.UserCache, ID string) {
 
 	var cbConfig tgbotapi.CallbackConfig
 
+	if !can(c.User, permApproveUsers) {
+		cbConfig.CallbackQueryID = c.CallbackID
+		cbConfig.Text = "You have no permission to moderate users"
+		cbConfig.ShowAlert = true
+		_, err := bot.AnswerCallbackQuery(cbConfig)
+		if err != nil {
+			log.Println(err)
+		}
+		return
+	}
+
 	userID, err := strconv.Atoi(ID)
 	if err != nil {
 		msg := tgbotapi.NewMessage(c.ChatID, "Sorry, something went wrong")
@@ -2723,13 +2933,7 @@ func newUserAccpet(c *models.UserCache, ID string) {
 		return
 	}
 
-	stmt, err := dbase.UpdateUserStatus(cfg)
-	if err != nil {
-		return
-	}
-
-	timeNow := time.Now().UTC()
-	_, err = stmt.Exec(models.UserApprowed, timeNow, c.User.TelegramID, u.TelegramID)
+	u, err = changeUserStatus(u, c.User, models.UserApprowed, "")
 	if err != nil {
 		reply := fmt.Sprintf(`Can't approve '<a href="tg://user?id=%v">%v %v</a>. Err:%v`, u.TelegramID, u.FirstName, u.LastName, err.Error())
 		msg := tgbotapi.NewMessage(c.ChatID, reply)
@@ -2741,15 +2945,7 @@ func newUserAccpet(c *models.UserCache, ID string) {
 		return
 	}
 
-	reply := fmt.Sprintf(`Your account has been <b>approved</b> by <a href="tg://user?id=%v">%v %v</a> at %v`, c.User.TelegramID, c.User.FirstName, c.User.LastName, timeNow)
-	msg := tgbotapi.NewMessage(int64(userID), reply)
-	msg.ParseMode = "HTML"
-	_, err = bot.Send(msg)
-	if err != nil {
-		log.Println(err)
-	}
-
-	reply = fmt.Sprintf(`<a href="tg://user?id=%v">%v %v</a> has been <b>approved</b>`, u.TelegramID, u.FirstName, u.LastName)
+	reply := fmt.Sprintf(`<a href="tg://user?id=%v">%v %v</a> has been <b>approved</b>`, u.TelegramID, u.FirstName, u.LastName)
 	msgEdited := tgbotapi.NewEditMessageText(c.ChatID, c.MessageID, reply)
 	msgEdited.ParseMode = "HTML"
 	_, err = bot.Send(msgEdited)
@@ -2865,17 +3061,10 @@ func changeStatus(c *models.UserCache, action string) {
 	}
 	rows.Close()
 
-	var taskType string
-
-	if t.ToUser == tguID {
-		taskType = "Inbox"
-	} else {
-		taskType = "Sent"
-	}
-
-	rule := taskRules[taskType][t.Status]
+	taskType := taskTypeOf(t, tguID)
 
-	if !rule.Contains(action) {
+	t, err = changeTaskStatus(t, c.User, action)
+	if err == errStatusForbidden {
 		cbConfig.Text = fmt.Sprintf("It isn't allowed to change the status to %v for Task #%v", newStatus, t.ID)
 		cbConfig.ShowAlert = true
 		cbConfig.CallbackQueryID = c.CallbackID
@@ -2886,24 +3075,8 @@ func changeStatus(c *models.UserCache, action string) {
 
 		updateTaskInlineKeyboard(c.ChatID, c.MessageID, c.TaskID, taskType, t.Status)
 		return
-	}
-
-	stmt, err := dbase.UpdateTaskStatus(cfg)
-	if err != nil {
-		cbConfig.Text = "Something went wrong while updating Task status"
-		cbConfig.ShowAlert = true
-		cbConfig.CallbackQueryID = c.CallbackID
-		_, err := bot.AnswerCallbackQuery(cbConfig)
-		if err != nil {
-			log.Println(err)
-		}
-		return
-	}
-
-	changedAt := time.Now().UTC()
-
-	_, err = stmt.Exec(newStatus, changedAt, c.User.TelegramID, t.ID)
-	if err != nil {
+	} else if err != nil {
+		log.Println(err)
 		cbConfig.Text = "Something went wrong while updating Task status"
 		cbConfig.ShowAlert = true
 		cbConfig.CallbackQueryID = c.CallbackID
@@ -2922,21 +3095,6 @@ func changeStatus(c *models.UserCache, action string) {
 	}
 
 	updateTaskInlineKeyboard(c.ChatID, c.MessageID, t.ID, taskType, newStatus)
-
-	chatID := t.FromUser
-	if taskType == "Sent" {
-		chatID = t.ToUser
-	}
-
-	reply := fmt.Sprintf(`Task <b>#%v</b>
-		status was changed to %v
-		by <a href="tg://user?id=%v">%v %v</a> at %v`, t.ID, newStatus, c.User.TelegramID, c.User.FirstName, c.User.LastName, changedAt)
-	msg := tgbotapi.NewMessage(int64(chatID), reply)
-	msg.ParseMode = "HTML"
-	_, err = bot.Send(msg)
-	if err != nil {
-		log.Println(err)
-	}
 }
 
 func updateTaskInlineKeyboard(chatID int64, msgID int, taskID int, taskType string, status string) {
@@ -2947,6 +3105,9 @@ func updateTaskInlineKeyboard(chatID int64, msgID int, taskID int, taskType stri
 		btnRow = append(btnRow, tgbotapi.NewInlineKeyboardButtonData(val, fmt.Sprintf("%v|%v", val, taskID)))
 	}
 	markup := tgbotapi.NewInlineKeyboardMarkup(btnRow)
+	if btnRowWeb := taskWebButtons(taskID); btnRowWeb != nil {
+		markup.InlineKeyboard = append(markup.InlineKeyboard, btnRowWeb)
+	}
 
 	inlKbrd := tgbotapi.NewEditMessageReplyMarkup(chatID, msgID, markup)
 	_, err := bot.Send(inlKbrd)
@@ -2957,7 +3118,7 @@ func updateTaskInlineKeyboard(chatID int64, msgID int, taskID int, taskType stri
 
 func showTask(c *models.UserCache) {
 
-	rows, err := dbase.SelectTasksByIDUserTelegramID(cfg, c.TaskID, c.User.TelegramID)
+	rows, err := dbase.SelectTasksByID(cfg, c.TaskID)
 	//rows, err := db.Query(`
 	//	SELECT
 	//		t.id,
@@ -3001,7 +3162,7 @@ func showTask(c *models.UserCache) {
 		return
 	}
 
-	if t.ToUser != c.User.TelegramID && t.FromUser != c.User.TelegramID {
+	if !canViewTask(c.User, t) {
 		msg := tgbotapi.NewMessage(c.ChatID, "Access denided")
 		_, err := bot.Send(msg)
 		if err != nil {
@@ -3014,7 +3175,11 @@ func showTask(c *models.UserCache) {
 	<i>status:</i> <b>%v</b> (%v)
 	
 	<i>title:</i> %v
-	<i>description:</i> %v`, t.ID, t.Status, t.ChangedAt.Time, t.Title, t.Description)
+	<i>description:</i> %v`, t.ID, t.Status, t.ChangedAt.Time, t.Title, mentionsToBotHTML(t.Description, resolveMentions(t.Description)))
+
+	if t.DueDate.Valid {
+		reply += fmt.Sprintf("\n<i>due date:</i> %v", t.DueDate.Time.Format(models.DueDateLayout))
+	}
 
 	var taskType string
 	if t.ToUser == c.User.TelegramID {
@@ -3033,6 +3198,10 @@ func showTask(c *models.UserCache) {
 
 	kbdReply = append(kbdReply, btnRow)
 
+	if btnRowWeb := taskWebButtons(t.ID); btnRowWeb != nil {
+		kbdReply = append(kbdReply, btnRowWeb)
+	}
+
 	//ми прийшли сюди з меню задач. Запсукаємо слайдер
 	if c.TaskSlider.EditingTaskIndx != 0 {
 		var btnRowNavigation []tgbotapi.InlineKeyboardButton
@@ -3070,6 +3239,7 @@ func showTask(c *models.UserCache) {
 	}
 
 	c.CurrentMessage = msgSent.MessageID
+	saveTaskMessage(msgSent, t.ID)
 }
 
 func showHistory(c *models.UserCache) {
@@ -3227,7 +3397,7 @@ func showComments(c *models.UserCache) {
 
 	for key, val := range xs {
 		reply += fmt.Sprintf(`<b>%v. Comment:</b> %v
-		by <a href="tg://user?id=%v">%v %v</a> at %v`, key+1, val.CDb.Comment, val.UDb.TelegramID, val.UDb.FirstName, val.UDb.LastName, val.CDb.Date.Time)
+		by <a href="tg://user?id=%v">%v %v</a> at %v`, key+1, mentionsToBotHTML(val.CDb.Comment, resolveMentions(val.CDb.Comment)), val.UDb.TelegramID, val.UDb.FirstName, val.UDb.LastName, val.CDb.Date.Time)
 		reply += fmt.Sprintln()
 	}
 
@@ -3335,16 +3505,24 @@ func addComment(c *models.UserCache) {
 
 func informNewTask(newTaskID int64, task models.DbTasks, fromUser models.DbUsers, toUser models.DbUsers)  {
 
+	task.ID = int(newTaskID)
+	fireWebhooks(webhookPayload{Event: models.WebhookTaskCreated, Task: &task, By: &fromUser})
+
+	mentioned := resolveMentions(task.Description)
+	description := mentionsToBotHTML(task.Description, mentioned)
+
 	reply := fmt.Sprintf(`<b>Task #%v</b>
 				To user: <a href="tg://user?id=%v">%v %v</a>
 				Title: %v
-				Description: %v`, newTaskID, toUser.TelegramID, toUser.FirstName, toUser.LastName, task.Title, task.Description)
+				Description: %v`, newTaskID, toUser.TelegramID, toUser.FirstName, toUser.LastName, task.Title, description)
 
 	msg := tgbotapi.NewMessage(int64(fromUser.TelegramID), reply)
 	msg.ParseMode = "HTML"
-	_, err := bot.Send(msg)
+	msgSent, err := bot.Send(msg)
 	if err != nil {
 		log.Println(err)
+	} else {
+		saveTaskMessage(msgSent, int(newTaskID))
 	}
 
 	if fromUser.TelegramID != toUser.TelegramID {
@@ -3353,30 +3531,236 @@ func informNewTask(newTaskID int64, task models.DbTasks, fromUser models.DbUsers
 				Description: %v
 
 				Task manager: <a href="tg://user?id=%v">%v %v</a>
-				Created at: %v`, newTaskID, task.Title, task.Description, fromUser.TelegramID, fromUser.FirstName, fromUser.LastName, task.ChangedAt)
-		msg = tgbotapi.NewMessage(int64(toUser.TelegramID), reply)
-		msg.ParseMode = "HTML"
+				Created at: %v`, newTaskID, task.Title, description, fromUser.TelegramID, fromUser.FirstName, fromUser.LastName, task.ChangedAt.Time)
+		if task.DueDate.Valid {
+			reply += fmt.Sprintf("\nDue date: %v", task.DueDate.Time.Format(models.DueDateLayout))
+		}
+		notifyUser(toUser.TelegramID, models.NotifyNewTask, task.ID, fmt.Sprintf("New Task #%v %v", task.ID, task.Title), reply)
+	}
+
+	informMentions(task, fromUser, task.Description, mentioned)
+}
+
+//informStatusChange tells participants of the Task, except the one who changed it, and webhooks that the status was changed
+func informStatusChange(t models.DbTasks, by models.DbUsers, oldStatus string) {
+
+	var recipients []int
+
+	fireWebhooks(webhookPayload{Event: models.WebhookTaskStatusChanged, Task: &t, By: &by, OldStatus: oldStatus})
+
+	if t.FromUser != by.TelegramID {
+		recipients = append(recipients, t.FromUser)
+	}
+
+	if t.ToUser != by.TelegramID && t.ToUser != t.FromUser {
+		recipients = append(recipients, t.ToUser)
+	}
+
+	reply := fmt.Sprintf(`Task <b>#%v</b>
+		status was changed to %v
+		by <a href="tg://user?id=%v">%v %v</a> at %v`, t.ID, t.Status, by.TelegramID, html.EscapeString(by.FirstName), html.EscapeString(by.LastName), t.ChangedAt.Time)
+
+	for _, tgid := range recipients {
+		notifyUser(tgid, models.NotifyStatus, t.ID, fmt.Sprintf("Task #%v is %v", t.ID, t.Status), reply)
+	}
+}
+
+//saveTaskComment makes the comment the last one of the Task and adds it to the Task comments.
+//update_task_comments trigger adds it only if the text differs from the last comment, so a repeated text is added here
+func saveTaskComment(t models.DbTasks, by models.DbUsers, comment string) error {
+
+	now := time.Now().UTC()
+
+	stmt, err := dbase.UpdateTaskComment(cfg)
+	if err != nil {
+		return err
+	}
+
+	_, err = stmt.Exec(comment, now, by.TelegramID, t.ID)
+	if err != nil {
+		return err
+	}
+
+	if comment != t.Comment {
+		return nil
+	}
+
+	stmt, err = dbase.InsertTaskComment(cfg)
+	if err != nil {
+		return err
+	}
+
+	_, err = dbase.ExecInsertTaskComment(stmt, models.DbTaskComments{
+		TaskID:  t.ID,
+		UserID:  by.TelegramID,
+		Date:    models.NullTime{Time: now, Valid: true},
+		Comment: comment,
+	})
+
+	return err
+}
+
+//informNewComment forwards a fresh Task comment to the other participants of the Task
+func informNewComment(t models.DbTasks, author models.DbUsers, comment string) {
+
+	var recipients []int
+
+	fireWebhooks(webhookPayload{Event: models.WebhookTaskCommented, Task: &t, By: &author, Comment: comment})
+
+	if t.FromUser != author.TelegramID {
+		recipients = append(recipients, t.FromUser)
+	}
+
+	if t.ToUser != author.TelegramID && t.ToUser != t.FromUser {
+		recipients = append(recipients, t.ToUser)
+	}
+
+	mentioned := resolveMentions(comment)
+
+	reply := fmt.Sprintf(`<strong>Task #%v</strong> %v
+	<b>Comment</b> by <a href="tg://user?id=%v">%v %v</a>:
+	%v
+
+	<i>reply to this message to answer</i>`, t.ID, html.EscapeString(t.Title), author.TelegramID, html.EscapeString(author.FirstName), html.EscapeString(author.LastName), mentionsToBotHTML(comment, mentioned))
+
+	for _, tgid := range recipients {
+		notifyUser(tgid, models.NotifyComment, t.ID, fmt.Sprintf("New comment in Task #%v %v", t.ID, t.Title), reply)
+	}
+
+	informMentions(t, author, comment, mentioned)
+}
+
+//saveTaskMessage remembers which Task a sent bot message is about,
+//so a Telegram reply to that message can be turned into a Task comment
+func saveTaskMessage(m tgbotapi.Message, taskID int) {
+
+	if m.Chat == nil || m.MessageID == 0 || taskID == 0 {
+		return
+	}
+
+	stmt, err := dbase.InsertTaskMessage(cfg)
+	if err != nil {
+		log.Println(fmt.Errorf("InsertTaskMessage: %v", err))
+		return
+	}
+
+	tm := models.DbTaskMessages{
+		TaskID:    taskID,
+		ChatID:    m.Chat.ID,
+		MessageID: m.MessageID,
+	}
+
+	_, err = dbase.ExecInsertTaskMessage(stmt, tm)
+	if err != nil {
+		log.Println(fmt.Errorf("ExecInsertTaskMessage: %v", err))
+	}
+}
+
+//handleReply stores a Telegram reply to a bot message about a Task as a Task comment.
+//Returns false if the replied message isn't about any Task, so it can be served as usual
+func handleReply(c *models.UserCache) bool {
+
+	var tm models.DbTaskMessages
+	var t models.DbTasks
+
+	rows, err := dbase.SelectTaskMessage(cfg, c.ChatID, c.Message.ReplyToMessage.MessageID)
+	if err != nil {
+		log.Println(fmt.Errorf("SelectTaskMessage: %v", err))
+		return false
+	}
+
+	if rows.Next() {
+		err := dbase.ScanTaskMessage(rows, &tm)
+		if err != nil {
+			log.Println(err)
+		}
+	}
+	rows.Close()
+
+	if tm.TaskID == 0 {
+		return false
+	}
+
+	if c.Text == "" {
+		msg := tgbotapi.NewMessage(c.ChatID, "I don't see any sense to add an empty comment, sorry.:(")
+		msg.ReplyToMessageID = c.MessageID
 		_, err := bot.Send(msg)
 		if err != nil {
-			fmt.Println(err)
+			log.Println(err)
 		}
+		return true
+	}
+
+	rows, err = dbase.SelectTasksByIDUserTelegramID(cfg, tm.TaskID, c.User.TelegramID)
+	if err != nil {
+		msg := tgbotapi.NewMessage(c.ChatID, "Something went wrong while selecting Task info")
+		msg.ReplyToMessageID = c.MessageID
+		_, err := bot.Send(msg)
+		if err != nil {
+			log.Println(err)
+		}
+		return true
+	}
+
+	if rows.Next() {
+		err := dbase.ScanTask(rows, &t)
+		if err != nil {
+			log.Println(err)
+		}
+	}
+	rows.Close()
+
+	if t.ID == 0 {
+		msg := tgbotapi.NewMessage(c.ChatID, "Access denided")
+		msg.ReplyToMessageID = c.MessageID
+		_, err := bot.Send(msg)
+		if err != nil {
+			log.Println(err)
+		}
+		return true
 	}
-}
 
-func LoadConfiguration(file string) (models.Config, error) {
-	var config models.Config
+	var taskType string
 
-	cfgFile, err := ioutil.ReadFile(file)
+	if t.ToUser == c.User.TelegramID {
+		taskType = "Inbox"
+	} else {
+		taskType = "Sent"
+	}
+
+	rule := taskRules[taskType][t.Status]
+
+	if !rule.Contains(models.Comment) {
+		msg := tgbotapi.NewMessage(c.ChatID, fmt.Sprintf("It isn't allowed to comment Task #%v", t.ID))
+		msg.ReplyToMessageID = c.MessageID
+		_, err := bot.Send(msg)
+		if err != nil {
+			log.Println(err)
+		}
+		return true
+	}
+
+	err = saveTaskComment(t, c.User, c.Text)
 	if err != nil {
 		log.Println(err)
-		return config, err
+
+		msg := tgbotapi.NewMessage(c.ChatID, "Something went wrong while updating Task comment :(")
+		msg.ReplyToMessageID = c.MessageID
+		_, err := bot.Send(msg)
+		if err != nil {
+			log.Println(err)
+		}
+		return true
 	}
 
-	err = json.Unmarshal(cfgFile, &config)
+	msg := tgbotapi.NewMessage(c.ChatID, fmt.Sprintf("Comment has been added to Task #%v", t.ID))
+	msg.ReplyToMessageID = c.MessageID
+	_, err = bot.Send(msg)
 	if err != nil {
 		log.Println(err)
-		return config, err
 	}
 
-	return config, nil
-}
\ No newline at end of file
+	informNewComment(t, c.User, c.Text)
+
+	return true
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/slevchyk/taskeram/dbase"
+	"github.com/slevchyk/taskeram/models"
+	"github.com/slevchyk/taskeram/notifier"
+)
+
+const (
+	testAdminID    = 1
+	testAssigneeID = 2
+)
+
+func TestMain(m *testing.M) {
+
+	log.SetOutput(ioutil.Discard)
+	os.Exit(m.Run())
+}
+
+//setupTestDB creates temp sqlite DB with the admin, one more approved user and a New Task from the admin to the user
+func setupTestDB(t *testing.T) models.DbTasks {
+
+	c, err := dbase.Open("sqlite:" + filepath.Join(t.TempDir(), "taskeram"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.DB.Close() })
+
+	cfg = models.Config{}
+	cfg.Database = c.Database
+	cfg.DB = c.DB
+	cfg.Telegram.AdminID = "1"
+
+	dbase.InitDB(cfg)
+	initData()
+
+	stmt, err := dbase.InsertUser(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = dbase.ExecInsertUser(stmt, models.DbUsers{
+		TelegramID: testAssigneeID,
+		FirstName:  "Assignee",
+		Status:     models.UserApprowed,
+		ChangedAt:  models.NullTime{Time: time.Now().UTC(), Valid: true},
+		ChangedBy:  testAdminID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	task, err := insertTask(models.DbTasks{
+		FromUser:  testAdminID,
+		ToUser:    testAssigneeID,
+		Status:    models.TaskStatusNew,
+		ChangedAt: models.NullTime{Time: time.Now().UTC(), Valid: true},
+		ChangedBy: testAdminID,
+		Title:     "Test task",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return task
+}
+
+func taskStatus(t *testing.T, taskID int) string {
+
+	var task models.DbTasks
+
+	rows, err := dbase.SelectTasksByID(cfg, taskID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		t.Fatalf("Task #%v not found", taskID)
+	}
+
+	err = dbase.ScanTask(rows, &task)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return task.Status
+}
+
+func countRows(t *testing.T, table string) int {
+
+	n, err := dbase.CountRows(cfg, table)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return n
+}
+
+//sentMessages keeps messages which would be sent to Telegram
+type sentMessages struct {
+	mu   sync.Mutex
+	sent map[int][]notifier.Message
+}
+
+func (s *sentMessages) Channel() string {
+	return notifier.ChannelTelegram
+}
+
+func (s *sentMessages) Notify(to notifier.Recipient, m notifier.Message) error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sent[to.TelegramID] = append(s.sent[to.TelegramID], m)
+	return nil
+}
+
+//recordNotifications replaces notifiers with one which keeps Telegram messages.
+//Call notifyQueue.Wait before checking them
+func recordNotifications(t *testing.T) *sentMessages {
+
+	s := &sentMessages{sent: make(map[int][]notifier.Message)}
+
+	notifiers = map[string]notifier.Notifier{notifier.ChannelTelegram: s}
+	notifyQueue = notifier.NewQueue(1, 1, time.Millisecond, nil)
+	t.Cleanup(func() { notifiers = nil })
+
+	return s
+}
+
+//addTestUser adds one more approved user with the role
+func addTestUser(t *testing.T, tgid int, firstName string, role string) models.DbUsers {
+
+	stmt, err := dbase.InsertUser(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = dbase.ExecInsertUser(stmt, models.DbUsers{TelegramID: tgid, FirstName: firstName, Status: models.UserApprowed, Role: role})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return dbase.GetUserByTelegramID(cfg, tgid)
+}
+
+func TestSaveTaskCommentRepeated(t *testing.T) {
+
+	task := setupTestDB(t)
+
+	for i, comment := range []string{"on it", "on it"} {
+		task, err := getTask(task.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = saveTaskComment(task, dbase.GetUserByTelegramID(cfg, testAssigneeID), comment)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if n := countRows(t, "task_comments"); n != i+1 {
+			t.Errorf("after comment %v: %v comments are saved, want %v", i+1, n, i+1)
+		}
+	}
+}
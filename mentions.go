@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/slevchyk/taskeram/dbase"
+	"github.com/slevchyk/taskeram/models"
+	"github.com/slevchyk/taskeram/utils"
+)
+
+//resolveMentions matches "@username", "@firstname" and "#user<telegram id>" mentions in the text against approved users.
+//Mentions that can't be matched, or match more than one user by first name, are skipped
+func resolveMentions(text string) map[string]models.DbUsers {
+
+	if len(utils.FindMentions(text)) == 0 {
+		return nil
+	}
+
+	return matchMentions(text, selectMentionUsers())
+}
+
+//selectMentionUsers returns approved users, mentions are matched against them
+func selectMentionUsers() []models.DbUsers {
+
+	var u models.DbUsers
+	var users []models.DbUsers
+
+	rows, err := dbase.SelectUsersByStatus(cfg, models.UserApprowed)
+	if err != nil {
+		log.Println(fmt.Errorf("selectMentionUsers: %v", err))
+		return nil
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		err := dbase.ScanUser(rows, &u)
+		if err != nil {
+			log.Println(err)
+		} else {
+			users = append(users, u)
+		}
+	}
+
+	return users
+}
+
+//matchMentions is resolveMentions against already selected users, e.g. when a page shows many texts
+func matchMentions(text string, users []models.DbUsers) map[string]models.DbUsers {
+
+	mentions := utils.FindMentions(text)
+	if len(mentions) == 0 {
+		return nil
+	}
+
+	result := make(map[string]models.DbUsers)
+
+	for _, mention := range mentions {
+		if u, ok := matchMention(mention, users); ok {
+			result[mention] = u
+		}
+	}
+
+	return result
+}
+
+func matchMention(mention string, users []models.DbUsers) (models.DbUsers, bool) {
+
+	var matched []models.DbUsers
+
+	if strings.HasPrefix(mention, "#user") {
+		tgid, err := strconv.Atoi(strings.TrimPrefix(mention, "#user"))
+		if err != nil {
+			return models.DbUsers{}, false
+		}
+
+		for _, u := range users {
+			if u.TelegramID == tgid {
+				return u, true
+			}
+		}
+
+		return models.DbUsers{}, false
+	}
+
+	name := strings.TrimPrefix(mention, "@")
+
+	//Telegram username is unique, so it has priority over first name
+	for _, u := range users {
+		if u.Username != "" && strings.EqualFold(u.Username, name) {
+			return u, true
+		}
+	}
+
+	for _, u := range users {
+		if strings.EqualFold(u.FirstName, name) {
+			matched = append(matched, u)
+		}
+	}
+
+	if len(matched) != 1 {
+		return models.DbUsers{}, false
+	}
+
+	return matched[0], true
+}
+
+//mentionsToBotHTML prepares text with mentions for a bot message with HTML parse mode
+func mentionsToBotHTML(text string, users map[string]models.DbUsers) string {
+
+	return utils.RenderMentions(text, users, func(u models.DbUsers, mention string) string {
+		return fmt.Sprintf(`<a href="tg://user?id=%v">%v</a>`, u.TelegramID, mention)
+	})
+}
+
+//mentionsToWebHTML prepares text with mentions for web app templates. Users are selected once per page with selectMentionUsers
+func mentionsToWebHTML(text string, users []models.DbUsers) template.HTML {
+
+	return template.HTML(utils.RenderMentions(text, matchMentions(text, users), func(u models.DbUsers, mention string) string {
+		return fmt.Sprintf(`<a href="/user?id=%v">%v</a>`, u.TelegramID, mention)
+	}))
+}
+
+//informMentions notifies mentioned users who aren't participants of the Task.
+//Users who can't see the Task aren't notified, so its text doesn't leak to them
+func informMentions(t models.DbTasks, author models.DbUsers, text string, users map[string]models.DbUsers) {
+
+	informed := make(map[int]bool)
+
+	for _, u := range users {
+		if u.TelegramID == t.FromUser || u.TelegramID == t.ToUser || u.TelegramID == author.TelegramID {
+			continue
+		}
+
+		if informed[u.TelegramID] || !canViewTask(u, t) {
+			continue
+		}
+		informed[u.TelegramID] = true
+
+		reply := fmt.Sprintf("<b>You were mentioned</b> in Task <b>#%v</b> %v\nby <a href=\"tg://user?id=%v\">%v %v</a>:\n%v",
+			t.ID, html.EscapeString(t.Title), author.TelegramID, html.EscapeString(author.FirstName), html.EscapeString(author.LastName), mentionsToBotHTML(text, users))
+		notifyUser(u.TelegramID, models.NotifyMention, t.ID, fmt.Sprintf("You were mentioned in Task #%v %v", t.ID, t.Title), reply)
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/slevchyk/taskeram/dbase"
+	"github.com/slevchyk/taskeram/models"
+)
+
+func TestInformMentions(t *testing.T) {
+
+	task := setupTestDB(t)
+	sent := recordNotifications(t)
+
+	addTestUser(t, 3, "Stranger", models.RoleMember)
+	addTestUser(t, 4, "Boss", models.RoleManager)
+
+	task.Title = "a <b> task"
+	text := "@Stranger @Boss please look"
+
+	informMentions(task, dbase.GetUserByTelegramID(cfg, testAssigneeID), text, resolveMentions(text))
+	notifyQueue.Wait()
+
+	if len(sent.sent[3]) != 0 {
+		t.Errorf("user who can't see the Task is notified: %+v", sent.sent[3])
+	}
+
+	if len(sent.sent[4]) != 1 {
+		t.Fatalf("manager got %v messages, want 1", len(sent.sent[4]))
+	}
+
+	m := sent.sent[4][0].Text
+	if !strings.Contains(m, "a &lt;b&gt; task") || strings.Contains(m, "\t") {
+		t.Errorf("message isn't escaped or is indented: %q", m)
+	}
+}
@@ -12,6 +12,7 @@ const (
 	Approve  = "Approve"
 	Ban      = "Ban"
 	Unban    = "Unban"
+	Teams    = "Teams"
 	Previous = "Previous"
 	Next     = "Next"
 	Inbox    = "Inbox"
@@ -26,6 +27,8 @@ const (
 	Reject   = "Reject"
 	Comment  = "Comment"
 	Confirm = "Confirm"
+	Skip     = "Skip"
+	Settings = "Settings"
 )
 
 const (
@@ -56,6 +59,7 @@ const (
 	NewTaskStepUser = iota
 	NewTaskStepTitle
 	NewTaskStepDescription
+	NewTaskStepDueDate
 	NewTaskStepSaveToDB
 )
 
@@ -73,9 +77,63 @@ const (
 	UserBanned    = "Banned"
 )
 
+const (
+	RoleAdmin   = "admin"
+	RoleManager = "manager"
+	RoleMember  = "member"
+	RoleGuest   = "guest"
+)
+
 const (
 	NewUserRequest = "NewUserRequest"
 	NewUserCancel  = "NewUserCancel"
 	NewUserAccept  = "NewUserAccept"
 	NewUserDecline = "NewUserDecline"
-)
\ No newline at end of file
+)
+
+const (
+	ImportCreate = "ImportCreate"
+	ImportCancel = "ImportCancel"
+)
+
+const (
+	DigestOff    = ""
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+)
+
+const (
+	NotifyNewTask = "newtask"
+	NotifyStatus  = "status"
+	NotifyComment = "comment"
+	NotifyMention = "mention"
+	NotifyDigest  = "digest"
+)
+
+const (
+	DeliverySent    = "sent"
+	DeliveryFailed  = "failed"
+	DeliverySkipped = "skipped"
+)
+
+const (
+	WebhookTaskCreated       = "task.created"
+	WebhookTaskStatusChanged = "task.status_changed"
+	WebhookTaskCommented     = "task.commented"
+	WebhookUserApproved      = "user.approved"
+)
+
+const DueDateLayout = "2006-01-02"
+
+//Task priorities, Tasks without priority are normal
+const (
+	PriorityLow    = "low"
+	PriorityNormal = "normal"
+	PriorityHigh   = "high"
+)
+
+//login methods of the web app, both are available if the method isn't set in config
+const (
+	LoginWidget = "widget"
+	LoginBot    = "bot"
+)
@@ -12,13 +12,39 @@ type Config struct {
 		Token   string `json:"token"`
 		AdminID string `json:"admin_id"`
 	} `json:"telegram"`
+	SMTP struct {
+		Host     string `json:"host"`
+		Port     int    `json:"port"`
+		User     string `json:"user"`
+		Password string `json:"password"`
+		From     string `json:"from"`
+	} `json:"smtp"`
+	Web struct {
+		//URL is the public base URL of the web app, e.g. https://tasks.example.com. It is used for links from the bot
+		URL string `json:"url"`
+		//Login is the login method: widget (Telegram Login Widget), bot (confirm in bot) or empty for both
+		Login string `json:"login"`
+		//Listen is the address of the web server, :80 by default
+		Listen string `json:"listen"`
+		//TLS is used if both CertFile and KeyFile are set or SelfSigned is true (for development only)
+		CertFile   string `json:"cert_file"`
+		KeyFile    string `json:"key_file"`
+		SelfSigned bool   `json:"self_signed"`
+		//ReadTimeout and WriteTimeout are in seconds, 0 means default
+		ReadTimeout  int `json:"read_timeout"`
+		WriteTimeout int `json:"write_timeout"`
+	} `json:"web"`
 	Database struct {
 		Type     string `json:"type"`
 		Name     string `json:"name"`
 		User     string `json:"user"`
 		Password string `json:"password"`
+		//Host, Port and SSLMode are used by postgres only
+		Host    string `json:"host"`
+		Port    int    `json:"port"`
+		SSLMode string `json:"sslmode"`
 	} `json:"database"`
-	DB *sql.DB
+	DB *sql.DB `json:"-"`
 }
 
 //NullTime special type for scan sql rows with Null data for time type variables
@@ -52,6 +78,8 @@ type DbUsers struct {
 	ChangedAt  NullTime `json:"changed_at"`
 	Comment    string   `json:"comment"`
 	Userpic    string   `json:"userpic"`
+	Username   string   `json:"username"`
+	Role       string   `json:"role"`
 }
 
 type DbTasks struct {
@@ -68,6 +96,8 @@ type DbTasks struct {
 	CommentedBy int      `json:"commented_by"`
 	Images      string   `json:"images"`
 	Documents   string   `json:"documents"`
+	DueDate     NullTime `json:"due_date"`
+	Priority    string   `json:"priority"`
 }
 
 type DbTaskHistory struct {
@@ -79,11 +109,11 @@ type DbTaskHistory struct {
 }
 
 type DbTaskComments struct {
-	ID      int
-	TaskID  int
-	UserID  int
-	Date    NullTime
-	Comment string
+	ID      int      `json:"id"`
+	TaskID  int      `json:"task_id"`
+	UserID  int      `json:"user_id"`
+	Date    NullTime `json:"date"`
+	Comment string   `json:"comment"`
 }
 
 type userSlider struct {
@@ -113,6 +143,8 @@ type UserCache struct {
 	UserSlider     userSlider
 	TaskSlider     taskSlider
 	Message        *tgbotapi.Message
+	//ImportRows are Tasks from CSV file waiting for the user to confirm the import
+	ImportRows []ImportRow
 }
 
 type Task struct {
@@ -120,6 +152,7 @@ type Task struct {
 	ToUser      *DbUsers
 	Title       string
 	Description string
+	DueDate     time.Time
 }
 
 type AllowedActions []string
@@ -148,6 +181,7 @@ type Buttons struct {
 	Approve   tgbotapi.KeyboardButton
 	Ban       tgbotapi.KeyboardButton
 	Unban     tgbotapi.KeyboardButton
+	Teams     tgbotapi.KeyboardButton
 	Inbox     tgbotapi.KeyboardButton
 	Sent      tgbotapi.KeyboardButton
 	New       tgbotapi.KeyboardButton
@@ -162,6 +196,7 @@ type Buttons struct {
 	History   tgbotapi.KeyboardButton
 	Close     tgbotapi.KeyboardButton
 	Reject    tgbotapi.KeyboardButton
+	Skip      tgbotapi.KeyboardButton
 }
 
 type DbHistory struct {
@@ -176,6 +211,88 @@ type DbComment struct {
 	UDb DbUsers
 }
 
+type DbTaskMessages struct {
+	ID        int
+	TaskID    int
+	ChatID    int64
+	MessageID int
+}
+
+type DbUserSettings struct {
+	ID            int
+	TelegramID    int
+	Timezone      string
+	Digest        string
+	DigestTime    string
+	DigestWeekday int
+	DigestSentAt  NullTime
+	NotifyNewTask int
+	NotifyStatus  int
+	NotifyComment int
+	NotifyMention int
+	QuietFrom     string
+	QuietTo       string
+	Email         string
+	WebhookURL    string
+}
+
+type DbNotificationChannels struct {
+	ID         int
+	TelegramID int
+	Event      string
+	Channel    string
+}
+
+//DbAPITokens keeps SHA-256 hashes of the tokens, the token itself is shown to the user only once.
+//calendar_tokens have the same columns
+type DbAPITokens struct {
+	ID         int
+	TelegramID int
+	Token      string
+	CreatedAt  NullTime
+}
+
+type DbWebhooks struct {
+	ID        int
+	URL       string
+	Secret    string
+	//Events is a comma separated list of events the webhook is subscribed to
+	Events    string
+	Active    int
+	CreatedBy int
+	CreatedAt NullTime
+}
+
+type DbWebhookDeliveries struct {
+	ID           int
+	WebhookID    int
+	Event        string
+	Payload      string
+	Status       string
+	Attempts     int
+	ResponseCode int
+	Error        string
+	CreatedAt    NullTime
+}
+
+type DbNotificationLog struct {
+	ID         int
+	TelegramID int
+	Channel    string
+	Event      string
+	TaskID     int
+	Status     string
+	Attempts   int
+	Error      string
+	CreatedAt  NullTime
+}
+
+type DbTaskMutes struct {
+	ID         int
+	TelegramID int
+	TaskID     int
+}
+
 type DbAuth struct {
 	ID         int
 	Token      string
@@ -186,6 +303,7 @@ type DbAuth struct {
 
 type DbSessions struct {
 	ID           int
+	//UUID keeps hash of the session token, the token itself is only in the user cookie
 	UUID         string
 	TelegramID   int
 	StartedAt    NullTime
@@ -193,3 +311,71 @@ type DbSessions struct {
 	IP           string
 	UserAgent    string
 }
+
+type DbTeams struct {
+	ID        int
+	Name      string
+	CreatedBy int
+	CreatedAt NullTime
+}
+
+type DbTeamMembers struct {
+	ID         int
+	TeamID     int
+	TelegramID int
+	Lead       int
+}
+
+type DbTeamMutes struct {
+	ID         int
+	TelegramID int
+	TeamID     int
+}
+
+type DbUserHistory struct {
+	ID        int
+	UserID    int
+	Status    string
+	Role      string
+	Comment   string
+	ChangedBy int
+	ChangedAt NullTime
+}
+
+type DbInvites struct {
+	ID        int
+	Code      string
+	Role      string
+	TeamID    int
+	MaxUses   int
+	Uses      int
+	ExpiresAt NullTime
+	CreatedBy int
+	CreatedAt NullTime
+	Revoked   int
+}
+
+//DbDump is a portable copy of DB made by taskeram backup and loaded by taskeram restore
+type DbDump struct {
+	Version   int               `json:"version"`
+	CreatedAt time.Time         `json:"created_at"`
+	Database  string            `json:"database"`
+	Tables    []DbDumpTable     `json:"tables"`
+	Userpics  map[string][]byte `json:"userpics"`
+}
+
+//DbDumpTable holds rows of a table. Values of TimeColumns are RFC 3339 strings in JSON
+type DbDumpTable struct {
+	Name        string          `json:"name"`
+	Columns     []string        `json:"columns"`
+	TimeColumns []string        `json:"time_columns,omitempty"`
+	Rows        [][]interface{} `json:"rows"`
+}
+
+//ImportRow is a Task read from CSV file. Line is the line of the file, Error explains why the Task can't be created
+type ImportRow struct {
+	Line   int
+	Task   DbTasks
+	ToUser DbUsers
+	Error  string
+}
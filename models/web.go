@@ -11,6 +11,8 @@ type TplNavBar struct {
 	LoggedIn bool
 	User     DbUsers
 	MainMenu []TplMainMenu
+	//CSRF is the anti-forgery token which every form changing data has to send
+	CSRF string
 }
 
 //TasksRow is a part of TplTasks struct for levels.gohtml
@@ -19,6 +21,7 @@ type TasksRow struct {
 	Task        DbTasks
 	ToUser      DbUsers
 	FromUser    DbUsers
+	Description template.HTML
 }
 
 //TplTasks data type for tasks.gohtml
@@ -26,10 +29,14 @@ type TplTasks struct {
 	NavBar TplNavBar
 	Tabs template.HTML
 	Rows []TasksRow
+	Type string
+	Status string
 }
 
 type TplLogin struct {
-	Users []DbUsers
+	BotName string
+	Widget  bool
+	Bot     bool
 }
 
 type TplActions struct {
@@ -44,8 +51,11 @@ type TplTask struct {
 	ToUser DbUsers
 	FromUser DbUsers
 	CommentedBy DbUsers
+	Description template.HTML
+	Comment template.HTML
 	Actions []TplActions
 	Users   []DbUsers
+	BotLink string
 }
 
 type TplIndex struct {
@@ -55,5 +65,100 @@ type TplIndex struct {
 type TplUser struct {
 	NavBar TplNavBar
 	User DbUsers
+	Role string
+	Roles []string
+	Settings DbUserSettings
+	MutedTasks []string
+	MutedTeams []TplTeamMute
+	EventChannels []TplEventChannels
+	Deliveries []DbNotificationLog
+	HasAPIToken bool
+	APIToken string
+	HasCalendarToken bool
+	CalendarURL string
+	Sessions []TplSession
+}
+
+type TplSession struct {
+	Session DbSessions
+	Current bool
+}
+
+type TplEventChannels struct {
+	Event    string
+	Telegram bool
+	Email    bool
+	Webhook  bool
+}
+
+type TplTeamMute struct {
+	Team  DbTeams
+	Muted bool
+}
+
+
+type TplWebhooks struct {
+	NavBar TplNavBar
+	Webhooks []DbWebhooks
+	Events []string
+	Webhook DbWebhooks
+	Deliveries []DbWebhookDeliveries
 }
 
+type TplTeams struct {
+	NavBar TplNavBar
+	Teams []TplTeam
+	Users []DbUsers
+}
+
+type TplTeam struct {
+	Team DbTeams
+	Members []TplTeamMember
+}
+
+type TplTeamMember struct {
+	Member DbTeamMembers
+	User DbUsers
+}
+
+type TplAdminUsers struct {
+	NavBar TplNavBar
+	Status string
+	Statuses []string
+	Search string
+	Users []DbUsers
+	User DbUsers
+	Role string
+	Roles []string
+	History []TplUserHistory
+	CanApprove bool
+	CanBan bool
+}
+
+type TplUserHistory struct {
+	History DbUserHistory
+	ChangedBy DbUsers
+}
+
+type TplInvites struct {
+	NavBar TplNavBar
+	Invites []TplInvite
+	Roles []string
+	Teams []DbTeams
+}
+
+type TplInvite struct {
+	Invite DbInvites
+	Link string
+	Team DbTeams
+	CreatedBy DbUsers
+}
+
+type TplImport struct {
+	NavBar TplNavBar
+	CSV string
+	Rows []ImportRow
+	Errors int
+	Error string
+	Users []DbUsers
+}
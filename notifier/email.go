@@ -0,0 +1,46 @@
+package notifier
+
+import (
+	"fmt"
+	"mime"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+//Email sends notifications as plain text letters through an SMTP server
+type Email struct {
+	//Addr is SMTP server address as host:port
+	Addr string
+	From string
+	//Auth may be nil if the server doesn't need authentication
+	Auth smtp.Auth
+}
+
+func (e Email) Channel() string {
+	return ChannelEmail
+}
+
+func (e Email) Notify(to Recipient, m Message) error {
+
+	if to.Email == "" {
+		return ErrNoAddress
+	}
+
+	var letter strings.Builder
+
+	fmt.Fprintf(&letter, "From: %v\r\n", e.From)
+	fmt.Fprintf(&letter, "To: %v\r\n", to.Email)
+	fmt.Fprintf(&letter, "Subject: %v\r\n", mime.QEncoding.Encode("utf-8", m.Subject))
+	fmt.Fprintf(&letter, "Date: %v\r\n", time.Now().Format(time.RFC1123Z))
+	letter.WriteString("MIME-Version: 1.0\r\n")
+	letter.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	letter.WriteString("\r\n")
+	letter.WriteString(strings.Replace(m.PlainText(), "\n", "\r\n", -1))
+	letter.WriteString("\r\n")
+	if m.URL != "" {
+		fmt.Fprintf(&letter, "\r\n%v\r\n", m.URL)
+	}
+
+	return smtp.SendMail(e.Addr, e.Auth, e.From, []string{to.Email}, []byte(letter.String()))
+}
@@ -0,0 +1,56 @@
+package notifier
+
+import (
+	"errors"
+	"html"
+	"regexp"
+	"strings"
+)
+
+const (
+	ChannelTelegram = "telegram"
+	ChannelEmail    = "email"
+	ChannelWebhook  = "webhook"
+)
+
+//ErrNoAddress is returned when the recipient hasn't set up the address for a channel. It isn't worth a retry
+var ErrNoAddress = errors.New("recipient has no address for the channel")
+
+//Notifier delivers notifications over one channel
+type Notifier interface {
+	Channel() string
+	Notify(to Recipient, m Message) error
+}
+
+//Recipient holds user addresses for all channels
+type Recipient struct {
+	TelegramID int
+	Email      string
+	WebhookURL string
+	//Silent asks to deliver without a sound, e.g. at night
+	Silent bool
+}
+
+//Message is a notification about some event. Text is formatted with Telegram HTML tags
+type Message struct {
+	Event   string
+	TaskID  int
+	Subject string
+	Text    string
+	//URL opens the Task in the web app. It is empty if the web app isn't published
+	URL string
+}
+
+var tagRegexp = regexp.MustCompile(`<[^>]*>`)
+
+//PlainText returns message text without HTML tags and source indentation
+func (m Message) PlainText() string {
+
+	var lines []string
+
+	for _, val := range strings.Split(tagRegexp.ReplaceAllString(m.Text, ""), "\n") {
+		lines = append(lines, strings.TrimSpace(html.UnescapeString(val)))
+	}
+
+	return strings.Join(lines, "\n")
+}
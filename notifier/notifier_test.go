@@ -0,0 +1,276 @@
+package notifier
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+//smtpStub is an SMTP server which accepts every letter and keeps it
+type smtpStub struct {
+	ln      net.Listener
+	mu      sync.Mutex
+	letters []string
+}
+
+func newSMTPStub(t *testing.T) *smtpStub {
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	s := &smtpStub{ln: ln}
+	go s.serve()
+
+	return s
+}
+
+func (s *smtpStub) serve() {
+
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.session(conn)
+	}
+}
+
+func (s *smtpStub) session(conn net.Conn) {
+
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	reply := func(line string) {
+		conn.Write([]byte(line + "\r\n"))
+	}
+
+	reply("220 localhost ESMTP stub")
+
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+
+		switch cmd := strings.ToUpper(strings.Fields(line + " ")[0]); cmd {
+		case "EHLO", "HELO":
+			reply("250 localhost")
+		case "MAIL", "RCPT", "RSET", "NOOP":
+			reply("250 OK")
+		case "DATA":
+			reply("354 go ahead")
+
+			var letter strings.Builder
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				letter.WriteString(line)
+			}
+
+			s.mu.Lock()
+			s.letters = append(s.letters, letter.String())
+			s.mu.Unlock()
+
+			reply("250 queued")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 unknown command")
+		}
+	}
+}
+
+func TestEmail(t *testing.T) {
+
+	s := newSMTPStub(t)
+	e := Email{Addr: s.ln.Addr().String(), From: "taskeram@example.com"}
+
+	err := e.Notify(Recipient{TelegramID: 1}, Message{Subject: "Task #1"})
+	if err != ErrNoAddress {
+		t.Errorf("recipient without e-mail: got %v, want %v", err, ErrNoAddress)
+	}
+
+	m := Message{
+		Subject: "Task #1 is Closed",
+		Text:    "Task <b>#1</b>\n\t\tstatus was changed to Closed &amp; done",
+		URL:     "https://taskeram.example.com/tasks/1",
+	}
+
+	err = e.Notify(Recipient{TelegramID: 1, Email: "user@example.com"}, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.letters) != 1 {
+		t.Fatalf("server got %v letters, want 1", len(s.letters))
+	}
+
+	for _, want := range []string{"To: user@example.com\r\n", "Subject: Task #1 is Closed\r\n", "Task #1\r\nstatus was changed to Closed & done\r\n", m.URL} {
+		if !strings.Contains(s.letters[0], want) {
+			t.Errorf("letter doesn't contain %q:\n%v", want, s.letters[0])
+		}
+	}
+}
+
+func TestWebhook(t *testing.T) {
+
+	var got WebhookPayload
+	fail := true
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail {
+			http.Error(w, "down", http.StatusBadGateway)
+			return
+		}
+
+		err := json.NewDecoder(r.Body).Decode(&got)
+		if err != nil || r.Header.Get("Content-Type") != "application/json" {
+			http.Error(w, "bad payload", http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+
+	wh := Webhook{Client: srv.Client()}
+	to := Recipient{TelegramID: 7, WebhookURL: srv.URL}
+	m := Message{Event: "status", TaskID: 1, Subject: "Task #1 is Closed", Text: "Task <b>#1</b> is closed"}
+
+	err := wh.Notify(Recipient{TelegramID: 7}, m)
+	if err != ErrNoAddress {
+		t.Errorf("recipient without URL: got %v, want %v", err, ErrNoAddress)
+	}
+
+	err = wh.Notify(to, m)
+	if err == nil {
+		t.Error("error response of the receiver isn't reported")
+	}
+
+	fail = false
+	err = wh.Notify(to, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Event != "status" || got.TaskID != 1 || got.TelegramID != 7 || got.Text != "Task #1 is closed" {
+		t.Errorf("receiver got %+v", got)
+	}
+}
+
+//flaky fails the first attempts and counts all of them
+type flaky struct {
+	mu       sync.Mutex
+	failures int
+	calls    int
+}
+
+func (f *flaky) Channel() string {
+	return "flaky"
+}
+
+func (f *flaky) Notify(to Recipient, m Message) error {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls++
+	if to.TelegramID == 0 {
+		return ErrNoAddress
+	}
+	if f.calls <= f.failures {
+		return errors.New("temporary failure")
+	}
+
+	return nil
+}
+
+func TestQueue(t *testing.T) {
+
+	type result struct {
+		attempts int
+		err      error
+	}
+
+	var mu sync.Mutex
+	results := make(map[string]result)
+
+	q := NewQueue(2, 3, time.Millisecond, func(n Notifier, to Recipient, m Message, attempts int, err error) {
+		mu.Lock()
+		results[m.Subject] = result{attempts, err}
+		mu.Unlock()
+	})
+
+	tests := []struct {
+		subject  string
+		n        *flaky
+		to       Recipient
+		attempts int
+		failed   bool
+	}{
+		{"delivered", &flaky{}, Recipient{TelegramID: 1}, 1, false},
+		{"retried", &flaky{failures: 2}, Recipient{TelegramID: 1}, 3, false},
+		{"failed", &flaky{failures: 5}, Recipient{TelegramID: 1}, 3, true},
+		{"no address", &flaky{failures: 5}, Recipient{}, 1, true},
+	}
+
+	for _, tt := range tests {
+		q.Enqueue(tt.n, tt.to, Message{Subject: tt.subject})
+	}
+	q.Wait()
+
+	for _, tt := range tests {
+		r, ok := results[tt.subject]
+		if !ok {
+			t.Errorf("%v: OnDone isn't called", tt.subject)
+			continue
+		}
+
+		if r.attempts != tt.attempts || tt.n.calls != tt.attempts || (r.err != nil) != tt.failed {
+			t.Errorf("%v: %v attempts, %v calls, error %v", tt.subject, r.attempts, tt.n.calls, r.err)
+		}
+	}
+}
+
+func TestQueueFullRetry(t *testing.T) {
+
+	var attempts []int
+
+	q := &Queue{
+		jobs: make(chan delivery, 1),
+		OnDone: func(n Notifier, to Recipient, m Message, tries int, err error) {
+			if err == nil {
+				t.Error("dropped retry is reported without error")
+			}
+			attempts = append(attempts, tries)
+		},
+	}
+
+	q.pending.Add(2)
+	q.retry(delivery{n: &flaky{}, attempt: 2}, errors.New("temporary failure"))
+	q.retry(delivery{n: &flaky{}, attempt: 2}, errors.New("temporary failure"))
+
+	if len(q.jobs) != 1 || len(attempts) != 1 || attempts[0] != 1 {
+		t.Fatalf("%v retries are queued, dropped after attempts %v", len(q.jobs), attempts)
+	}
+
+	<-q.jobs
+	q.pending.Done()
+	q.Wait()
+}
@@ -0,0 +1,93 @@
+package notifier
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+//Queue delivers messages in background, so senders don't wait for slow or failing channels.
+//Failed attempts are retried with a doubling pause without holding up a worker. A retry is dropped if the queue is full
+type Queue struct {
+	Attempts int
+	Pause    time.Duration
+	//OnDone is called after the last attempt of every message with the number of attempts and the last error
+	OnDone func(n Notifier, to Recipient, m Message, attempts int, err error)
+
+	jobs    chan delivery
+	pending sync.WaitGroup
+}
+
+//delivery is a message waiting for its next attempt
+type delivery struct {
+	n       Notifier
+	to      Recipient
+	m       Message
+	attempt int
+	pause   time.Duration
+}
+
+//NewQueue starts workers which deliver messages from the queue
+func NewQueue(workers int, attempts int, pause time.Duration, onDone func(n Notifier, to Recipient, m Message, attempts int, err error)) *Queue {
+
+	q := &Queue{
+		Attempts: attempts,
+		Pause:    pause,
+		OnDone:   onDone,
+		jobs:     make(chan delivery, 100),
+	}
+
+	for i := 0; i < workers; i++ {
+		go q.work()
+	}
+
+	return q
+}
+
+//Enqueue adds the message for delivery with the notifier. It waits only if the queue is full
+func (q *Queue) Enqueue(n Notifier, to Recipient, m Message) {
+
+	q.pending.Add(1)
+	q.jobs <- delivery{n: n, to: to, m: m, attempt: 1, pause: q.Pause}
+}
+
+//Wait waits until all enqueued messages are delivered or out of attempts
+func (q *Queue) Wait() {
+
+	q.pending.Wait()
+}
+
+func (q *Queue) work() {
+
+	for d := range q.jobs {
+		err := d.n.Notify(d.to, d.m)
+		if err != nil && err != ErrNoAddress && d.attempt < q.Attempts {
+			retry := d
+			retry.attempt++
+			retry.pause *= 2
+			time.AfterFunc(d.pause, func() { q.retry(retry, err) })
+			continue
+		}
+
+		q.done(d, d.attempt, err)
+	}
+}
+
+//retry puts the delivery back to the queue. If the queue is full, the delivery is given up with the last error,
+//so timers don't pile up waiting for a free place
+func (q *Queue) retry(d delivery, err error) {
+
+	select {
+	case q.jobs <- d:
+	default:
+		q.done(d, d.attempt-1, fmt.Errorf("%v, no retry as the queue is full", err))
+	}
+}
+
+func (q *Queue) done(d delivery, attempts int, err error) {
+
+	if q.OnDone != nil {
+		q.OnDone(d.n, d.to, d.m, attempts, err)
+	}
+	q.pending.Done()
+}
@@ -0,0 +1,41 @@
+package notifier
+
+import (
+	"gopkg.in/telegram-bot-api.v4"
+)
+
+//Telegram sends notifications as bot messages
+type Telegram struct {
+	Bot *tgbotapi.BotAPI
+	//OnSent is called for every delivered message, e.g. to bind it to the Task
+	OnSent func(m tgbotapi.Message, taskID int)
+}
+
+func (t Telegram) Channel() string {
+	return ChannelTelegram
+}
+
+func (t Telegram) Notify(to Recipient, m Message) error {
+
+	if to.TelegramID == 0 {
+		return ErrNoAddress
+	}
+
+	msg := tgbotapi.NewMessage(int64(to.TelegramID), m.Text)
+	msg.ParseMode = "HTML"
+	msg.DisableNotification = to.Silent
+	if m.URL != "" {
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonURL("Open in web", m.URL)))
+	}
+
+	msgSent, err := t.Bot.Send(msg)
+	if err != nil {
+		return err
+	}
+
+	if t.OnSent != nil {
+		t.OnSent(msgSent, m.TaskID)
+	}
+
+	return nil
+}
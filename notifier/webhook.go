@@ -0,0 +1,82 @@
+package notifier
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+//SignatureHeader carries HMAC-SHA256 of the request body signed with the webhook secret
+const SignatureHeader = "X-Taskeram-Signature"
+
+//Sign returns the SignatureHeader value for the body, e.g. "sha256=5d4f..."
+func Sign(secret string, body []byte) string {
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+//Webhook posts notifications as JSON to the URL set by the user
+type Webhook struct {
+	//Client may be nil, then a client with 10 seconds timeout is used
+	Client *http.Client
+}
+
+//WebhookPayload is the JSON body of a webhook request
+type WebhookPayload struct {
+	Event      string    `json:"event"`
+	TaskID     int       `json:"task_id,omitempty"`
+	TelegramID int       `json:"telegram_id"`
+	Subject    string    `json:"subject"`
+	Text       string    `json:"text"`
+	URL        string    `json:"url,omitempty"`
+	SentAt     time.Time `json:"sent_at"`
+}
+
+func (wh Webhook) Channel() string {
+	return ChannelWebhook
+}
+
+func (wh Webhook) Notify(to Recipient, m Message) error {
+
+	if to.WebhookURL == "" {
+		return ErrNoAddress
+	}
+
+	client := wh.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	body, err := json.Marshal(WebhookPayload{
+		Event:      m.Event,
+		TaskID:     m.TaskID,
+		TelegramID: to.TelegramID,
+		Subject:    m.Subject,
+		Text:       m.PlainText(),
+		URL:        m.URL,
+		SentAt:     time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Post(to.WebhookURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with %v", resp.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/smtp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/slevchyk/taskeram/dbase"
+	"github.com/slevchyk/taskeram/models"
+	"github.com/slevchyk/taskeram/notifier"
+	"gopkg.in/telegram-bot-api.v4"
+)
+
+const (
+	notifyAttempts = 3
+	notifyPause    = 2 * time.Second
+	notifyWorkers  = 4
+	notifyTimeout  = 10 * time.Second
+)
+
+var notifiers map[string]notifier.Notifier
+
+//notifyQueue delivers notifications in background, shutdown waits for it
+var notifyQueue *notifier.Queue
+
+//initNotifiers sets up notification channels. E-mail is available only if SMTP server is configured
+func initNotifiers() {
+
+	notifiers = make(map[string]notifier.Notifier)
+	notifyQueue = notifier.NewQueue(notifyWorkers, notifyAttempts, notifyPause, onNotifyDone)
+
+	notifiers[notifier.ChannelTelegram] = notifier.Telegram{Bot: bot, OnSent: saveTaskMessage}
+	notifiers[notifier.ChannelWebhook] = notifier.Webhook{}
+
+	if cfg.SMTP.Host != "" {
+		port := cfg.SMTP.Port
+		if port == 0 {
+			port = 25
+		}
+
+		var auth smtp.Auth
+		if cfg.SMTP.User != "" {
+			auth = smtp.PlainAuth("", cfg.SMTP.User, cfg.SMTP.Password, cfg.SMTP.Host)
+		}
+
+		notifiers[notifier.ChannelEmail] = notifier.Email{
+			Addr: cfg.SMTP.Host + ":" + strconv.Itoa(port),
+			From: cfg.SMTP.From,
+			Auth: auth,
+		}
+	}
+}
+
+//initCLINotifiers sets up notification channels for command line tools, so their changes are notified like the bot ones.
+//The bot isn't running there, so Telegram is connected by the first message
+func initCLINotifiers() {
+
+	initNotifiers()
+	notifiers[notifier.ChannelTelegram] = &telegramOnDemand{}
+}
+
+//telegramOnDemand is Telegram channel which connects the bot when the first message is sent.
+//Commands which don't notify anybody don't call Telegram at all, and unreachable Telegram holds them up for limited attempts only
+type telegramOnDemand struct {
+	once sync.Once
+	t    notifier.Telegram
+	err  error
+}
+
+func (n *telegramOnDemand) Channel() string {
+	return notifier.ChannelTelegram
+}
+
+func (n *telegramOnDemand) Notify(to notifier.Recipient, m notifier.Message) error {
+
+	n.once.Do(func() {
+		n.t.Bot, n.err = tgbotapi.NewBotAPIWithClient(cfg.Telegram.Token, &http.Client{Timeout: notifyTimeout})
+		n.t.OnSent = saveTaskMessage
+	})
+
+	if n.err != nil {
+		return fmt.Errorf("can't connect to Telegram: %v", n.err)
+	}
+
+	return n.t.Notify(to, m)
+}
+
+//waitNotifications waits until queued notifications are delivered or out of attempts, so the process doesn't exit before
+func waitNotifications() {
+
+	if notifyQueue != nil {
+		notifyQueue.Wait()
+	}
+}
+
+//notifyUser is the single dispatcher for all notifications pushed to users.
+//It skips events the user has turned off, muted Tasks and Tasks within muted teams, queues delivery over the channels
+//the user has chosen for the event, silently during quiet hours, and logs every delivery when it's done.
+//Text is formatted with Telegram HTML tags. Returns true if the message is queued for at least one channel
+func notifyUser(tgid int, event string, taskID int, subject string, text string) bool {
+
+	s := dbase.GetUserSettings(cfg, tgid)
+
+	if !wantsEvent(s, event) {
+		return false
+	}
+
+	if taskID != 0 && event != models.NotifyMention && (isTaskMuted(tgid, taskID) || isTaskTeamMuted(tgid, taskID)) {
+		return false
+	}
+
+	to := notifier.Recipient{
+		TelegramID: tgid,
+		Email:      s.Email,
+		WebhookURL: s.WebhookURL,
+		Silent:     isQuietTime(s, time.Now()),
+	}
+
+	m := notifier.Message{
+		Event:   event,
+		TaskID:  taskID,
+		Subject: subject,
+		Text:    text,
+		URL:     taskWebURL(taskID),
+	}
+
+	queued := false
+
+	for _, channel := range eventChannels(tgid)[event] {
+		n, ok := notifiers[channel]
+		if !ok {
+			continue
+		}
+
+		notifyQueue.Enqueue(n, to, m)
+		queued = true
+	}
+
+	return queued
+}
+
+//onNotifyDone logs the result of the notification delivered by notifyQueue
+func onNotifyDone(n notifier.Notifier, to notifier.Recipient, m notifier.Message, attempts int, err error) {
+
+	if err != nil && err != notifier.ErrNoAddress {
+		log.Println(fmt.Errorf("notify %v about %v by %v: %v", to.TelegramID, m.Event, n.Channel(), err))
+	}
+
+	logDelivery(to.TelegramID, n.Channel(), m, attempts, err)
+}
+
+//eventChannels returns channels chosen by the user for every event. Telegram is used if nothing is chosen
+func eventChannels(tgid int) map[string][]string {
+
+	var nc models.DbNotificationChannels
+
+	channels := make(map[string][]string)
+
+	rows, err := dbase.SelectNotificationChannelsByTelegramID(cfg, tgid)
+	if err != nil {
+		log.Println(fmt.Errorf("SelectNotificationChannelsByTelegramID: %v", err))
+	} else {
+		for rows.Next() {
+			err := dbase.ScanNotificationChannel(rows, &nc)
+			if err != nil {
+				log.Println(err)
+			} else {
+				channels[nc.Event] = append(channels[nc.Event], nc.Channel)
+			}
+		}
+		rows.Close()
+	}
+
+	for _, event := range notifyEvents {
+		if len(channels[event]) == 0 {
+			channels[event] = []string{notifier.ChannelTelegram}
+		}
+	}
+
+	return channels
+}
+
+//saveEventChannels replaces channels chosen by the user for the event
+func saveEventChannels(tgid int, event string, channels []string) error {
+
+	stmt, err := dbase.DeleteNotificationChannels(cfg)
+	if err != nil {
+		return err
+	}
+
+	_, err = stmt.Exec(tgid, event)
+	if err != nil {
+		return err
+	}
+
+	stmt, err = dbase.InsertNotificationChannel(cfg)
+	if err != nil {
+		return err
+	}
+
+	for _, channel := range channels {
+		_, err = dbase.ExecInsertNotificationChannel(stmt, models.DbNotificationChannels{TelegramID: tgid, Event: event, Channel: channel})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func logDelivery(tgid int, channel string, m notifier.Message, attempts int, deliveryErr error) {
+
+	nl := models.DbNotificationLog{
+		TelegramID: tgid,
+		Channel:    channel,
+		Event:      m.Event,
+		TaskID:     m.TaskID,
+		Status:     models.DeliverySent,
+		Attempts:   attempts,
+		CreatedAt:  models.NullTime{Time: time.Now().UTC(), Valid: true},
+	}
+
+	if deliveryErr == notifier.ErrNoAddress {
+		nl.Status = models.DeliverySkipped
+		nl.Error = deliveryErr.Error()
+	} else if deliveryErr != nil {
+		nl.Status = models.DeliveryFailed
+		nl.Error = deliveryErr.Error()
+	}
+
+	stmt, err := dbase.InsertNotificationLog(cfg)
+	if err != nil {
+		log.Println(fmt.Errorf("InsertNotificationLog: %v", err))
+		return
+	}
+
+	_, err = dbase.ExecInsertNotificationLog(stmt, nl)
+	if err != nil {
+		log.Println(fmt.Errorf("ExecInsertNotificationLog: %v", err))
+	}
+}
+
+//wantsEvent reports whether the user wants to receive notifications of the event
+func wantsEvent(s models.DbUserSettings, event string) bool {
+
+	switch event {
+	case models.NotifyNewTask:
+		return s.NotifyNewTask != 0
+	case models.NotifyStatus:
+		return s.NotifyStatus != 0
+	case models.NotifyComment:
+		return s.NotifyComment != 0
+	case models.NotifyMention:
+		return s.NotifyMention != 0
+	}
+
+	return true
+}
+
+func isTaskMuted(tgid int, taskID int) bool {
+
+	rows, err := dbase.SelectTaskMute(cfg, tgid, taskID)
+	if err != nil {
+		log.Println(fmt.Errorf("SelectTaskMute: %v", err))
+		return false
+	}
+	defer rows.Close()
+
+	return rows.Next()
+}
+
+//isTaskTeamMuted reports whether the user muted a team where both author and assignee of the Task are members
+func isTaskTeamMuted(tgid int, taskID int) bool {
+
+	rows, err := dbase.SelectTeamMutesByTask(cfg, tgid, taskID)
+	if err != nil {
+		log.Println(fmt.Errorf("SelectTeamMutesByTask: %v", err))
+		return false
+	}
+	defer rows.Close()
+
+	return rows.Next()
+}
+
+//isQuietTime reports whether it's night for the user now. Quiet hours may span midnight, e.g. 22:00 - 07:00
+func isQuietTime(s models.DbUserSettings, now time.Time) bool {
+
+	if s.QuietFrom == "" || s.QuietTo == "" {
+		return false
+	}
+
+	from, err := time.Parse(digestTimeLayout, s.QuietFrom)
+	if err != nil {
+		return false
+	}
+
+	to, err := time.Parse(digestTimeLayout, s.QuietTo)
+	if err != nil {
+		return false
+	}
+
+	local := now.In(userLocation(s))
+	minutes := local.Hour()*60 + local.Minute()
+	fromMinutes := from.Hour()*60 + from.Minute()
+	toMinutes := to.Hour()*60 + to.Minute()
+
+	if fromMinutes <= toMinutes {
+		return minutes >= fromMinutes && minutes < toMinutes
+	}
+
+	return minutes >= fromMinutes || minutes < toMinutes
+}
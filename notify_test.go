@@ -0,0 +1,66 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/slevchyk/taskeram/dbase"
+)
+
+func TestTeamMute(t *testing.T) {
+
+	task := setupTestDB(t)
+
+	err := addTeam("Sales", dbase.GetUserByTelegramID(cfg, testAdminID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	teamID := selectTeams()[0].ID
+
+	for _, tgid := range []int{testAdminID, testAssigneeID} {
+		err := addTeamMember(teamID, tgid)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if isTaskTeamMuted(testAdminID, task.ID) {
+		t.Fatal("Task is muted before the team is muted")
+	}
+
+	err = muteTeam(testAdminID, teamID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !isTaskTeamMuted(testAdminID, task.ID) {
+		t.Error("Task within muted team isn't muted")
+	}
+
+	if isTaskTeamMuted(testAssigneeID, task.ID) {
+		t.Error("the team is muted for the other member too")
+	}
+
+	err = unmuteTeam(testAdminID, teamID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if isTaskTeamMuted(testAdminID, task.ID) {
+		t.Error("Task is muted after the team is unmuted")
+	}
+
+	//разом з командою видаляються і її заглушки
+	err = muteTeam(testAdminID, teamID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = deleteTeam(teamID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if isTaskTeamMuted(testAdminID, task.ID) || len(selectMutedTeams(testAdminID)) != 0 {
+		t.Error("mutes of the deleted team are kept")
+	}
+}
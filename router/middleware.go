@@ -0,0 +1,94 @@
+package router
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"runtime/debug"
+	"time"
+)
+
+type requestIDKey struct{}
+
+//RequestID gives every request an ID. It is taken from X-Request-ID header if the proxy has set it
+//and is sent back in the same header
+func RequestID(next http.Handler) http.Handler {
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		id := r.Header.Get("X-Request-ID")
+		if id == "" {
+			b := make([]byte, 8)
+			rand.Read(b)
+			id = hex.EncodeToString(b)
+		}
+
+		w.Header().Set("X-Request-ID", id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+//GetRequestID returns ID of the request set by RequestID middleware
+func GetRequestID(r *http.Request) string {
+
+	id, _ := r.Context().Value(requestIDKey{}).(string)
+	return id
+}
+
+//statusWriter remembers the response status for the log
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (sw *statusWriter) WriteHeader(status int) {
+	sw.status = status
+	sw.ResponseWriter.WriteHeader(status)
+}
+
+func (sw *statusWriter) Write(b []byte) (int, error) {
+	if sw.status == 0 {
+		sw.status = http.StatusOK
+	}
+	return sw.ResponseWriter.Write(b)
+}
+
+//Logger logs every request with its status and duration
+func Logger(next http.Handler) http.Handler {
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w}
+
+		next.ServeHTTP(sw, r)
+
+		log.Printf("[%v] %v %v %v %v", GetRequestID(r), r.Method, r.URL.Path, sw.status, time.Since(start))
+	})
+}
+
+//Recoverer turns panics in handlers into 500 Internal Server Error instead of closing the connection
+func Recoverer(next http.Handler) http.Handler {
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		defer func() {
+			if err := recover(); err != nil {
+				log.Printf("[%v] panic: %v\n%s", GetRequestID(r), err, debug.Stack())
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			}
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+//Timeout ends requests which take longer than d with 503 Service Unavailable
+func Timeout(d time.Duration) Middleware {
+
+	return func(next http.Handler) http.Handler {
+		return http.TimeoutHandler(next, d, "Request timeout")
+	}
+}
@@ -0,0 +1,149 @@
+package router
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+//Middleware wraps a handler with some common logic, e.g. logging or authentication
+type Middleware func(http.Handler) http.Handler
+
+//Router dispatches requests by method and path. Patterns may have parameters like /tasks/{id}
+//and end with /* to match all paths with the prefix
+type Router struct {
+	routes      []route
+	middlewares []Middleware
+	//NotFound is used when no route matches the path, http.NotFound by default
+	NotFound http.Handler
+}
+
+type route struct {
+	method   string
+	segments []string
+	prefix   bool
+	handler  http.Handler
+}
+
+type paramsKey struct{}
+
+func New() *Router {
+	return &Router{NotFound: http.HandlerFunc(http.NotFound)}
+}
+
+//Use adds middlewares to the chain. They are applied to all routes in the order they are added
+func (rt *Router) Use(mw ...Middleware) {
+	rt.middlewares = append(rt.middlewares, mw...)
+}
+
+//Handle registers the handler for the method and pattern
+func (rt *Router) Handle(method string, pattern string, h http.Handler) {
+
+	rt.routes = append(rt.routes, route{
+		method:   method,
+		segments: split(strings.TrimSuffix(pattern, "/*")),
+		prefix:   strings.HasSuffix(pattern, "/*"),
+		handler:  h,
+	})
+}
+
+func (rt *Router) Get(pattern string, h http.HandlerFunc) {
+	rt.Handle(http.MethodGet, pattern, h)
+}
+
+func (rt *Router) Post(pattern string, h http.HandlerFunc) {
+	rt.Handle(http.MethodPost, pattern, h)
+}
+
+//Param returns value of the path parameter of the request
+func Param(r *http.Request, name string) string {
+
+	params, _ := r.Context().Value(paramsKey{}).(map[string]string)
+	return params[name]
+}
+
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	var h http.Handler = http.HandlerFunc(rt.dispatch)
+
+	for i := len(rt.middlewares) - 1; i >= 0; i-- {
+		h = rt.middlewares[i](h)
+	}
+
+	h.ServeHTTP(w, r)
+}
+
+func (rt *Router) dispatch(w http.ResponseWriter, r *http.Request) {
+
+	segments := split(r.URL.Path)
+	allowed := make(map[string]bool)
+
+	for _, val := range rt.routes {
+		params, ok := val.match(segments)
+		if !ok {
+			continue
+		}
+
+		if val.method != r.Method && !(val.method == http.MethodGet && r.Method == http.MethodHead) {
+			allowed[val.method] = true
+			continue
+		}
+
+		if len(params) > 0 {
+			r = r.WithContext(context.WithValue(r.Context(), paramsKey{}, params))
+		}
+
+		val.handler.ServeHTTP(w, r)
+		return
+	}
+
+	if len(allowed) > 0 {
+		var methods []string
+		for method := range allowed {
+			methods = append(methods, method)
+		}
+		sort.Strings(methods)
+
+		w.Header().Set("Allow", strings.Join(methods, ", "))
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	rt.NotFound.ServeHTTP(w, r)
+}
+
+func (rt route) match(segments []string) (map[string]string, bool) {
+
+	if len(segments) < len(rt.segments) || (!rt.prefix && len(segments) != len(rt.segments)) {
+		return nil, false
+	}
+
+	var params map[string]string
+
+	for i, val := range rt.segments {
+		if strings.HasPrefix(val, "{") && strings.HasSuffix(val, "}") {
+			if params == nil {
+				params = make(map[string]string)
+			}
+			params[val[1:len(val)-1]] = segments[i]
+			continue
+		}
+
+		if val != segments[i] {
+			return nil, false
+		}
+	}
+
+	return params, true
+}
+
+func split(path string) []string {
+
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+
+	return strings.Split(path, "/")
+}
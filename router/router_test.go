@@ -0,0 +1,186 @@
+package router
+
+import (
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func newTestRouter() *Router {
+
+	rt := New()
+
+	rt.Get("/tasks", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("tasks"))
+	})
+	rt.Get("/tasks/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("task " + Param(r, "id")))
+	})
+	rt.Post("/task", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("posted"))
+	})
+	rt.Get("/public/*", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("file " + r.URL.Path))
+	})
+
+	return rt
+}
+
+func TestRouter(t *testing.T) {
+
+	rt := newTestRouter()
+
+	tests := []struct {
+		method string
+		path   string
+		status int
+		body   string
+		allow  string
+	}{
+		{http.MethodGet, "/tasks", http.StatusOK, "tasks", ""},
+		{http.MethodGet, "/tasks/", http.StatusOK, "tasks", ""},
+		{http.MethodHead, "/tasks", http.StatusOK, "", ""},
+		{http.MethodGet, "/tasks/42", http.StatusOK, "task 42", ""},
+		{http.MethodGet, "/tasks/42/history", http.StatusNotFound, "", ""},
+		{http.MethodPost, "/task", http.StatusOK, "posted", ""},
+		{http.MethodGet, "/task", http.StatusMethodNotAllowed, "", "POST"},
+		{http.MethodPost, "/tasks/42", http.StatusMethodNotAllowed, "", "GET"},
+		{http.MethodGet, "/public/css/app.css", http.StatusOK, "file /public/css/app.css", ""},
+		{http.MethodGet, "/unknown", http.StatusNotFound, "", ""},
+		{http.MethodGet, "/", http.StatusNotFound, "", ""},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		rt.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+
+		if w.Code != tt.status {
+			t.Errorf("%v %v: status %v, want %v", tt.method, tt.path, w.Code, tt.status)
+		}
+		if tt.body != "" && w.Body.String() != tt.body {
+			t.Errorf("%v %v: body %q, want %q", tt.method, tt.path, w.Body.String(), tt.body)
+		}
+		if w.Header().Get("Allow") != tt.allow {
+			t.Errorf("%v %v: Allow %q, want %q", tt.method, tt.path, w.Header().Get("Allow"), tt.allow)
+		}
+	}
+}
+
+func TestRouterNotFound(t *testing.T) {
+
+	rt := newTestRouter()
+	rt.NotFound = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "no such page", http.StatusNotFound)
+	})
+
+	w := httptest.NewRecorder()
+	rt.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/unknown", nil))
+
+	if w.Code != http.StatusNotFound || w.Body.String() != "no such page\n" {
+		t.Errorf("got %v %q, want custom 404", w.Code, w.Body.String())
+	}
+}
+
+func TestMiddlewareOrder(t *testing.T) {
+
+	var calls []string
+
+	mw := func(name string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls = append(calls, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+
+	rt := newTestRouter()
+	rt.Use(mw("first"), mw("second"))
+
+	w := httptest.NewRecorder()
+	rt.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/tasks", nil))
+
+	if len(calls) != 2 || calls[0] != "first" || calls[1] != "second" {
+		t.Errorf("middlewares are called as %v, want [first second]", calls)
+	}
+}
+
+func TestRequestID(t *testing.T) {
+
+	var id string
+
+	rt := New()
+	rt.Use(RequestID)
+	rt.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		id = GetRequestID(r)
+	})
+
+	w := httptest.NewRecorder()
+	rt.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if id == "" || w.Header().Get("X-Request-ID") != id {
+		t.Errorf("request ID %q, header %q", id, w.Header().Get("X-Request-ID"))
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("X-Request-ID", "proxy-id")
+	w = httptest.NewRecorder()
+	rt.ServeHTTP(w, r)
+
+	if id != "proxy-id" || w.Header().Get("X-Request-ID") != "proxy-id" {
+		t.Errorf("request ID of the proxy isn't kept, got %q", id)
+	}
+}
+
+func TestRecoverer(t *testing.T) {
+
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	rt := New()
+	rt.Use(Recoverer)
+	rt.Get("/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+
+	w := httptest.NewRecorder()
+	rt.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/panic", nil))
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("status %v, want %v", w.Code, http.StatusInternalServerError)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+
+	rt := New()
+	rt.Use(Timeout(20 * time.Millisecond))
+	rt.Get("/slow", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+			w.Write([]byte("done"))
+		case <-r.Context().Done():
+		}
+	})
+	rt.Get("/fast", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("done"))
+	})
+
+	w := httptest.NewRecorder()
+	rt.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slow", nil))
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("slow request: status %v, want %v", w.Code, http.StatusServiceUnavailable)
+	}
+
+	w = httptest.NewRecorder()
+	rt.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/fast", nil))
+
+	if w.Code != http.StatusOK || w.Body.String() != "done" {
+		t.Errorf("fast request: got %v %q", w.Code, w.Body.String())
+	}
+}
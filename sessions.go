@@ -0,0 +1,264 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/slevchyk/taskeram/dbase"
+	"github.com/slevchyk/taskeram/models"
+	"gopkg.in/telegram-bot-api.v4"
+)
+
+const (
+	//session ends after it isn't used for sessionIdleTimeout or anyway after sessionMaxAge
+	sessionIdleTimeout = 30 * time.Minute
+	sessionMaxAge      = 7 * 24 * time.Hour
+	//last activity is saved not more often than sessionTouchPeriod
+	sessionTouchPeriod = time.Minute
+	sessionCleanPeriod = 10 * time.Minute
+)
+
+//newSessionCookie returns session cookie which isn't available to scripts and other sites.
+//It is sent over HTTPS only if the web app is published over HTTPS
+func newSessionCookie(r *http.Request, token string, maxAge int) *http.Cookie {
+
+	return &http.Cookie{
+		Name:     "session",
+		Value:    token,
+		Path:     "/",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   r.TLS != nil || strings.HasPrefix(cfg.Web.URL, "https://"),
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+//startSession starts web session of the user and sets the session cookie.
+//The token is only in the cookie, DB keeps its hash
+func startSession(w http.ResponseWriter, r *http.Request, tgid int) error {
+
+	var s models.DbSessions
+
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return err
+	}
+	token := hex.EncodeToString(b)
+
+	s.UUID = hashToken(token)
+	s.TelegramID = tgid
+	s.LastActivity = models.NullTime{Time: time.Now().UTC(), Valid: true}
+	s.IP = r.RemoteAddr
+	s.UserAgent = r.Header.Get("User-Agent")
+	s.StartedAt = s.LastActivity
+
+	stmt, err := dbase.InsertSession(cfg)
+	if err != nil {
+		return err
+	}
+
+	_, err = dbase.ExecInsertSession(stmt, s)
+	if err != nil {
+		return err
+	}
+
+	http.SetCookie(w, newSessionCookie(r, token, int(sessionMaxAge.Seconds())))
+
+	return nil
+}
+
+//getSession finds session by the token from the cookie
+func getSession(token string) (models.DbSessions, bool) {
+
+	var s models.DbSessions
+
+	rows, err := dbase.SelectSessionByUUID(cfg, hashToken(token))
+	if err != nil {
+		log.Println(fmt.Errorf("SelectSessionByUUID: %v", err))
+		return s, false
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return s, false
+	}
+
+	err = dbase.ScanSession(rows, &s)
+	if err != nil {
+		log.Println(err)
+		return s, false
+	}
+
+	return s, true
+}
+
+func sessionExpired(s models.DbSessions, now time.Time) bool {
+	return now.Sub(s.LastActivity.Time) > sessionIdleTimeout || now.Sub(s.StartedAt.Time) > sessionMaxAge
+}
+
+//alreadyLoggedIn returns the user of the session. The token is taken from the session cookie if it isn't passed.
+//Expired sessions are removed, sessions of users who aren't approved anymore aren't accepted
+func alreadyLoggedIn(w http.ResponseWriter, r *http.Request, token string) (bool, models.DbUsers) {
+
+	var u models.DbUsers
+
+	if token == "" {
+		c, err := r.Cookie("session")
+		if err != nil {
+			return false, u
+		}
+		token = c.Value
+	}
+
+	s, ok := getSession(token)
+	if !ok {
+		return false, u
+	}
+
+	now := time.Now().UTC()
+
+	if sessionExpired(s, now) {
+		err := deleteSession(s.ID)
+		if err != nil {
+			log.Println(err)
+		}
+		http.SetCookie(w, newSessionCookie(r, "", -1))
+		return false, u
+	}
+
+	u, err := dbase.FindUserByTelegramID(cfg, s.TelegramID)
+	if err != nil {
+		log.Println(fmt.Errorf("FindUserByTelegramID: %v", err))
+		return false, models.DbUsers{}
+	}
+	if u.ID == 0 || u.Status != models.UserApprowed {
+		return false, models.DbUsers{}
+	}
+
+	if now.Sub(s.LastActivity.Time) > sessionTouchPeriod {
+		stmt, err := dbase.UpdateSessionLastActivityByUuid(cfg)
+		if err == nil {
+			_, err = stmt.Exec(now, s.UUID)
+		}
+		if err != nil {
+			log.Println(err)
+		}
+	}
+
+	return true, u
+}
+
+//currentSessionID returns ID of the session from the request cookie or 0
+func currentSessionID(r *http.Request) int {
+
+	c, err := r.Cookie("session")
+	if err != nil {
+		return 0
+	}
+
+	s, _ := getSession(c.Value)
+	return s.ID
+}
+
+func selectUserSessions(tgid int) []models.DbSessions {
+
+	var s models.DbSessions
+	var xs []models.DbSessions
+
+	rows, err := dbase.SelectSessionsByTelegramID(cfg, tgid)
+	if err != nil {
+		log.Println(fmt.Errorf("SelectSessionsByTelegramID: %v", err))
+		return xs
+	}
+	defer rows.Close()
+
+	now := time.Now().UTC()
+
+	for rows.Next() {
+		err := dbase.ScanSession(rows, &s)
+		if err != nil {
+			log.Println(err)
+		} else if !sessionExpired(s, now) {
+			xs = append(xs, s)
+		}
+	}
+
+	return xs
+}
+
+func deleteSession(id int) error {
+
+	stmt, err := dbase.DeleteSessionByID(cfg)
+	if err != nil {
+		return err
+	}
+
+	_, err = stmt.Exec(id)
+	return err
+}
+
+//deleteUserSessions logs the user out of the web app everywhere and returns the number of ended sessions
+func deleteUserSessions(tgid int) (int64, error) {
+
+	stmt, err := dbase.DeleteSessionsByTelegramID(cfg)
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := stmt.Exec(tgid)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+func cleanSessions(now time.Time) {
+
+	stmt, err := dbase.DeleteExpiredSessions(cfg)
+	if err != nil {
+		log.Println(fmt.Errorf("DeleteExpiredSessions: %v", err))
+		return
+	}
+
+	_, err = stmt.Exec(now.Add(-sessionIdleTimeout), now.Add(-sessionMaxAge))
+	if err != nil {
+		log.Println(fmt.Errorf("DeleteExpiredSessions: %v", err))
+	}
+}
+
+func startSessionCleaner() {
+
+	ticker := time.NewTicker(sessionCleanPeriod)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		cleanSessions(now.UTC())
+	}
+}
+
+//handleCommandLogout ends all web sessions of the user
+func handleCommandLogout(c *models.UserCache) {
+
+	reply := "You have been logged out of the web app everywhere"
+
+	n, err := deleteUserSessions(c.User.TelegramID)
+	if err != nil {
+		log.Println(err)
+		reply = "Something went wrong while logging out"
+	} else if n == 0 {
+		reply = "You have no active web sessions"
+	}
+
+	msg := tgbotapi.NewMessage(c.ChatID, reply)
+	_, err = bot.Send(msg)
+	if err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,481 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/slevchyk/taskeram/dbase"
+	"github.com/slevchyk/taskeram/models"
+	"github.com/slevchyk/taskeram/notifier"
+	"gopkg.in/telegram-bot-api.v4"
+)
+
+const settingsHelp = `<i>/settings quiet 22:00 07:00</i> - send notifications silently at night
+<i>/settings quiet off</i> - turn quiet hours off
+<i>/settings timezone Europe/Kiev</i> - set your time zone
+<i>/settings mute 12</i> - stop notifications about Task #12
+<i>/settings unmute 12</i> - receive notifications about Task #12 again
+<i>/settings mute team 3</i> - stop notifications about Tasks within team #3
+<i>/settings unmute team 3</i> - receive notifications about Tasks within team #3 again
+<i>/settings email me@example.com</i> - set e-mail for notifications
+<i>/settings webhook https://example.com/hook</i> - set webhook URL for notifications, <i>off</i> to remove
+<i>/settings channels comment telegram,email</i> - choose channels for an event (newtask, status, comment, mention, digest)`
+
+var notifyEvents = []string{models.NotifyNewTask, models.NotifyStatus, models.NotifyComment, models.NotifyMention, models.NotifyDigest}
+
+func isNotifyEvent(event string) bool {
+
+	for _, val := range notifyEvents {
+		if val == event {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isNotifyChannel(channel string) bool {
+
+	return channel == notifier.ChannelTelegram || channel == notifier.ChannelEmail || channel == notifier.ChannelWebhook
+}
+
+//saveUserSettings inserts user settings on first change and updates them afterwards
+func saveUserSettings(s models.DbUserSettings) error {
+
+	if s.ID == 0 {
+		stmt, err := dbase.InsertUserSettings(cfg)
+		if err != nil {
+			return err
+		}
+
+		_, err = dbase.ExecInsertUserSettings(stmt, s)
+		return err
+	}
+
+	stmt, err := dbase.UpdateUserSettings(cfg)
+	if err != nil {
+		return err
+	}
+
+	_, err = dbase.ExecUpdateUserSettings(stmt, s)
+	return err
+}
+
+//selectMutedTasks returns IDs of the Tasks user doesn't want to be notified about
+func selectMutedTasks(tgid int) []int {
+
+	var m models.DbTaskMutes
+	var xs []int
+
+	rows, err := dbase.SelectTaskMutesByTelegramID(cfg, tgid)
+	if err != nil {
+		log.Println(fmt.Errorf("SelectTaskMutesByTelegramID: %v", err))
+		return xs
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		err := dbase.ScanTaskMute(rows, &m)
+		if err != nil {
+			log.Println(err)
+		} else {
+			xs = append(xs, m.TaskID)
+		}
+	}
+
+	return xs
+}
+
+func muteTask(tgid int, taskID int) error {
+
+	if isTaskMuted(tgid, taskID) {
+		return nil
+	}
+
+	stmt, err := dbase.InsertTaskMute(cfg)
+	if err != nil {
+		return err
+	}
+
+	_, err = dbase.ExecInsertTaskMute(stmt, models.DbTaskMutes{TelegramID: tgid, TaskID: taskID})
+	return err
+}
+
+func unmuteTask(tgid int, taskID int) error {
+
+	stmt, err := dbase.DeleteTaskMute(cfg)
+	if err != nil {
+		return err
+	}
+
+	_, err = stmt.Exec(tgid, taskID)
+	return err
+}
+
+//selectMutedTeams returns IDs of the teams user doesn't want to be notified about
+func selectMutedTeams(tgid int) []int {
+
+	var m models.DbTeamMutes
+	var xs []int
+
+	rows, err := dbase.SelectTeamMutesByTelegramID(cfg, tgid)
+	if err != nil {
+		log.Println(fmt.Errorf("SelectTeamMutesByTelegramID: %v", err))
+		return xs
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		err := dbase.ScanTeamMute(rows, &m)
+		if err != nil {
+			log.Println(err)
+		} else {
+			xs = append(xs, m.TeamID)
+		}
+	}
+
+	return xs
+}
+
+func muteTeam(tgid int, teamID int) error {
+
+	for _, val := range selectMutedTeams(tgid) {
+		if val == teamID {
+			return nil
+		}
+	}
+
+	stmt, err := dbase.InsertTeamMute(cfg)
+	if err != nil {
+		return err
+	}
+
+	_, err = dbase.ExecInsertTeamMute(stmt, models.DbTeamMutes{TelegramID: tgid, TeamID: teamID})
+	return err
+}
+
+func unmuteTeam(tgid int, teamID int) error {
+
+	stmt, err := dbase.DeleteTeamMute(cfg)
+	if err != nil {
+		return err
+	}
+
+	_, err = stmt.Exec(tgid, teamID)
+	return err
+}
+
+func handleCommandSettings(c *models.UserCache) {
+
+	s := dbase.GetUserSettings(cfg, c.User.TelegramID)
+	args := strings.Fields(c.Arguments)
+
+	if len(args) == 0 {
+		showSettings(c, s)
+		return
+	}
+
+	var reply string
+
+	switch strings.ToLower(args[0]) {
+	case "quiet":
+		if len(args) == 2 && strings.ToLower(args[1]) == "off" {
+			s.QuietFrom = ""
+			s.QuietTo = ""
+		} else if len(args) == 3 {
+			_, errFrom := time.Parse(digestTimeLayout, args[1])
+			_, errTo := time.Parse(digestTimeLayout, args[2])
+			if errFrom != nil || errTo != nil {
+				reply = "Quiet hours should be set as HH:MM HH:MM, e.g. 22:00 07:00"
+				break
+			}
+			s.QuietFrom = args[1]
+			s.QuietTo = args[2]
+		} else {
+			reply = "Quiet hours should be set as HH:MM HH:MM, e.g. 22:00 07:00"
+			break
+		}
+
+		err := saveUserSettings(s)
+		if err != nil {
+			log.Println(err)
+			reply = "Something went wrong while saving settings"
+			break
+		}
+
+		showSettings(c, s)
+		return
+	case "timezone":
+		if len(args) != 2 {
+			reply = "Time zone should be set as a name from the tz database, e.g. Europe/Kiev"
+			break
+		}
+
+		_, err := time.LoadLocation(args[1])
+		if err != nil {
+			reply = fmt.Sprintf("Unknown time zone %v", args[1])
+			break
+		}
+		s.Timezone = args[1]
+
+		err = saveUserSettings(s)
+		if err != nil {
+			log.Println(err)
+			reply = "Something went wrong while saving settings"
+			break
+		}
+
+		showSettings(c, s)
+		return
+	case "mute", "unmute":
+		if len(args) == 3 && strings.ToLower(args[1]) == "team" {
+			reply = muteTeamCommand(c.User, strings.ToLower(args[0]) == "mute", args[2])
+			break
+		}
+
+		if len(args) != 2 {
+			reply = fmt.Sprintf("you should input Task number after /settings %v", args[0])
+			break
+		}
+
+		taskID, err := strconv.Atoi(strings.TrimPrefix(args[1], "#"))
+		if err != nil {
+			reply = fmt.Sprintf("%v - wrong argument type", args[1])
+			break
+		}
+
+		if strings.ToLower(args[0]) == "mute" {
+			err = muteTask(c.User.TelegramID, taskID)
+			reply = fmt.Sprintf("Task #%v is muted", taskID)
+		} else {
+			err = unmuteTask(c.User.TelegramID, taskID)
+			reply = fmt.Sprintf("Task #%v is unmuted", taskID)
+		}
+		if err != nil {
+			log.Println(err)
+			reply = "Something went wrong while saving settings"
+		}
+	case "email", "webhook":
+		if len(args) != 2 {
+			reply = settingsHelp
+			break
+		}
+
+		address := args[1]
+		if strings.ToLower(address) == "off" {
+			address = ""
+		}
+
+		if strings.ToLower(args[0]) == "email" {
+			if address != "" && !strings.Contains(address, "@") {
+				reply = fmt.Sprintf("%v doesn't look like an e-mail", address)
+				break
+			}
+			s.Email = address
+		} else {
+			if address != "" && !strings.HasPrefix(address, "http://") && !strings.HasPrefix(address, "https://") {
+				reply = fmt.Sprintf("%v doesn't look like a URL", address)
+				break
+			}
+			s.WebhookURL = address
+		}
+
+		err := saveUserSettings(s)
+		if err != nil {
+			log.Println(err)
+			reply = "Something went wrong while saving settings"
+			break
+		}
+
+		showSettings(c, s)
+		return
+	case "channels":
+		if len(args) != 3 || !isNotifyEvent(args[1]) {
+			reply = settingsHelp
+			break
+		}
+
+		var channels []string
+		for _, val := range strings.Split(strings.ToLower(args[2]), ",") {
+			if !isNotifyChannel(val) {
+				reply = fmt.Sprintf("Unknown channel %v, use telegram, email or webhook", val)
+				break
+			}
+			channels = append(channels, val)
+		}
+		if reply != "" {
+			break
+		}
+
+		err := saveEventChannels(c.User.TelegramID, args[1], channels)
+		if err != nil {
+			log.Println(err)
+			reply = "Something went wrong while saving settings"
+			break
+		}
+
+		showSettings(c, s)
+		return
+	default:
+		reply = settingsHelp
+	}
+
+	msg := tgbotapi.NewMessage(c.ChatID, reply)
+	msg.ParseMode = "HTML"
+	_, err := bot.Send(msg)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+func showSettings(c *models.UserCache, s models.DbUserSettings) {
+
+	msg := tgbotapi.NewMessage(c.ChatID, settingsText(s))
+	msg.ParseMode = "HTML"
+	msg.ReplyMarkup = settingsKeyboard(s)
+	_, err := bot.Send(msg)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+//muteTeamCommand mutes or unmutes the team the user is a member of and returns the reply
+func muteTeamCommand(u models.DbUsers, mute bool, arg string) string {
+
+	teamID := teamIDArg(arg)
+	t := getTeamByID(teamID)
+	if t.ID == 0 || teamMember(t.ID, u.TelegramID).ID == 0 {
+		return fmt.Sprintf("You aren't a member of team %v", arg)
+	}
+
+	var err error
+	var reply string
+
+	if mute {
+		err = muteTeam(u.TelegramID, t.ID)
+		reply = fmt.Sprintf("Team #%v %v is muted", t.ID, html.EscapeString(t.Name))
+	} else {
+		err = unmuteTeam(u.TelegramID, t.ID)
+		reply = fmt.Sprintf("Team #%v %v is unmuted", t.ID, html.EscapeString(t.Name))
+	}
+	if err != nil {
+		log.Println(err)
+		return "Something went wrong while saving settings"
+	}
+
+	return reply
+}
+
+func settingsText(s models.DbUserSettings) string {
+
+	quiet := "off"
+	if s.QuietFrom != "" && s.QuietTo != "" {
+		quiet = fmt.Sprintf("%v - %v", s.QuietFrom, s.QuietTo)
+	}
+
+	muted := "none"
+	if xs := selectMutedTasks(s.TelegramID); len(xs) > 0 {
+		var tasks []string
+		for _, val := range xs {
+			tasks = append(tasks, fmt.Sprintf("#%v", val))
+		}
+		muted = strings.Join(tasks, ", ")
+	}
+
+	mutedTeams := "none"
+	if xs := selectMutedTeams(s.TelegramID); len(xs) > 0 {
+		var teams []string
+		for _, val := range xs {
+			teams = append(teams, fmt.Sprintf("#%v %v", val, getTeamByID(val).Name))
+		}
+		mutedTeams = html.EscapeString(strings.Join(teams, ", "))
+	}
+
+	email := s.Email
+	if email == "" {
+		email = "not set"
+	}
+
+	webhook := s.WebhookURL
+	if webhook == "" {
+		webhook = "not set"
+	}
+
+	var channels string
+	eventsChannels := eventChannels(s.TelegramID)
+	for _, event := range notifyEvents {
+		channels += fmt.Sprintf("\n	%v: %v", event, strings.Join(eventsChannels[event], ", "))
+	}
+
+	return fmt.Sprintf(`<b>Notification settings</b>
+	Time zone: %v
+	Quiet hours: %v
+	Muted Tasks: %v
+	Muted teams: %v
+	E-mail: %v
+	Webhook: %v
+	Channels:%v
+
+	Tap a button to turn notifications on or off.
+	%v`, s.Timezone, quiet, muted, mutedTeams, html.EscapeString(email), html.EscapeString(webhook), channels, settingsHelp)
+}
+
+func settingsKeyboard(s models.DbUserSettings) tgbotapi.InlineKeyboardMarkup {
+
+	button := func(title string, event string) tgbotapi.InlineKeyboardButton {
+		mark := "🚫"
+		if wantsEvent(s, event) {
+			mark = "✓"
+		}
+		return tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("%v %v", mark, title), fmt.Sprintf("%v|%v", models.Settings, event))
+	}
+
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(button("New tasks", models.NotifyNewTask), button("Status changes", models.NotifyStatus)),
+		tgbotapi.NewInlineKeyboardRow(button("Comments", models.NotifyComment), button("Mentions", models.NotifyMention)))
+}
+
+//toggleSetting turns notifications of the event on or off from the /settings message
+func toggleSetting(c *models.UserCache, event string) {
+
+	var cbConfig tgbotapi.CallbackConfig
+
+	s := dbase.GetUserSettings(cfg, c.User.TelegramID)
+
+	switch event {
+	case models.NotifyNewTask:
+		s.NotifyNewTask = 1 - s.NotifyNewTask
+	case models.NotifyStatus:
+		s.NotifyStatus = 1 - s.NotifyStatus
+	case models.NotifyComment:
+		s.NotifyComment = 1 - s.NotifyComment
+	case models.NotifyMention:
+		s.NotifyMention = 1 - s.NotifyMention
+	default:
+		return
+	}
+
+	cbConfig.CallbackQueryID = c.CallbackID
+
+	err := saveUserSettings(s)
+	if err != nil {
+		log.Println(err)
+		cbConfig.Text = "Something went wrong while saving settings"
+		cbConfig.ShowAlert = true
+	}
+
+	_, err = bot.AnswerCallbackQuery(cbConfig)
+	if err != nil {
+		log.Println(err)
+	}
+
+	inlKbrd := tgbotapi.NewEditMessageReplyMarkup(c.ChatID, c.MessageID, settingsKeyboard(s))
+	_, err = bot.Send(inlKbrd)
+	if err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,461 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/slevchyk/taskeram/dbase"
+	"github.com/slevchyk/taskeram/models"
+	"gopkg.in/telegram-bot-api.v4"
+)
+
+const teamHelp = `<i>/team</i> - show teams and their members
+<i>/team add Support</i> - create a team
+<i>/team delete 2</i> - delete team #2
+<i>/team join 2 @username</i> - add a user to team #2
+<i>/team leave 2 @username</i> - remove a user from team #2
+<i>/team lead 2 @username</i> - make a member team lead or an ordinary member again`
+
+//teamsEnabled reports whether any team exists. Until then users aren't limited by teams
+func teamsEnabled() bool {
+
+	rows, err := dbase.SelectTeams(cfg)
+	if err != nil {
+		log.Println(fmt.Errorf("SelectTeams: %v", err))
+		return false
+	}
+	defer rows.Close()
+
+	return rows.Next()
+}
+
+//commonTeams returns memberships of tgid in the teams where otherTgid is a member too
+func commonTeams(tgid int, otherTgid int) []models.DbTeamMembers {
+
+	var m models.DbTeamMembers
+	var xs []models.DbTeamMembers
+
+	rows, err := dbase.SelectCommonTeamMembers(cfg, tgid, otherTgid)
+	if err != nil {
+		log.Println(fmt.Errorf("SelectCommonTeamMembers: %v", err))
+		return xs
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		err := dbase.ScanTeamMember(rows, &m)
+		if err != nil {
+			log.Println(err)
+		} else {
+			xs = append(xs, m)
+		}
+	}
+
+	return xs
+}
+
+//inSameTeam reports whether users share a team. Everybody is in the same team while there are no teams
+func inSameTeam(a models.DbUsers, b models.DbUsers) bool {
+
+	if a.TelegramID == b.TelegramID || !teamsEnabled() {
+		return true
+	}
+
+	return len(commonTeams(a.TelegramID, b.TelegramID)) > 0
+}
+
+//leadsTeamOf reports whether lead is a lead of any team tgid belongs to
+func leadsTeamOf(lead models.DbUsers, tgid int) bool {
+
+	for _, val := range commonTeams(lead.TelegramID, tgid) {
+		if val.Lead == 1 {
+			return true
+		}
+	}
+
+	return false
+}
+
+func selectTeams() []models.DbTeams {
+
+	var t models.DbTeams
+	var xs []models.DbTeams
+
+	rows, err := dbase.SelectTeams(cfg)
+	if err != nil {
+		log.Println(fmt.Errorf("SelectTeams: %v", err))
+		return xs
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		err := dbase.ScanTeam(rows, &t)
+		if err != nil {
+			log.Println(err)
+		} else {
+			xs = append(xs, t)
+		}
+	}
+
+	return xs
+}
+
+func selectTeamMembers(teamID int) []models.DbTeamMembers {
+
+	var m models.DbTeamMembers
+	var xs []models.DbTeamMembers
+
+	rows, err := dbase.SelectTeamMembers(cfg, teamID)
+	if err != nil {
+		log.Println(fmt.Errorf("SelectTeamMembers: %v", err))
+		return xs
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		err := dbase.ScanTeamMember(rows, &m)
+		if err != nil {
+			log.Println(err)
+		} else {
+			xs = append(xs, m)
+		}
+	}
+
+	return xs
+}
+
+//teamMember returns membership of the user in the team, ID is 0 if the user isn't a member
+func teamMember(teamID int, tgid int) models.DbTeamMembers {
+
+	for _, val := range selectTeamMembers(teamID) {
+		if val.TelegramID == tgid {
+			return val
+		}
+	}
+
+	return models.DbTeamMembers{}
+}
+
+func getTeamByID(id int) models.DbTeams {
+
+	var t models.DbTeams
+
+	rows, err := dbase.SelectTeamByID(cfg, id)
+	if err != nil {
+		log.Println(fmt.Errorf("SelectTeamByID: %v", err))
+		return t
+	}
+	defer rows.Close()
+
+	if rows.Next() {
+		err := dbase.ScanTeam(rows, &t)
+		if err != nil {
+			log.Println(err)
+		}
+	}
+
+	return t
+}
+
+func addTeam(name string, by models.DbUsers) error {
+
+	stmt, err := dbase.InsertTeam(cfg)
+	if err != nil {
+		return err
+	}
+
+	_, err = dbase.ExecInsertTeam(stmt, models.DbTeams{
+		Name:      name,
+		CreatedBy: by.TelegramID,
+		CreatedAt: models.NullTime{Time: time.Now().UTC(), Valid: true},
+	})
+	return err
+}
+
+func deleteTeam(id int) error {
+
+	stmt, err := dbase.DeleteTeamMutesByTeamID(cfg)
+	if err != nil {
+		return err
+	}
+
+	_, err = stmt.Exec(id)
+	if err != nil {
+		return err
+	}
+
+	stmt, err = dbase.DeleteTeamMembers(cfg)
+	if err != nil {
+		return err
+	}
+
+	_, err = stmt.Exec(id)
+	if err != nil {
+		return err
+	}
+
+	stmt, err = dbase.DeleteTeam(cfg)
+	if err != nil {
+		return err
+	}
+
+	_, err = stmt.Exec(id)
+	return err
+}
+
+func addTeamMember(teamID int, tgid int) error {
+
+	if teamMember(teamID, tgid).ID != 0 {
+		return nil
+	}
+
+	stmt, err := dbase.InsertTeamMember(cfg)
+	if err != nil {
+		return err
+	}
+
+	_, err = dbase.ExecInsertTeamMember(stmt, models.DbTeamMembers{TeamID: teamID, TelegramID: tgid})
+	return err
+}
+
+func removeTeamMember(memberID int) error {
+
+	stmt, err := dbase.DeleteTeamMember(cfg)
+	if err != nil {
+		return err
+	}
+
+	_, err = stmt.Exec(memberID)
+	return err
+}
+
+func setTeamLead(memberID int, lead int) error {
+
+	stmt, err := dbase.UpdateTeamMemberLead(cfg)
+	if err != nil {
+		return err
+	}
+
+	_, err = stmt.Exec(lead, memberID)
+	return err
+}
+
+//handleCommandTeam lets admins manage teams from the bot
+func handleCommandTeam(c *models.UserCache) {
+
+	if !can(c.User, permManageTeams) {
+		return
+	}
+
+	args := strings.Fields(c.Arguments)
+
+	if len(args) == 0 {
+		showTeams(c)
+		return
+	}
+
+	var reply string
+
+	switch strings.ToLower(args[0]) {
+	case "add":
+		name := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(c.Arguments), args[0]))
+		if name == "" {
+			reply = "you should input team name after /team add"
+			break
+		}
+
+		err := addTeam(name, c.User)
+		if err != nil {
+			log.Println(err)
+			reply = "Something went wrong while saving team"
+			break
+		}
+
+		showTeams(c)
+		return
+	case "delete":
+		if len(args) != 2 {
+			reply = teamHelp
+			break
+		}
+
+		t := getTeamByID(teamIDArg(args[1]))
+		if t.ID == 0 {
+			reply = fmt.Sprintf("Can't find any team with ID: %v", args[1])
+			break
+		}
+
+		err := deleteTeam(t.ID)
+		if err != nil {
+			log.Println(err)
+			reply = "Something went wrong while deleting team"
+			break
+		}
+
+		showTeams(c)
+		return
+	case "join", "leave", "lead":
+		if len(args) != 3 {
+			reply = teamHelp
+			break
+		}
+
+		t := getTeamByID(teamIDArg(args[1]))
+		if t.ID == 0 {
+			reply = fmt.Sprintf("Can't find any team with ID: %v", args[1])
+			break
+		}
+
+		u := findTaskRecipient(args[2])
+		if u.ID == 0 {
+			reply = fmt.Sprintf("Can't find any user %v", args[2])
+			break
+		}
+
+		var err error
+
+		m := teamMember(t.ID, u.TelegramID)
+
+		switch strings.ToLower(args[0]) {
+		case "join":
+			err = addTeamMember(t.ID, u.TelegramID)
+		case "leave":
+			if m.ID != 0 {
+				err = removeTeamMember(m.ID)
+			}
+		case "lead":
+			if m.ID == 0 {
+				reply = fmt.Sprintf("%v %v isn't a member of %v", u.FirstName, u.LastName, html.EscapeString(t.Name))
+				break
+			}
+			err = setTeamLead(m.ID, 1-m.Lead)
+		}
+		if reply != "" {
+			break
+		}
+		if err != nil {
+			log.Println(err)
+			reply = "Something went wrong while saving team"
+			break
+		}
+
+		showTeams(c)
+		return
+	default:
+		reply = teamHelp
+	}
+
+	msg := tgbotapi.NewMessage(c.ChatID, reply)
+	msg.ParseMode = "HTML"
+	_, err := bot.Send(msg)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+func teamIDArg(arg string) int {
+
+	id, err := strconv.Atoi(strings.TrimPrefix(arg, "#"))
+	if err != nil {
+		return 0
+	}
+
+	return id
+}
+
+//showTeams sends the list of teams with their members and leads
+func showTeams(c *models.UserCache) {
+
+	if !can(c.User, permManageTeams) {
+		return
+	}
+
+	reply := "<b>Teams</b>\n"
+
+	teams := selectTeams()
+	if len(teams) == 0 {
+		reply += "There are no teams yet, all users work together\n"
+	}
+
+	for _, t := range teams {
+		reply += fmt.Sprintf("\n<b>#%v %v</b>\n", t.ID, html.EscapeString(t.Name))
+
+		for _, m := range selectTeamMembers(t.ID) {
+			u := dbase.GetUserByTelegramID(cfg, m.TelegramID)
+			lead := ""
+			if m.Lead == 1 {
+				lead = " (lead)"
+			}
+			reply += fmt.Sprintf("	<a href=\"tg://user?id=%v\">%v %v</a>%v\n", u.TelegramID, u.FirstName, u.LastName, lead)
+		}
+	}
+
+	reply += "\n" + teamHelp
+
+	msg := tgbotapi.NewMessage(c.ChatID, reply)
+	msg.ParseMode = "HTML"
+	_, err := bot.Send(msg)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+//leadsTeam reports whether the user is a lead of any team
+func leadsTeam(u models.DbUsers) bool {
+
+	var m models.DbTeamMembers
+
+	rows, err := dbase.SelectTeamMembersByTelegramID(cfg, u.TelegramID)
+	if err != nil {
+		log.Println(fmt.Errorf("SelectTeamMembersByTelegramID: %v", err))
+		return false
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		err := dbase.ScanTeamMember(rows, &m)
+		if err != nil {
+			log.Println(err)
+		} else if m.Lead == 1 {
+			return true
+		}
+	}
+
+	return false
+}
+
+//userTeams returns the teams the user is a member of
+func userTeams(tgid int) []models.DbTeams {
+
+	var m models.DbTeamMembers
+	var xs []models.DbTeams
+
+	rows, err := dbase.SelectTeamMembersByTelegramID(cfg, tgid)
+	if err != nil {
+		log.Println(fmt.Errorf("SelectTeamMembersByTelegramID: %v", err))
+		return xs
+	}
+
+	var teamIDs []int
+	for rows.Next() {
+		err := dbase.ScanTeamMember(rows, &m)
+		if err != nil {
+			log.Println(err)
+		} else {
+			teamIDs = append(teamIDs, m.TeamID)
+		}
+	}
+	rows.Close()
+
+	for _, id := range teamIDs {
+		if t := getTeamByID(id); t.ID != 0 {
+			xs = append(xs, t)
+		}
+	}
+
+	return xs
+}
@@ -0,0 +1,573 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/slevchyk/taskeram/dbase"
+	"github.com/slevchyk/taskeram/models"
+)
+
+//formats of taskeram import
+const (
+	importFormatJSON    = "json"
+	importFormatTrello  = "trello"
+	importFormatTracker = "tracker"
+)
+
+//trackerHelp documents the generic tracker format and the mapping file
+const trackerHelp = `Tracker format (-format tracker) is a JSON object, Trello boards (-format trello) are converted to it:
+{
+  "tasks": [{
+    "id": "PRJ-1",                        id in the other tracker, it is shown in the report only
+    "title": "Prepare the report",        required
+    "description": "...",
+    "status": "In Progress",              taskeram status or a name from "statuses" of the mapping, default New
+    "priority": "high",                   low, normal or high
+    "due_date": "2019-01-31",             YYYY-MM-DD
+    "created_at": "2019-01-02T10:00:00Z", RFC 3339, default now
+    "from": "alice",                      author, default "default_from" of the mapping or telegram.admin_id
+    "to": "bob",                          assignee, default "default_to" of the mapping
+    "comments": [{"author": "alice", "date": "2019-01-03T12:00:00Z", "text": "..."}],
+    "subtasks": [{"title": "Collect data", "done": true, "to": "carol"}]
+  }]
+}
+
+Mapping file (-map) tells taskeram users and statuses by the names of the other tracker:
+{
+  "users": {"alice": "123456789", "bob": "@bob"},
+  "statuses": {"To Do": "New", "In Progress": "Started", "Done": "Completed"},
+  "default_from": "alice",
+  "default_to": "bob"
+}
+Users are Telegram IDs or @usernames, names missing in "users" are looked up as they are.
+Trello members are named by their usernames, lists become statuses, archived cards are Closed,
+labels low, normal and high set priority and checklist items become subtasks.
+Subtasks are separate Tasks which refer to the Task in their description.`
+
+//trackerBoard is the generic format of Tasks from other trackers, see trackerHelp
+type trackerBoard struct {
+	Tasks []trackerTask `json:"tasks"`
+}
+
+type trackerTask struct {
+	ID          string           `json:"id"`
+	Title       string           `json:"title"`
+	Description string           `json:"description"`
+	Status      string           `json:"status"`
+	Priority    string           `json:"priority"`
+	DueDate     string           `json:"due_date"`
+	CreatedAt   time.Time        `json:"created_at"`
+	From        string           `json:"from"`
+	To          string           `json:"to"`
+	Comments    []trackerComment `json:"comments"`
+	Subtasks    []trackerSubtask `json:"subtasks"`
+}
+
+type trackerComment struct {
+	Author string    `json:"author"`
+	Date   time.Time `json:"date"`
+	Text   string    `json:"text"`
+}
+
+type trackerSubtask struct {
+	Title string `json:"title"`
+	Done  bool   `json:"done"`
+	To    string `json:"to"`
+}
+
+//trackerMapping maps users and statuses of the other tracker to taskeram ones, see trackerHelp
+type trackerMapping struct {
+	Users       map[string]string `json:"users"`
+	Statuses    map[string]string `json:"statuses"`
+	DefaultFrom string            `json:"default_from"`
+	DefaultTo   string            `json:"default_to"`
+}
+
+//trelloBoard is the part of Trello board JSON export which is imported
+type trelloBoard struct {
+	Lists []struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"lists"`
+	Cards []struct {
+		ID           string   `json:"id"`
+		Name         string   `json:"name"`
+		Desc         string   `json:"desc"`
+		IDList       string   `json:"idList"`
+		IDMembers    []string `json:"idMembers"`
+		IDChecklists []string `json:"idChecklists"`
+		Due          string   `json:"due"`
+		Closed       bool     `json:"closed"`
+		Labels       []struct {
+			Name string `json:"name"`
+		} `json:"labels"`
+	} `json:"cards"`
+	Members []struct {
+		ID       string `json:"id"`
+		Username string `json:"username"`
+	} `json:"members"`
+	Checklists []struct {
+		ID         string `json:"id"`
+		CheckItems []struct {
+			Name  string  `json:"name"`
+			State string  `json:"state"`
+			Pos   float64 `json:"pos"`
+		} `json:"checkItems"`
+	} `json:"checklists"`
+	Actions []struct {
+		Type            string    `json:"type"`
+		Date            time.Time `json:"date"`
+		IDMemberCreator string    `json:"idMemberCreator"`
+		Data            struct {
+			Text string `json:"text"`
+			Card struct {
+				ID string `json:"id"`
+			} `json:"card"`
+		} `json:"data"`
+	} `json:"actions"`
+}
+
+//trackerPlan is a Task ready to be saved with its comments and subtasks or the problems which don't let to save it
+type trackerPlan struct {
+	Source   string                  `json:"source"`
+	Task     models.DbTasks          `json:"task"`
+	Comments []models.DbTaskComments `json:"comments"`
+	Subtasks []models.DbTasks        `json:"subtasks"`
+	Problems []string                `json:"problems,omitempty"`
+}
+
+//readTrackerBoard reads Tasks in the tracker or trello format
+func readTrackerBoard(r io.Reader, format string) (trackerBoard, error) {
+
+	var b trackerBoard
+
+	switch format {
+	case importFormatTracker:
+		err := json.NewDecoder(r).Decode(&b)
+		return b, err
+	case importFormatTrello:
+		var tb trelloBoard
+		err := json.NewDecoder(r).Decode(&tb)
+		if err != nil {
+			return b, err
+		}
+		return tb.tracker(), nil
+	default:
+		return b, fmt.Errorf("import format should be %v, %v or %v", importFormatJSON, importFormatTrello, importFormatTracker)
+	}
+}
+
+//tracker converts Trello board to the generic format
+func (tb trelloBoard) tracker() trackerBoard {
+
+	var b trackerBoard
+
+	lists := make(map[string]string)
+	for _, l := range tb.Lists {
+		lists[l.ID] = l.Name
+	}
+
+	members := make(map[string]string)
+	for _, m := range tb.Members {
+		members[m.ID] = m.Username
+	}
+	member := func(id string) string {
+		if name, ok := members[id]; ok {
+			return name
+		}
+		return id
+	}
+
+	checklists := make(map[string]int)
+	for i, cl := range tb.Checklists {
+		checklists[cl.ID] = i
+	}
+
+	//actions are exported newest first
+	actions := tb.Actions
+	sort.SliceStable(actions, func(i, j int) bool { return actions[i].Date.Before(actions[j].Date) })
+
+	for _, card := range tb.Cards {
+		t := trackerTask{
+			ID:          card.ID,
+			Title:       card.Name,
+			Description: card.Desc,
+			Status:      lists[card.IDList],
+			CreatedAt:   trelloCreatedAt(card.ID),
+		}
+
+		if card.Closed {
+			t.Status = models.TaskStatusClosed
+		}
+
+		if len(card.IDMembers) != 0 {
+			t.To = member(card.IDMembers[0])
+		}
+
+		if due, err := time.Parse(time.RFC3339, card.Due); err == nil {
+			t.DueDate = due.UTC().Format(models.DueDateLayout)
+		}
+
+		for _, l := range card.Labels {
+			switch name := strings.ToLower(l.Name); name {
+			case models.PriorityLow, models.PriorityNormal, models.PriorityHigh:
+				t.Priority = name
+			}
+		}
+
+		for _, id := range card.IDChecklists {
+			i, ok := checklists[id]
+			if !ok {
+				continue
+			}
+
+			items := tb.Checklists[i].CheckItems
+			sort.SliceStable(items, func(i, j int) bool { return items[i].Pos < items[j].Pos })
+
+			for _, item := range items {
+				t.Subtasks = append(t.Subtasks, trackerSubtask{Title: item.Name, Done: item.State == "complete"})
+			}
+		}
+
+		for _, a := range actions {
+			if a.Data.Card.ID != card.ID {
+				continue
+			}
+
+			switch a.Type {
+			case "createCard":
+				t.From = member(a.IDMemberCreator)
+				t.CreatedAt = a.Date
+			case "commentCard":
+				t.Comments = append(t.Comments, trackerComment{Author: member(a.IDMemberCreator), Date: a.Date, Text: a.Data.Text})
+			}
+		}
+
+		b.Tasks = append(b.Tasks, t)
+	}
+
+	return b
+}
+
+//trelloCreatedAt returns creation time kept in the first 8 hex digits of Trello ID
+func trelloCreatedAt(id string) time.Time {
+
+	if len(id) < 8 {
+		return time.Time{}
+	}
+
+	sec, err := strconv.ParseInt(id[:8], 16, 64)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return time.Unix(sec, 0).UTC()
+}
+
+//readTrackerMapping reads the mapping file, empty path means no mapping
+func readTrackerMapping(path string) (trackerMapping, error) {
+
+	var m trackerMapping
+
+	if path == "" {
+		return m, nil
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return m, err
+	}
+
+	err = json.Unmarshal(b, &m)
+	if err != nil {
+		return m, fmt.Errorf("%v: %v", path, err)
+	}
+
+	return m, nil
+}
+
+//user finds taskeram user by the name in the other tracker
+func (m trackerMapping) user(name string) (models.DbUsers, bool) {
+
+	if val, ok := m.Users[name]; ok {
+		name = val
+	}
+
+	u := findTaskRecipient(name)
+	if u.ID == 0 || u.Status != models.UserApprowed {
+		return u, false
+	}
+
+	return u, true
+}
+
+//status returns taskeram status by the name in the other tracker, empty status is New
+func (m trackerMapping) status(name string) (string, bool) {
+
+	if val, ok := m.Statuses[name]; ok {
+		name = val
+	}
+
+	if name == "" {
+		return models.TaskStatusNew, true
+	}
+
+	for _, status := range taskStatuses {
+		if strings.EqualFold(status, name) {
+			return status, true
+		}
+	}
+
+	return "", false
+}
+
+//planTrackerImport checks all Tasks of the board without saving them. Tasks are made on behalf of the admin,
+//so team rules aren't applied, but authors and assignees must be approved users
+func planTrackerImport(b trackerBoard, m trackerMapping, admin models.DbUsers) []trackerPlan {
+
+	var xs []trackerPlan
+
+	for i, it := range b.Tasks {
+		p := trackerPlan{Source: it.ID}
+		if p.Source == "" {
+			p.Source = fmt.Sprintf("#%v", i+1)
+		}
+
+		problem := func(format string, a ...interface{}) {
+			p.Problems = append(p.Problems, fmt.Sprintf(format, a...))
+		}
+
+		from := admin
+		if name := firstNonEmpty(it.From, m.DefaultFrom); name != "" {
+			u, ok := m.user(name)
+			if ok {
+				from = u
+			} else {
+				problem("unknown author %v", name)
+			}
+		}
+
+		to := firstNonEmpty(it.To, m.DefaultTo)
+		if to == "" {
+			problem("no assignee")
+		} else if val, ok := m.Users[to]; ok {
+			to = val
+		}
+
+		var err error
+		if to != "" {
+			p.Task, _, err = prepareTask(admin, to, it.Title, it.Description, it.DueDate)
+			if err != nil {
+				problem("%v", err)
+			}
+		}
+		if p.Task.Title == "" {
+			p.Task.Title = it.Title
+		}
+
+		status, ok := m.status(it.Status)
+		if !ok {
+			problem("unknown status %v, map it in statuses", it.Status)
+		}
+
+		priority := strings.ToLower(it.Priority)
+		switch priority {
+		case "", models.PriorityLow, models.PriorityNormal, models.PriorityHigh:
+		default:
+			problem("wrong priority %v, expected %v, %v or %v", it.Priority, models.PriorityLow, models.PriorityNormal, models.PriorityHigh)
+		}
+
+		createdAt := it.CreatedAt.UTC()
+		if createdAt.IsZero() {
+			createdAt = time.Now().UTC()
+		}
+
+		p.Task.FromUser = from.TelegramID
+		p.Task.Status = status
+		p.Task.Priority = priority
+		p.Task.ChangedAt = models.NullTime{Time: createdAt, Valid: true}
+		p.Task.ChangedBy = from.TelegramID
+
+		for _, c := range it.Comments {
+			u, ok := m.user(c.Author)
+			if !ok {
+				problem("unknown comment author %v", c.Author)
+				continue
+			}
+
+			date := c.Date.UTC()
+			if date.IsZero() {
+				date = createdAt
+			}
+
+			p.Comments = append(p.Comments, models.DbTaskComments{
+				UserID:  u.TelegramID,
+				Date:    models.NullTime{Time: date, Valid: true},
+				Comment: c.Text,
+			})
+
+			//the Task keeps its last comment the same way as after UpdateTaskComment
+			p.Task.Comment = c.Text
+			p.Task.CommentedAt = models.NullTime{Time: date, Valid: true}
+			p.Task.CommentedBy = u.TelegramID
+		}
+
+		for _, st := range it.Subtasks {
+			stTo := firstNonEmpty(st.To, to)
+			if val, ok := m.Users[st.To]; ok {
+				stTo = val
+			}
+
+			t, _, err := prepareTask(admin, stTo, st.Title, "", "")
+			if err != nil {
+				problem("subtask %v: %v", st.Title, err)
+				continue
+			}
+
+			t.FromUser = from.TelegramID
+			t.ChangedAt = p.Task.ChangedAt
+			t.ChangedBy = from.TelegramID
+			if st.Done {
+				t.Status = models.TaskStatusClosed
+			}
+
+			p.Subtasks = append(p.Subtasks, t)
+		}
+
+		xs = append(xs, p)
+	}
+
+	return xs
+}
+
+//saveTrackerImport saves planned Tasks with their comments and subtasks in one transaction and returns IDs of the Tasks.
+//Nothing is saved if any of them fails. Task history starts with the imported status, recipients aren't notified
+func saveTrackerImport(plans []trackerPlan) ([]int, error) {
+
+	var ids []int
+
+	taskStmt, err := dbase.InsertTask(cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer taskStmt.Close()
+
+	commentStmt, err := dbase.InsertTaskComment(cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer commentStmt.Close()
+
+	//імпорт або записується повністю, або не записується зовсім, тож його можна просто повторити
+	tx, err := cfg.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	insert := func(t models.DbTasks) (int, error) {
+		res, err := dbase.ExecInsertTask(tx.Stmt(taskStmt), t)
+		if err != nil {
+			return 0, err
+		}
+
+		id, err := res.LastInsertId()
+		return int(id), err
+	}
+
+	for _, p := range plans {
+		id, err := insert(p.Task)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %v", p.Source, err)
+		}
+		ids = append(ids, id)
+
+		for _, c := range p.Comments {
+			c.TaskID = id
+			_, err := dbase.ExecInsertTaskComment(tx.Stmt(commentStmt), c)
+			if err != nil {
+				return nil, fmt.Errorf("%v: %v", p.Source, err)
+			}
+		}
+
+		for _, st := range p.Subtasks {
+			st.Description = fmt.Sprintf("Subtask of Task #%v %v", id, p.Task.Title)
+
+			stID, err := insert(st)
+			if err != nil {
+				return nil, fmt.Errorf("%v: %v", p.Source, err)
+			}
+			ids = append(ids, stID)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+//trackerReport describes planned Tasks and their problems for dry run
+func trackerReport(plans []trackerPlan) string {
+
+	var lines []string
+	var tasks, subtasks, comments, problems int
+
+	users := make(map[int]string)
+	name := func(tgid int) string {
+		if _, ok := users[tgid]; !ok {
+			u := dbase.GetUserByTelegramID(cfg, tgid)
+			users[tgid] = strings.TrimSpace(u.FirstName + " " + u.LastName)
+		}
+		return users[tgid]
+	}
+
+	for _, p := range plans {
+		t := p.Task
+
+		line := fmt.Sprintf("%v\t%v\t%v, from %v to %v", p.Source, t.Title, t.Status, name(t.FromUser), name(t.ToUser))
+		if t.DueDate.Valid {
+			line += ", due " + t.DueDate.Time.Format(models.DueDateLayout)
+		}
+		if len(p.Comments) != 0 {
+			line += fmt.Sprintf(", %v comments", len(p.Comments))
+		}
+		if len(p.Subtasks) != 0 {
+			line += fmt.Sprintf(", %v subtasks", len(p.Subtasks))
+		}
+		lines = append(lines, line)
+
+		for _, val := range p.Problems {
+			lines = append(lines, "\t"+val)
+		}
+
+		tasks++
+		subtasks += len(p.Subtasks)
+		comments += len(p.Comments)
+		problems += len(p.Problems)
+	}
+
+	lines = append(lines, fmt.Sprintf("%v Tasks, %v subtasks, %v comments, %v problems", tasks, subtasks, comments, problems))
+
+	return strings.Join(lines, "\n")
+}
+
+func firstNonEmpty(xs ...string) string {
+
+	for _, val := range xs {
+		if val != "" {
+			return val
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/slevchyk/taskeram/models"
+)
+
+func testTrackerPlans() []trackerPlan {
+
+	now := models.NullTime{Time: time.Now().UTC(), Valid: true}
+	task := func(title string) models.DbTasks {
+		return models.DbTasks{FromUser: testAdminID, ToUser: testAssigneeID, Status: models.TaskStatusNew, ChangedAt: now, ChangedBy: testAdminID, Title: title}
+	}
+
+	return []trackerPlan{
+		{
+			Source:   "card 1",
+			Task:     task("Prepare the report"),
+			Comments: []models.DbTaskComments{{UserID: testAdminID, Date: now, Comment: "by Friday"}},
+			Subtasks: []models.DbTasks{task("Collect numbers")},
+		},
+		{
+			Source: "card 2",
+			Task:   task("Send the report"),
+		},
+	}
+}
+
+func TestSaveTrackerImport(t *testing.T) {
+
+	setupTestDB(t)
+
+	ids, err := saveTrackerImport(testTrackerPlans())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ids) != 3 || countRows(t, "tasks") != 4 || countRows(t, "task_comments") != 1 {
+		t.Errorf("imported Tasks %v, %v Tasks and %v comments in DB", ids, countRows(t, "tasks"), countRows(t, "task_comments"))
+	}
+}
+
+func TestSaveTrackerImportIsAtomic(t *testing.T) {
+
+	setupTestDB(t)
+
+	_, err := cfg.DB.Exec(`
+		CREATE TRIGGER fail_import BEFORE INSERT ON tasks WHEN new.title='Send the report'
+		BEGIN
+			SELECT RAISE(ABORT, 'import failed');
+		END;`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids, err := saveTrackerImport(testTrackerPlans())
+	if err == nil {
+		t.Fatal("error of the second Task isn't returned")
+	}
+
+	if len(ids) != 0 || countRows(t, "tasks") != 1 || countRows(t, "task_comments") != 0 || countRows(t, "task_history") != 1 {
+		t.Errorf("failed import left Tasks %v, %v Tasks and %v comments in DB", ids, countRows(t, "tasks"), countRows(t, "task_comments"))
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/slevchyk/taskeram/dbase"
+	"github.com/slevchyk/taskeram/models"
+	"gopkg.in/telegram-bot-api.v4"
+)
+
+//changeUserStatus approves, bans or unbans the user on behalf of by and informs the user about it.
+//The reason is kept in users.comment and in user history. It is the single place for moderating
+//users used by the bot menus and the web admin console
+func changeUserStatus(u models.DbUsers, by models.DbUsers, status string, reason string) (models.DbUsers, error) {
+
+	oldStatus := u.Status
+
+	u.Status = status
+	u.Comment = reason
+	u.ChangedAt = models.NullTime{Time: time.Now().UTC(), Valid: true}
+	u.ChangedBy = by.TelegramID
+
+	stmt, err := dbase.UpdateUserStatusComment(cfg)
+	if err != nil {
+		return u, err
+	}
+
+	_, err = dbase.ExecUpdateUserStatusComment(stmt, u)
+	if err != nil {
+		return u, err
+	}
+
+	var action string
+	switch {
+	case status == models.UserApprowed && oldStatus == models.UserBanned:
+		action = "unbanned"
+	case status == models.UserApprowed:
+		action = "approved"
+	case status == models.UserBanned && oldStatus == models.UserRequested:
+		action = "declined"
+	case status == models.UserBanned:
+		action = "banned"
+	}
+
+	if status == models.UserApprowed && oldStatus == models.UserRequested {
+		fireWebhooks(webhookPayload{Event: models.WebhookUserApproved, User: &u, By: &by})
+	}
+
+	//bot is nil when the status is changed by taskeram user command, Telegram may be unreachable then
+	if action == "" || bot == nil {
+		return u, nil
+	}
+
+	reply := fmt.Sprintf(`Your account has been <b>%v</b> by <a href="tg://user?id=%v">%v %v</a> at %v`, action, by.TelegramID, by.FirstName, by.LastName, u.ChangedAt.Time)
+	if action == "declined" {
+		reply = fmt.Sprintf(`Unfortunately your request has been <b>declined</b> by <a href="tg://user?id=%v">%v %v</a> at %v. Try to text to admin`, by.TelegramID, by.FirstName, by.LastName, u.ChangedAt.Time)
+	} else if action == "banned" {
+		reply = fmt.Sprintf(`Unfortunately your account has been <b>banned</b> by <a href="tg://user?id=%v">%v %v</a> at %v. Try to text to admin`, by.TelegramID, by.FirstName, by.LastName, u.ChangedAt.Time)
+	}
+
+	if reason != "" {
+		reply += fmt.Sprintf("\n<i>reason:</i> %v", reason)
+	}
+
+	msg := tgbotapi.NewMessage(int64(u.TelegramID), reply)
+	msg.ParseMode = "HTML"
+	_, err = bot.Send(msg)
+	if err != nil {
+		log.Println(err)
+	}
+
+	return u, nil
+}
+
+//setUserRole changes the user role and keeps the admin flag in sync with it
+func setUserRole(u models.DbUsers, by models.DbUsers, role string) (models.DbUsers, error) {
+
+	u.Role = role
+	u.Admin = 0
+	if role == models.RoleAdmin {
+		u.Admin = 1
+	}
+	u.ChangedAt = models.NullTime{Time: time.Now().UTC(), Valid: true}
+	u.ChangedBy = by.TelegramID
+
+	stmt, err := dbase.UpdateUserRole(cfg)
+	if err != nil {
+		return u, err
+	}
+
+	_, err = dbase.ExecUpdateUserRole(stmt, u)
+	return u, err
+}
+
+//selectUserHistory returns status and role changes of the user, the latest first
+func selectUserHistory(u models.DbUsers) []models.DbUserHistory {
+
+	var h models.DbUserHistory
+	var xs []models.DbUserHistory
+
+	rows, err := dbase.SelectUserHistory(cfg, u.ID)
+	if err != nil {
+		log.Println(fmt.Errorf("SelectUserHistory: %v", err))
+		return xs
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		err := dbase.ScanUserHistory(rows, &h)
+		if err != nil {
+			log.Println(err)
+		} else {
+			xs = append(xs, h)
+		}
+	}
+
+	return xs
+}
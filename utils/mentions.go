@@ -0,0 +1,57 @@
+package utils
+
+import (
+	"html"
+	"regexp"
+
+	"github.com/slevchyk/taskeram/models"
+)
+
+//mentionRegexp matches "@firstname", "@username" and "#user<telegram id>" references.
+//A mention has to start the text or follow a non-word symbol, so e-mails aren't treated as mentions
+var mentionRegexp = regexp.MustCompile(`(^|[^\p{L}\p{N}_])(@[\p{L}\p{N}_]+|#user[0-9]+)`)
+
+//FindMentions returns unique mentions found in the text in order of appearance
+func FindMentions(text string) []string {
+
+	var xs []string
+	found := make(map[string]bool)
+
+	for _, val := range mentionRegexp.FindAllStringSubmatch(text, -1) {
+		if found[val[2]] {
+			continue
+		}
+
+		found[val[2]] = true
+		xs = append(xs, val[2])
+	}
+
+	return xs
+}
+
+//RenderMentions escapes the text for HTML output and replaces resolved mentions with the link made by linkFunc.
+//Unresolved mentions are left as plain text
+func RenderMentions(text string, users map[string]models.DbUsers, linkFunc func(u models.DbUsers, mention string) string) string {
+
+	var result string
+	last := 0
+
+	for _, loc := range mentionRegexp.FindAllStringSubmatchIndex(text, -1) {
+		start, end := loc[4], loc[5]
+		mention := text[start:end]
+
+		result += html.EscapeString(text[last:start])
+
+		if u, ok := users[mention]; ok {
+			result += linkFunc(u, html.EscapeString(mention))
+		} else {
+			result += html.EscapeString(mention)
+		}
+
+		last = end
+	}
+
+	result += html.EscapeString(text[last:])
+
+	return result
+}
@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//CheckTelegramAuth verifies data sent by Telegram Login Widget against the bot token
+//as described in https://core.telegram.org/widgets/login#checking-authorization
+//and returns Telegram ID of the user. Data older than maxAge is rejected
+func CheckTelegramAuth(values url.Values, botToken string, maxAge time.Duration) (int, error) {
+
+	hash := values.Get("hash")
+	if hash == "" {
+		return 0, errors.New("telegram auth: hash is missing")
+	}
+
+	var pairs []string
+	for key := range values {
+		if key == "hash" {
+			continue
+		}
+		pairs = append(pairs, key+"="+values.Get(key))
+	}
+	sort.Strings(pairs)
+
+	secret := sha256.Sum256([]byte(botToken))
+	mac := hmac.New(sha256.New, secret[:])
+	mac.Write([]byte(strings.Join(pairs, "\n")))
+
+	expected := hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(expected), []byte(strings.ToLower(hash))) {
+		return 0, errors.New("telegram auth: data is not from Telegram")
+	}
+
+	authDate, err := strconv.ParseInt(values.Get("auth_date"), 10, 64)
+	if err != nil {
+		return 0, errors.New("telegram auth: wrong auth_date")
+	}
+
+	if time.Since(time.Unix(authDate, 0)) > maxAge {
+		return 0, errors.New("telegram auth: data is outdated")
+	}
+
+	tgid, err := strconv.Atoi(values.Get("id"))
+	if err != nil {
+		return 0, errors.New("telegram auth: wrong id")
+	}
+
+	return tgid, nil
+}
@@ -0,0 +1,110 @@
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+var xlsxFiles = []struct {
+	Name    string
+	Content string
+}{
+	{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>
+<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>
+</Types>`},
+	{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>
+</Relationships>`},
+	{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>
+</Relationships>`},
+}
+
+//WriteXLSX writes rows as a single sheet Excel workbook. All cells are text
+func WriteXLSX(w io.Writer, sheet string, rows [][]string) error {
+
+	zw := zip.NewWriter(w)
+
+	for _, f := range xlsxFiles {
+		fw, err := zw.Create(f.Name)
+		if err != nil {
+			return err
+		}
+
+		_, err = io.WriteString(fw, f.Content)
+		if err != nil {
+			return err
+		}
+	}
+
+	var name bytes.Buffer
+	err := xml.EscapeText(&name, []byte(sheet))
+	if err != nil {
+		return err
+	}
+
+	fw, err := zw.Create("xl/workbook.xml")
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(fw, `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="%v" sheetId="1" r:id="rId1"/></sheets>
+</workbook>`, name.String())
+	if err != nil {
+		return err
+	}
+
+	fw, err = zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return err
+	}
+
+	var b bytes.Buffer
+
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+
+	for i, row := range rows {
+		fmt.Fprintf(&b, `<row r="%v">`, i+1)
+		for j, val := range row {
+			fmt.Fprintf(&b, `<c r="%v%v" t="inlineStr"><is><t xml:space="preserve">`, xlsxColumn(j), i+1)
+			err := xml.EscapeText(&b, []byte(val))
+			if err != nil {
+				return err
+			}
+			b.WriteString(`</t></is></c>`)
+		}
+		b.WriteString(`</row>`)
+	}
+
+	b.WriteString(`</sheetData></worksheet>`)
+
+	_, err = b.WriteTo(fw)
+	if err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+//xlsxColumn returns column name by index: A, B, ..., Z, AA, AB...
+func xlsxColumn(i int) string {
+
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+
+	return name
+}
@@ -1,12 +1,15 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
 	"github.com/satori/go.uuid"
 	"github.com/slevchyk/taskeram/dbase"
 	"github.com/slevchyk/taskeram/models"
+	"github.com/slevchyk/taskeram/notifier"
+	"github.com/slevchyk/taskeram/router"
 	"github.com/slevchyk/taskeram/utils"
 	"gopkg.in/telegram-bot-api.v4"
 	"html/template"
@@ -18,61 +21,163 @@ import (
 	"time"
 )
 
-func startWebApp() {
+//userKey keeps the logged in user in the request context
+type userKey struct{}
 
-	http.Handle("/public/", http.StripPrefix("/public", http.FileServer(http.Dir("./public"))))
-	http.Handle("/assets/", http.StripPrefix("/assets", http.FileServer(http.Dir("./assets"))))
-	http.HandleFunc("/", indexHandler)
-	http.HandleFunc("/login", loginHandler)
-	http.HandleFunc("/auth", authHandler)
-	http.HandleFunc("/logout", logoutHandler)
-	http.HandleFunc("/tasks", tasksHandler)
-	http.HandleFunc("/task", taskHanlder)
-	http.HandleFunc("/user", userHanlder)
-	http.HandleFunc("/api/history", apiHistoryHandler)
-	http.HandleFunc("/api/updatetaskstatus", apiUpdateTaskStatusHandler)
-	http.HandleFunc("/api/commenttask", apiCommentTaskHandler)
-	err := http.ListenAndServe(":80", nil)
+//newWebRouter returns routes of the web app with their middlewares
+func newWebRouter() *router.Router {
+
+	rt := router.New()
+	rt.Use(router.RequestID, router.Logger, router.Recoverer, router.Timeout(webRequestTimeout))
+
+	rt.Get("/public/*", http.StripPrefix("/public", http.FileServer(http.Dir("./public"))).ServeHTTP)
+	rt.Get("/assets/*", http.StripPrefix("/assets", http.FileServer(http.Dir("./assets"))).ServeHTTP)
+
+	rt.Get("/login", loginHandler)
+	rt.Post("/login", loginHandler)
+	rt.Get("/auth", authHandler)
+	rt.Get("/auth/telegram", telegramAuthHandler)
+	rt.Get("/logout", logoutHandler)
+
+	rt.Get("/", requireLogin(indexHandler))
+	rt.Get("/tasks", requireLogin(tasksHandler))
+	rt.Get("/tasks/{id}", requireLogin(taskHanlder))
+
+	//сторінки з формами, які змінюють дані, перевіряють CSRF токен
+	for pattern, h := range map[string]http.HandlerFunc{
+		"/task":        taskHanlder,
+		"/user":        userHanlder,
+		"/webhooks":    webhooksHandler,
+		"/teams":       teamsHandler,
+		"/admin/users": adminUsersHandler,
+		"/invites":     invitesHandler,
+		"/import":      importHandler,
+	} {
+		rt.Get(pattern, requireLogin(csrfProtect(h)))
+		rt.Post(pattern, requireLogin(csrfProtect(h)))
+	}
+
+	//API для сторінки задачі, сесію передають параметром або cookie. Зміни приймаємо лише POST з CSRF токеном
+	rt.Get("/api/history", apiHistoryHandler)
+	rt.Post("/api/updatetaskstatus", originProtect(csrfProtect(apiUpdateTaskStatusHandler)))
+	rt.Post("/api/commenttask", originProtect(csrfProtect(apiCommentTaskHandler)))
+
+	rt.Post("/api/tasks", apiTasksHandler)
+	rt.Post("/api/tasks/mail", apiTasksMailHandler)
+
+	//календар підписують сторонні програми, тому доступ лише за токеном у посиланні
+	rt.Get("/calendar/{token}", calendarHandler)
+
+	return rt
+}
+
+//startWebApp starts the web server in background and returns it for shutdown
+func startWebApp() *http.Server {
+
+	srv, err := newWebServer(newWebRouter())
 	if err != nil {
-		panic(err)
+		log.Fatal(err)
+	}
+
+	go func() {
+		var err error
+
+		if srv.TLSConfig != nil || cfg.Web.CertFile != "" {
+			err = srv.ListenAndServeTLS(cfg.Web.CertFile, cfg.Web.KeyFile)
+		} else {
+			err = srv.ListenAndServe()
+		}
+
+		if err != nil && err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+
+	return srv
+}
+
+//requireLogin lets only logged in users through, others are redirected to the login page.
+//The user is available to the handler with currentUser
+func requireLogin(next http.HandlerFunc) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		loggedIn, user := alreadyLoggedIn(w, r, "")
+		if !loggedIn {
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return
+		}
+
+		next(w, r.WithContext(context.WithValue(r.Context(), userKey{}, user)))
 	}
 }
 
+//currentUser returns the user set by requireLogin
+func currentUser(r *http.Request) models.DbUsers {
+
+	u, _ := r.Context().Value(userKey{}).(models.DbUsers)
+	return u
+}
+
+//apiMaxCommentSize limits comments sent by the task page
+const apiMaxCommentSize = 64 << 10
+
 func apiCommentTaskHandler(w http.ResponseWriter, r *http.Request) {
 
 	sessionUUID := r.FormValue("session")
 	taskIDValue := r.FormValue("id")
 
-	commentByte, err := ioutil.ReadAll(r.Body)
-	if err != nil {
+	commentByte, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, apiMaxCommentSize))
+	if isBodyTooLarge(err) {
+		http.Error(w, fmt.Sprintf("Comment is bigger than %v KB", apiMaxCommentSize>>10), http.StatusRequestEntityTooLarge)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
 	comment := string(commentByte)
 
-	if sessionUUID == "" || taskIDValue == "" || comment == "" {
+	if taskIDValue == "" || comment == "" {
+		http.Error(w, "Task ID and comment are required", http.StatusBadRequest)
 		return
 	}
 
 	loggedIn, user := alreadyLoggedIn(w, r, sessionUUID)
 	if !loggedIn {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
 	taskID, err := strconv.Atoi(taskIDValue)
 	if err != nil {
+		http.Error(w, "Wrong Task ID", http.StatusBadRequest)
 		return
 	}
 
-	stmt, err := dbase.UpdateTaskComment(cfg)
+	t, err := getTask(taskID)
 	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if t.ID == 0 || !canViewTask(user, t) {
+		http.Error(w, "Access denied", http.StatusNotFound)
+		return
+	}
+
+	if !taskRules[taskTypeOf(t, user.TelegramID)][t.Status].Contains(models.Comment) {
+		http.Error(w, fmt.Sprintf("It isn't allowed to comment Task #%v", t.ID), http.StatusForbidden)
 		return
 	}
 
-	_, err = stmt.Exec(comment, time.Now().UTC(), user.TelegramID, taskID)
+	err = saveTaskComment(t, user, comment)
 	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+
+	informNewComment(t, user, comment)
 }
 
 func apiUpdateTaskStatusHandler(w http.ResponseWriter, r *http.Request) {
@@ -81,27 +186,40 @@ func apiUpdateTaskStatusHandler(w http.ResponseWriter, r *http.Request) {
 	taskIDValue := r.FormValue("id")
 	status := r.FormValue("status")
 
-	if sessionUUID == "" || taskIDValue == "" || status == "" {
+	if taskIDValue == "" || status == "" {
+		http.Error(w, "Task ID and status are required", http.StatusBadRequest)
 		return
 	}
 
 	loggedIn, user := alreadyLoggedIn(w, r, sessionUUID)
 	if !loggedIn {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
 	taskID, err := strconv.Atoi(taskIDValue)
 	if err != nil {
+		http.Error(w, "Wrong Task ID", http.StatusBadRequest)
 		return
 	}
 
-	stmt, err := dbase.UpdateTaskStatus(cfg)
+	t, err := getTask(taskID)
 	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	_, err = stmt.Exec(status, time.Now().UTC(), user.TelegramID, taskID)
-	if err != nil {
+	if t.ID == 0 || !canViewTask(user, t) {
+		http.Error(w, "Access denied", http.StatusNotFound)
+		return
+	}
+
+	_, err = changeTaskStatus(t, user, statusAction(status))
+	if err == errStatusForbidden {
+		http.Error(w, fmt.Sprintf("It isn't allowed to change the status to %v for Task #%v", status, t.ID), http.StatusForbidden)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 }
@@ -111,7 +229,7 @@ func apiHistoryHandler(w http.ResponseWriter, r *http.Request) {
 	sessionUUID := r.FormValue("session")
 	taskIDValue := r.FormValue("id")
 
-	if sessionUUID == "" || taskIDValue == "" {
+	if taskIDValue == "" {
 		return
 	}
 
@@ -151,14 +269,12 @@ func indexHandler(w http.ResponseWriter, r *http.Request) {
 
 	var td models.TplIndex
 
-	loggedIn, user := alreadyLoggedIn(w, r, "")
-	if !loggedIn {
-		http.Redirect(w, r, "/login", http.StatusSeeOther)
-	}
+	user := currentUser(r)
 
 	td.NavBar.MainMenu = getMainMenu("")
-	td.NavBar.LoggedIn = loggedIn
+	td.NavBar.LoggedIn = true
 	td.NavBar.User = user
+	td.NavBar.CSRF = csrfToken(r)
 
 	err := tpl.ExecuteTemplate(w, "index.gohtml", td)
 	if err != nil {
@@ -178,6 +294,7 @@ func authHandler(w http.ResponseWriter, r *http.Request) {
 
 	if token.Value == "" {
 		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
 	}
 
 	rows, err := dbase.SelectAuthByToken(cfg, token.Value)
@@ -189,11 +306,14 @@ func authHandler(w http.ResponseWriter, r *http.Request) {
 	if rows.Next() {
 		err := dbase.ScanAuth(rows, &a)
 		if err != nil {
+			rows.Close()
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 	} else {
+		rows.Close()
 		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
 	}
 	rows.Close()
 
@@ -213,6 +333,7 @@ func authHandler(w http.ResponseWriter, r *http.Request) {
 		token.MaxAge = -1
 		http.SetCookie(w, token)
 		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
 	}
 
 	if a.Approved == 1 {
@@ -231,36 +352,14 @@ func authHandler(w http.ResponseWriter, r *http.Request) {
 		token.MaxAge = -1
 		http.SetCookie(w, token)
 
-		var s models.DbSessions
-
-		sessionUUID, _ := uuid.NewV4()
-
-		s.UUID = sessionUUID.String()
-		s.TelegramID = a.TelegramID
-		s.LastActivity.Time = time.Now().UTC()
-		s.IP = r.RemoteAddr
-		s.UserAgent = r.Header.Get("User-Agent")
-		s.StartedAt = s.LastActivity
-
-		stmt, err = dbase.InsertSession(cfg)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-
-		_, err = dbase.ExecInsertSession(stmt, s)
+		err = startSession(w, r, a.TelegramID)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		c := &http.Cookie{
-			Name:  "session",
-			Value: s.UUID,
-		}
-		http.SetCookie(w, c)
-
 		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
 	}
 
 	err = tpl.ExecuteTemplate(w, "auth.gohtml", nil)
@@ -269,16 +368,49 @@ func authHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+//telegramAuthHandler is the callback of Telegram Login Widget. The auth data is signed with the bot token
+func telegramAuthHandler(w http.ResponseWriter, r *http.Request) {
+
+	if cfg.Web.Login == models.LoginBot {
+		http.NotFound(w, r)
+		return
+	}
+
+	tgid, err := utils.CheckTelegramAuth(r.URL.Query(), cfg.Telegram.Token, telegramAuthMaxAge)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, "Telegram authorization failed", http.StatusForbidden)
+		return
+	}
+
+	u := dbase.GetUserByTelegramID(cfg, tgid)
+	if u.ID == 0 || u.Status != models.UserApprowed {
+		http.Error(w, "Your account isn't approved in Taskeram", http.StatusForbidden)
+		return
+	}
+
+	err = startSession(w, r, tgid)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
 func loginHandler(w http.ResponseWriter, r *http.Request) {
 
 	var td models.TplLogin
 	var u models.DbUsers
 
-	if r.Method == http.MethodPost {
-		tgidString := r.FormValue("tdid")
+	td.BotName = bot.Self.UserName
+	td.Widget = cfg.Web.Login != models.LoginBot
+	td.Bot = cfg.Web.Login != models.LoginWidget
 
-		tgid, err := strconv.Atoi(tgidString)
-		if err != nil {
+	if r.Method == http.MethodPost && td.Bot {
+		//користувача шукаємо за Telegram ID чи username, список всіх користувачів більше не показуємо
+		tgid := findTaskRecipient(r.FormValue("tdid")).TelegramID
+		if tgid == 0 {
 			http.Error(w, "Incorrect user id", http.StatusForbidden)
 			return
 		}
@@ -292,11 +424,14 @@ func loginHandler(w http.ResponseWriter, r *http.Request) {
 		if rows.Next() {
 			err := dbase.ScanUser(rows, &u)
 			if err != nil {
+				rows.Close()
 				http.Error(w, err.Error(), http.StatusInternalServerError)
 				return
 			}
 		} else {
-			http.Redirect(w, r, "/login", http.StatusBadRequest)
+			rows.Close()
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return
 		}
 		rows.Close()
 
@@ -326,9 +461,11 @@ func loginHandler(w http.ResponseWriter, r *http.Request) {
 		}
 
 		c := &http.Cookie{
-			Name:   "token",
-			Value:  a.Token,
-			MaxAge: authSessionLengt,
+			Name:     "token",
+			Value:    a.Token,
+			MaxAge:   authSessionLengt,
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
 		}
 		http.SetCookie(w, c)
 
@@ -352,23 +489,7 @@ func loginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	rows, err := dbase.SelectUsersByStatus(cfg, models.UserApprowed)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	for rows.Next() {
-		err := dbase.ScanUser(rows, &u)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-		td.Users = append(td.Users, u)
-	}
-	rows.Close()
-
-	err = tpl.ExecuteTemplate(w, "login.gohtml", td)
+	err := tpl.ExecuteTemplate(w, "login.gohtml", td)
 	if err != nil {
 		log.Println(err)
 	}
@@ -376,10 +497,7 @@ func loginHandler(w http.ResponseWriter, r *http.Request) {
 
 func tasksHandler(w http.ResponseWriter, r *http.Request) {
 
-	loggedIn, user := alreadyLoggedIn(w, r, "")
-	if !loggedIn {
-		http.Redirect(w, r, "/login", http.StatusSeeOther)
-	}
+	user := currentUser(r)
 
 	var (
 		td   models.TplTasks
@@ -390,8 +508,14 @@ func tasksHandler(w http.ResponseWriter, r *http.Request) {
 		err  error
 	)
 
-	td.NavBar.LoggedIn = loggedIn
+	td.NavBar.LoggedIn = true
 	td.NavBar.User = user
+	td.NavBar.CSRF = csrfToken(r)
+
+	if r.FormValue("export") != "" {
+		exportTasks(w, r, user)
+		return
+	}
 
 	taskStatus := r.FormValue("status")
 	taskStatus = strings.Title(taskStatus)
@@ -405,6 +529,9 @@ func tasksHandler(w http.ResponseWriter, r *http.Request) {
 		rows, err = dbase.SelectInboxTasks(cfg, user.TelegramID, taskStatus)
 	case "sent":
 		rows, err = dbase.SelectSentTasks(cfg, user.TelegramID, taskStatus)
+	case "team":
+		//задачі учасників команд, якими керує користувач
+		rows, err = dbase.SelectTeamTasks(cfg, user.TelegramID, taskStatus)
 	default:
 		rows, err = dbase.SelectInboxTasks(cfg, user.TelegramID, taskStatus)
 	}
@@ -413,6 +540,8 @@ func tasksHandler(w http.ResponseWriter, r *http.Request) {
 		log.Println(err)
 	}
 
+	mentionUsers := selectMentionUsers()
+
 	for rows.Next() {
 		err := dbase.ScanTask(rows, &t)
 		if err != nil {
@@ -424,13 +553,15 @@ func tasksHandler(w http.ResponseWriter, r *http.Request) {
 		tu := dbase.GetUserByTelegramID(cfg, t.ToUser)
 		fu := dbase.GetUserByTelegramID(cfg, t.FromUser)
 
-		sr = append(sr, models.TasksRow{Number: i, Task: t, ToUser: tu, FromUser: fu})
+		sr = append(sr, models.TasksRow{Number: i, Task: t, ToUser: tu, FromUser: fu, Description: mentionsToWebHTML(t.Description, mentionUsers)})
 	}
 	rows.Close()
 
 	td.NavBar.MainMenu = getMainMenu(taskType)
 	td.Tabs = template.HTML(getTasksTabs(taskType, taskStatus))
 	td.Rows = sr
+	td.Type = taskType
+	td.Status = strings.ToLower(taskStatus)
 
 	err = tpl.ExecuteTemplate(w, "tasks.gohtml", td)
 	if err != nil {
@@ -441,13 +572,9 @@ func tasksHandler(w http.ResponseWriter, r *http.Request) {
 func taskHanlder(w http.ResponseWriter, r *http.Request) {
 
 	var td models.TplTask
-	var t models.DbTasks
 	var u models.DbUsers
 
-	loggedIn, user := alreadyLoggedIn(w, r, "")
-	if !loggedIn {
-		http.Redirect(w, r, "/login", http.StatusSeeOther)
-	}
+	user := currentUser(r)
 
 	rows, err := dbase.SelectUsersByStatus(cfg, models.UserApprowed)
 	if err != nil {
@@ -461,69 +588,47 @@ func taskHanlder(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, fmt.Sprintf("Scaning users for task. Err: %v", err), http.StatusInternalServerError)
 			return
 		}
-		td.Users = append(td.Users, u)
+		if canAssignTask(user, u) {
+			td.Users = append(td.Users, u)
+		}
 	}
 	rows.Close()
 
 	td.Edit = false
 
+	//зміни приймаємо лише POST запитом з CSRF токеном, GET /tasks/{id} тільки показує задачу
 	do := r.FormValue("do")
+	if do != "" && r.Method != http.MethodPost {
+		http.Error(w, fmt.Sprintf("%v is allowed with POST only", do), http.StatusMethodNotAllowed)
+		return
+	}
+
 	switch do {
 	case "add":
 
-		t.FromUser = user.TelegramID
-
-		tgid, err := strconv.Atoi(r.FormValue("toUser"))
-		if err != nil {
-			http.Error(w, fmt.Sprintf("Adding new task. Converting user tg id to int. Err: %v", err), http.StatusInternalServerError)
-			return
-		}
-
-		u = dbase.GetUserByTelegramID(cfg, tgid)
-		if u.ID == 0 {
-			http.Error(w, fmt.Sprintf("Adding new task. Can't find any user by tg id."), http.StatusInternalServerError)
-			return
-		}
-
-		t.ToUser = tgid
-		t.Status = models.TaskStatusNew
-		t.ChangedAt.Time = time.Now().UTC()
-		t.ChangedBy = user.TelegramID
-		t.Title = r.FormValue("title")
-		t.Description = r.FormValue("description")
-
-		stmt, err := dbase.InsertTask(cfg)
-		if err != nil {
-			http.Error(w, fmt.Sprintf("Adding new task. Inserting new task. Err: %v", err), http.StatusInternalServerError)
+		_, err := createTask(user, r.FormValue("toUser"), r.FormValue("title"), r.FormValue("description"), r.FormValue("dueDate"))
+		if _, ok := err.(taskInputError); ok {
+			http.Error(w, fmt.Sprintf("Adding new task. Err: %v", err), http.StatusBadRequest)
 			return
-		}
-
-		res, err := dbase.ExecInsertTask(stmt, t)
-		if err != nil {
-			http.Error(w, fmt.Sprintf("Adding new task. Inserting (commit) new task. Err: %v", err), http.StatusInternalServerError)
+		} else if err == errTaskForbidden {
+			http.Error(w, fmt.Sprintf("Adding new task. Err: %v", err), http.StatusForbidden)
 			return
-		}
-
-		newTaskID, err := res.LastInsertId()
-		if err != nil {
-			http.Error(w, fmt.Sprintf("Adding new task. Sql result (new task id). Err: %v", err), http.StatusInternalServerError)
+		} else if err != nil {
+			http.Error(w, fmt.Sprintf("Adding new task. Err: %v", err), http.StatusInternalServerError)
 			return
 		}
 
-		informNewTask(newTaskID, t, user, u)
 		http.Redirect(w, r, fmt.Sprintf("/tasks?type=sent&status=%v", strings.ToLower(models.TaskStatusNew)), http.StatusSeeOther)
+		return
 	case "update":
 
 		var t models.DbTasks
 
 		taskIDValue := r.FormValue("id")
-		if taskIDValue == "" {
-			return
-		}
 
 		taskID, err := strconv.Atoi(taskIDValue)
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Updating task. Can't convert task id to int. Err: %v", err), http.StatusInternalServerError)
+			http.Error(w, fmt.Sprintf("Updating task. Wrong task id %v", taskIDValue), http.StatusBadRequest)
 			return
 		}
 
@@ -536,55 +641,39 @@ func taskHanlder(w http.ResponseWriter, r *http.Request) {
 		if rows.Next() {
 			err := dbase.ScanTask(rows, &t)
 			if err != nil {
+				rows.Close()
 				http.Error(w, fmt.Sprintf("Updating new task. Scaning task. Err: %v", err), http.StatusInternalServerError)
 				return
 			}
 		} else {
+			rows.Close()
+			http.Error(w, "Access denied", http.StatusNotFound)
 			return
 		}
 		rows.Close()
 
-		//встановимо тип задачі
-		var taskType string
-		if t.ToUser == user.TelegramID {
-			taskType = "Inbox"
-		} else {
-			taskType = "Sent"
-		}
-
-		//для значення "status" перетворимо у верхній регістр першу літеру так як в нас заведено в константах
-		newStatus := strings.Title(r.FormValue("status"))
-
-		//якщо при поновленні змінюється статус
-		if newStatus != "" {
-			//перевіримо чи новий статус доступний для цієї задачі
-			rule := taskRules[taskType][t.Status]
-			if !rule.Contains(newStatus) {
-				http.Redirect(w, r, "/", http.StatusNotFound)
-			}
-
-			t.Status = newStatus
-			t.ChangedAt.Time = time.Now().UTC()
-			t.ChangedBy = user.TelegramID
-
-			stmt, err := dbase.UpdateTaskStatus(cfg)
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+		//якщо при поновленні змінюється статус, значення "status" - дія або новий статус, наприклад start чи started
+		if status := r.FormValue("status"); status != "" {
+			t, err = changeTaskStatus(t, user, statusAction(status))
+			if err == errStatusForbidden {
+				http.Error(w, fmt.Sprintf("It isn't allowed to change the status to %v for Task #%v", status, t.ID), http.StatusForbidden)
 				return
-			}
-
-			_, err = dbase.ExecUpdateTaskStatus(stmt, t)
-			if err != nil {
+			} else if err != nil {
 				http.Error(w, err.Error(), http.StatusInternalServerError)
 				return
 			}
 		}
 
+		http.Redirect(w, r, fmt.Sprintf("/tasks/%v", t.ID), http.StatusSeeOther)
+		return
 	default:
 
 		var t models.DbTasks
 
-		taskIDValue := r.FormValue("id")
+		taskIDValue := router.Param(r, "id")
+		if taskIDValue == "" {
+			taskIDValue = r.FormValue("id")
+		}
 		if taskIDValue == "" {
 			break
 		}
@@ -597,28 +686,36 @@ func taskHanlder(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		rows, err := dbase.SelectTasksByIDUserTelegramID(cfg, taskID, user.TelegramID)
+		rows, err := dbase.SelectTasksByID(cfg, taskID)
 		if err != nil {
 			http.Error(w, fmt.Sprintf("Updating new task. Selecting task by id. Err: %v", err), http.StatusInternalServerError)
 			return
 		}
+		defer rows.Close()
 
-		if rows.Next() {
-			err := dbase.ScanTask(rows, &t)
-			if err != nil {
-				http.Error(w, fmt.Sprintf("Updating new task. Scaning task. Err: %v", err), http.StatusInternalServerError)
-				return
-			}
-		} else {
+		if !rows.Next() {
+			http.NotFound(w, r)
+			return
+		}
+
+		err = dbase.ScanTask(rows, &t)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Updating new task. Scaning task. Err: %v", err), http.StatusInternalServerError)
 			return
 		}
 		rows.Close()
 
+		if !canViewTask(user, t) {
+			http.Error(w, "Access denied", http.StatusForbidden)
+			return
+		}
+
+		//учасник задачі може змінювати статус, інші лише переглядають
 		var taskType string
 
 		if t.ToUser == user.TelegramID {
 			taskType = "Inbox"
-		} else {
+		} else if t.FromUser == user.TelegramID {
 			taskType = "Sent"
 		}
 
@@ -631,13 +728,18 @@ func taskHanlder(w http.ResponseWriter, r *http.Request) {
 		}
 
 		td.Task = t
+		mentionUsers := selectMentionUsers()
+		td.Description = mentionsToWebHTML(t.Description, mentionUsers)
+		td.Comment = mentionsToWebHTML(t.Comment, mentionUsers)
 		td.ToUser = dbase.GetUserByTelegramID(cfg, t.ToUser)
 		td.FromUser = dbase.GetUserByTelegramID(cfg, t.FromUser)
 		td.CommentedBy = dbase.GetUserByTelegramID(cfg, t.CommentedBy)
+		td.BotLink = taskDeepLink(t.ID)
 	}
 
-	td.NavBar.LoggedIn = loggedIn
+	td.NavBar.LoggedIn = true
 	td.NavBar.User = user
+	td.NavBar.CSRF = csrfToken(r)
 	td.NavBar.MainMenu = getMainMenu("new")
 
 	err = tpl.ExecuteTemplate(w, "task.gohtml", td)
@@ -652,18 +754,16 @@ func userHanlder(w http.ResponseWriter, r *http.Request) {
 	var u models.DbUsers
 	var err error
 
-	loggedIn, user := alreadyLoggedIn(w, r, "")
-	if !loggedIn {
-		http.Redirect(w, r, "/login", http.StatusSeeOther)
-	}
+	user := currentUser(r)
 
 	tgidString := r.FormValue("id")
 	tgid, err := strconv.Atoi(tgidString)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("Wrong user id %v", tgidString), http.StatusBadRequest)
+		return
 	}
 
-	if user.TelegramID != tgid && user.Admin != 1 {
+	if user.TelegramID != tgid && !can(user, permEditProfiles) {
 		http.Error(w, "Access denied", http.StatusNotFound)
 		return
 	}
@@ -732,55 +832,911 @@ func userHanlder(w http.ResponseWriter, r *http.Request) {
 				}
 			}
 
-			if u.ID == user.ID {
-				user = u
+			settings := dbase.GetUserSettings(cfg, u.TelegramID)
+			settingsChanged := false
+
+			timezone := r.FormValue("timezone")
+			if timezone != "" && timezone != settings.Timezone {
+				_, err := time.LoadLocation(timezone)
+				if err != nil {
+					http.Error(w, fmt.Sprintf("Unknown time zone %v", timezone), http.StatusBadRequest)
+					return
+				}
+				settings.Timezone = timezone
+				settingsChanged = true
 			}
 
-			http.Redirect(w, r, fmt.Sprintf("/user?id=%v", u.TelegramID), http.StatusSeeOther)
-		}
-	}
+			digest := r.FormValue("digest")
+			if digest != settings.Digest && (digest == models.DigestOff || digest == models.DigestDaily || digest == models.DigestWeekly) {
+				settings.Digest = digest
+				settingsChanged = true
+			}
 
-	td.NavBar.LoggedIn = loggedIn
-	td.NavBar.MainMenu = getMainMenu("")
-	td.NavBar.User = user
+			digestTime := r.FormValue("digestTime")
+			if digestTime != "" && digestTime != settings.DigestTime {
+				_, err := time.Parse(digestTimeLayout, digestTime)
+				if err != nil {
+					http.Error(w, fmt.Sprintf("Wrong digest time %v", digestTime), http.StatusBadRequest)
+					return
+				}
+				settings.DigestTime = digestTime
+				settingsChanged = true
+			}
 
-	td.User = u
+			digestWeekday, err := strconv.Atoi(r.FormValue("digestWeekday"))
+			if err == nil && digestWeekday >= 0 && digestWeekday <= 6 && digestWeekday != settings.DigestWeekday {
+				settings.DigestWeekday = digestWeekday
+				settingsChanged = true
+			}
 
-	err = tpl.ExecuteTemplate(w, "user.gohtml", td)
-	if err != nil {
-		log.Println(err)
-	}
-}
+			for _, val := range []struct {
+				field *int
+				name  string
+			}{
+				{&settings.NotifyNewTask, "notifyNewTask"},
+				{&settings.NotifyStatus, "notifyStatus"},
+				{&settings.NotifyComment, "notifyComment"},
+				{&settings.NotifyMention, "notifyMention"},
+			} {
+				//unchecked checkbox isn't sent with the form at all
+				notify := 0
+				if r.FormValue(val.name) != "" {
+					notify = 1
+				}
 
-func getTasksTabs(taskType string, status string) string {
+				if *val.field != notify {
+					*val.field = notify
+					settingsChanged = true
+				}
+			}
 
-	if status == "" {
-		status = models.TaskStatusNew
-	}
+			quietFrom := r.FormValue("quietFrom")
+			quietTo := r.FormValue("quietTo")
+			if quietFrom == "" || quietTo == "" {
+				quietFrom = ""
+				quietTo = ""
+			}
 
-	var xs []string
+			if quietFrom != settings.QuietFrom || quietTo != settings.QuietTo {
+				_, errFrom := time.Parse(digestTimeLayout, quietFrom)
+				_, errTo := time.Parse(digestTimeLayout, quietTo)
+				if quietFrom != "" && (errFrom != nil || errTo != nil) {
+					http.Error(w, fmt.Sprintf("Wrong quiet hours %v - %v", quietFrom, quietTo), http.StatusBadRequest)
+					return
+				}
+				settings.QuietFrom = quietFrom
+				settings.QuietTo = quietTo
+				settingsChanged = true
+			}
 
-	xs = append(xs, models.TaskStatusNew)
-	xs = append(xs, models.TaskStatusStarted)
-	xs = append(xs, models.TaskStatusRejected)
-	xs = append(xs, models.TaskStatusCompleted)
-	xs = append(xs, models.TaskStatusClosed)
+			email := strings.TrimSpace(r.FormValue("email"))
+			if email != settings.Email {
+				if email != "" && !strings.Contains(email, "@") {
+					http.Error(w, fmt.Sprintf("Wrong e-mail %v", email), http.StatusBadRequest)
+					return
+				}
+				settings.Email = email
+				settingsChanged = true
+			}
 
-	nav := `<div class="row">
-	<ul class="nav nav-tabs">`
+			webhookURL := strings.TrimSpace(r.FormValue("webhookURL"))
+			if webhookURL != settings.WebhookURL {
+				if webhookURL != "" && !strings.HasPrefix(webhookURL, "http://") && !strings.HasPrefix(webhookURL, "https://") {
+					http.Error(w, fmt.Sprintf("Wrong webhook URL %v", webhookURL), http.StatusBadRequest)
+					return
+				}
+				settings.WebhookURL = webhookURL
+				settingsChanged = true
+			}
 
-	for _, val := range xs {
-		nav += fmt.Sprintf(`<li class="nav-item">
-	<a class="nav-link%v" href="/tasks?type=%v&status=%v">%v</a>
-	</li>`, isStatusActive(status, val), taskType, strings.ToLower(val), val)
-	}
+			if settingsChanged {
+				err := saveUserSettings(settings)
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+			}
 
-	nav += fmt.Sprintln(`	
-		</ul>
-	</div>`)
+			eventsChannels := eventChannels(u.TelegramID)
+			for _, event := range notifyEvents {
+				var channels []string
+				for _, val := range r.Form["channel_"+event] {
+					if isNotifyChannel(val) {
+						channels = append(channels, val)
+					}
+				}
 
-	return nav
-}
+				if strings.Join(channels, ",") == strings.Join(eventsChannels[event], ",") {
+					continue
+				}
+
+				err := saveEventChannels(u.TelegramID, event, channels)
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+			}
+
+			mutedTasks := make(map[int]bool)
+			for _, val := range strings.FieldsFunc(r.FormValue("mutedTasks"), func(r rune) bool { return r == ',' || r == ' ' }) {
+				taskID, err := strconv.Atoi(strings.TrimPrefix(val, "#"))
+				if err != nil {
+					http.Error(w, fmt.Sprintf("Wrong Task number %v", val), http.StatusBadRequest)
+					return
+				}
+				mutedTasks[taskID] = true
+			}
+
+			for _, taskID := range selectMutedTasks(u.TelegramID) {
+				if mutedTasks[taskID] {
+					delete(mutedTasks, taskID)
+					continue
+				}
+
+				err := unmuteTask(u.TelegramID, taskID)
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+			}
+
+			for taskID := range mutedTasks {
+				err := muteTask(u.TelegramID, taskID)
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+			}
+
+			mutedTeams := make(map[string]bool)
+			for _, val := range r.Form["mutedTeams"] {
+				mutedTeams[val] = true
+			}
+
+			//заглушити можна лише команди, в яких користувач є учасником
+			for _, t := range userTeams(u.TelegramID) {
+				if mutedTeams[strconv.Itoa(t.ID)] {
+					err = muteTeam(u.TelegramID, t.ID)
+				} else {
+					err = unmuteTeam(u.TelegramID, t.ID)
+				}
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+			}
+
+			if u.ID == user.ID {
+				user = u
+			}
+
+			http.Redirect(w, r, fmt.Sprintf("/user?id=%v", u.TelegramID), http.StatusSeeOther)
+			return
+		case "token":
+			//токен показуємо лише один раз і тільки власнику
+			if u.TelegramID != user.TelegramID {
+				http.Error(w, "Access denied", http.StatusNotFound)
+				return
+			}
+
+			td.APIToken, err = newAPIToken(u.TelegramID)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		case "calendar":
+			//посилання на календар містить токен, тому його теж показуємо лише один раз
+			if u.TelegramID != user.TelegramID {
+				http.Error(w, "Access denied", http.StatusNotFound)
+				return
+			}
+
+			token, err := newCalendarToken(u.TelegramID)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			td.CalendarURL = calendarURL(r, token)
+		case "nocalendar":
+			if u.TelegramID != user.TelegramID {
+				http.Error(w, "Access denied", http.StatusNotFound)
+				return
+			}
+
+			err = deleteCalendarToken(u.TelegramID)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			http.Redirect(w, r, fmt.Sprintf("/user?id=%v", u.TelegramID), http.StatusSeeOther)
+			return
+		case "session":
+			sessionID, err := strconv.Atoi(r.FormValue("session"))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			for _, val := range selectUserSessions(u.TelegramID) {
+				if val.ID != sessionID {
+					continue
+				}
+
+				err = deleteSession(val.ID)
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+			}
+
+			http.Redirect(w, r, fmt.Sprintf("/user?id=%v", u.TelegramID), http.StatusSeeOther)
+			return
+		case "role":
+			//власну роль змінювати не можна, щоб не залишитися без адміністратора
+			if !can(user, permChangeRoles) || u.TelegramID == user.TelegramID {
+				http.Error(w, "Access denied", http.StatusNotFound)
+				return
+			}
+
+			role := r.FormValue("role")
+			if !isRole(role) {
+				http.Error(w, fmt.Sprintf("Unknown role %v", role), http.StatusBadRequest)
+				return
+			}
+
+			_, err = setUserRole(u, user, role)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			http.Redirect(w, r, fmt.Sprintf("/user?id=%v", u.TelegramID), http.StatusSeeOther)
+			return
+		}
+	}
+
+	td.NavBar.LoggedIn = true
+	td.NavBar.MainMenu = getMainMenu("")
+	td.NavBar.User = user
+	td.NavBar.CSRF = csrfToken(r)
+
+	td.User = u
+	td.Role = userRole(u)
+	td.Settings = dbase.GetUserSettings(cfg, u.TelegramID)
+
+	if can(user, permChangeRoles) && u.TelegramID != user.TelegramID {
+		td.Roles = roles
+	}
+
+	rows, err := dbase.SelectAPITokensByTelegramID(cfg, u.TelegramID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	td.HasAPIToken = rows.Next()
+	rows.Close()
+
+	td.HasCalendarToken, err = hasCalendarToken(u.TelegramID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	for _, taskID := range selectMutedTasks(u.TelegramID) {
+		td.MutedTasks = append(td.MutedTasks, strconv.Itoa(taskID))
+	}
+
+	mutedTeams := make(map[int]bool)
+	for _, teamID := range selectMutedTeams(u.TelegramID) {
+		mutedTeams[teamID] = true
+	}
+	for _, t := range userTeams(u.TelegramID) {
+		td.MutedTeams = append(td.MutedTeams, models.TplTeamMute{Team: t, Muted: mutedTeams[t.ID]})
+	}
+
+	eventsChannels := eventChannels(u.TelegramID)
+	for _, event := range notifyEvents {
+		ec := models.TplEventChannels{Event: event}
+		for _, val := range eventsChannels[event] {
+			switch val {
+			case notifier.ChannelTelegram:
+				ec.Telegram = true
+			case notifier.ChannelEmail:
+				ec.Email = true
+			case notifier.ChannelWebhook:
+				ec.Webhook = true
+			}
+		}
+		td.EventChannels = append(td.EventChannels, ec)
+	}
+
+	currentSession := currentSessionID(r)
+	for _, val := range selectUserSessions(u.TelegramID) {
+		td.Sessions = append(td.Sessions, models.TplSession{Session: val, Current: val.ID == currentSession})
+	}
+
+	rows, err = dbase.SelectNotificationLogByTelegramID(cfg, u.TelegramID, 20)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var nl models.DbNotificationLog
+	for rows.Next() {
+		err := dbase.ScanNotificationLog(rows, &nl)
+		if err != nil {
+			log.Println(err)
+		} else {
+			td.Deliveries = append(td.Deliveries, nl)
+		}
+	}
+	rows.Close()
+
+	err = tpl.ExecuteTemplate(w, "user.gohtml", td)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+//webhooksHandler lets users with manage webhooks permission register outgoing webhooks and see their delivery history
+func webhooksHandler(w http.ResponseWriter, r *http.Request) {
+
+	var td models.TplWebhooks
+	var wh models.DbWebhooks
+
+	user := currentUser(r)
+
+	if !can(user, permManageWebhooks) {
+		http.Error(w, "Access denied", http.StatusNotFound)
+		return
+	}
+
+	do := r.FormValue("do")
+	switch do {
+	case "add":
+		wh.URL = strings.TrimSpace(r.FormValue("url"))
+		if !strings.HasPrefix(wh.URL, "http://") && !strings.HasPrefix(wh.URL, "https://") {
+			http.Error(w, fmt.Sprintf("Wrong webhook URL %v", wh.URL), http.StatusBadRequest)
+			return
+		}
+
+		var events []string
+		for _, val := range r.Form["events"] {
+			for _, event := range webhookEvents {
+				if val == event {
+					events = append(events, val)
+				}
+			}
+		}
+		if len(events) == 0 {
+			http.Error(w, "Choose at least one event", http.StatusBadRequest)
+			return
+		}
+
+		secret, err := newWebhookSecret()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		wh.Secret = secret
+		wh.Events = strings.Join(events, ",")
+		wh.Active = 1
+		wh.CreatedBy = user.TelegramID
+		wh.CreatedAt = models.NullTime{Time: time.Now().UTC(), Valid: true}
+
+		stmt, err := dbase.InsertWebhook(cfg)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		_, err = dbase.ExecInsertWebhook(stmt, wh)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		http.Redirect(w, r, "/webhooks", http.StatusSeeOther)
+		return
+	case "enable", "disable":
+		id, err := strconv.Atoi(r.FormValue("id"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		active := 0
+		if do == "enable" {
+			active = 1
+		}
+
+		stmt, err := dbase.UpdateWebhookActive(cfg)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		_, err = stmt.Exec(active, id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		http.Redirect(w, r, "/webhooks", http.StatusSeeOther)
+		return
+	case "delete":
+		id, err := strconv.Atoi(r.FormValue("id"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		stmt, err := dbase.DeleteWebhookDeliveries(cfg)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		_, err = stmt.Exec(id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		stmt, err = dbase.DeleteWebhook(cfg)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		_, err = stmt.Exec(id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		http.Redirect(w, r, "/webhooks", http.StatusSeeOther)
+		return
+	}
+
+	rows, err := dbase.SelectWebhooks(cfg)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	for rows.Next() {
+		err := dbase.ScanWebhook(rows, &wh)
+		if err != nil {
+			log.Println(err)
+		} else {
+			td.Webhooks = append(td.Webhooks, wh)
+		}
+	}
+	rows.Close()
+
+	//якщо вказано ID, то покажемо історію відправок для цього webhook
+	id, err := strconv.Atoi(r.FormValue("id"))
+	if err == nil {
+		for _, val := range td.Webhooks {
+			if val.ID == id {
+				td.Webhook = val
+			}
+		}
+
+		rows, err := dbase.SelectWebhookDeliveries(cfg, id, 50)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		var wd models.DbWebhookDeliveries
+		for rows.Next() {
+			err := dbase.ScanWebhookDelivery(rows, &wd)
+			if err != nil {
+				log.Println(err)
+			} else {
+				td.Deliveries = append(td.Deliveries, wd)
+			}
+		}
+		rows.Close()
+	}
+
+	td.Events = webhookEvents
+	td.NavBar.LoggedIn = true
+	td.NavBar.MainMenu = getMainMenu("")
+	td.NavBar.User = user
+	td.NavBar.CSRF = csrfToken(r)
+
+	err = tpl.ExecuteTemplate(w, "webhooks.gohtml", td)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+//adminUsersHandler lists users by status with search, moderates them in bulk and shows their history
+func adminUsersHandler(w http.ResponseWriter, r *http.Request) {
+
+	var td models.TplAdminUsers
+	var u models.DbUsers
+
+	user := currentUser(r)
+
+	if !can(user, permViewUsers) {
+		http.Error(w, "Access denied", http.StatusNotFound)
+		return
+	}
+
+	status := r.FormValue("status")
+	if status != models.UserApprowed && status != models.UserBanned {
+		status = models.UserRequested
+	}
+
+	do := r.FormValue("do")
+	switch do {
+	case "approve", "ban", "unban":
+		//дозволені переходи: заявку можна схвалити чи відхилити, активного користувача забанити, забаненого розбанити
+		var newStatus string
+		var fromStatuses []string
+
+		switch do {
+		case "approve":
+			if !can(user, permApproveUsers) {
+				http.Error(w, "Access denied", http.StatusNotFound)
+				return
+			}
+			newStatus = models.UserApprowed
+			fromStatuses = []string{models.UserRequested}
+		case "ban":
+			if !can(user, permBanUsers) {
+				http.Error(w, "Access denied", http.StatusNotFound)
+				return
+			}
+			newStatus = models.UserBanned
+			fromStatuses = []string{models.UserRequested, models.UserApprowed}
+		case "unban":
+			if !can(user, permBanUsers) {
+				http.Error(w, "Access denied", http.StatusNotFound)
+				return
+			}
+			newStatus = models.UserApprowed
+			fromStatuses = []string{models.UserBanned}
+		}
+
+		reason := strings.TrimSpace(r.FormValue("reason"))
+
+		for _, val := range r.Form["ids"] {
+			tgid, err := strconv.Atoi(val)
+			if err != nil || tgid == user.TelegramID {
+				continue
+			}
+
+			u := dbase.GetUserByTelegramID(cfg, tgid)
+			allowed := false
+			for _, from := range fromStatuses {
+				if u.Status == from {
+					allowed = true
+				}
+			}
+			if u.ID == 0 || !allowed {
+				continue
+			}
+
+			_, err = changeUserStatus(u, user, newStatus, reason)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		http.Redirect(w, r, fmt.Sprintf("/admin/users?status=%v", status), http.StatusSeeOther)
+		return
+	case "role":
+		tgid, err := strconv.Atoi(r.FormValue("id"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		role := r.FormValue("role")
+		if !can(user, permChangeRoles) || tgid == user.TelegramID || !isRole(role) {
+			http.Error(w, "Access denied", http.StatusNotFound)
+			return
+		}
+
+		u := dbase.GetUserByTelegramID(cfg, tgid)
+		if u.ID == 0 {
+			http.Error(w, fmt.Sprintf("Can't find any user %v", tgid), http.StatusNotFound)
+			return
+		}
+
+		_, err = setUserRole(u, user, role)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		http.Redirect(w, r, fmt.Sprintf("/admin/users?status=%v&id=%v", u.Status, u.TelegramID), http.StatusSeeOther)
+		return
+	}
+
+	td.Status = status
+	td.Statuses = []string{models.UserRequested, models.UserApprowed, models.UserBanned}
+	td.Search = strings.TrimSpace(r.FormValue("search"))
+
+	var rows *sql.Rows
+	var err error
+
+	if td.Search == "" {
+		rows, err = dbase.SelectUsersByStatus(cfg, status)
+	} else {
+		rows, err = dbase.SelectUsersByStatusSearch(cfg, status, "%"+strings.ToLower(td.Search)+"%")
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	for rows.Next() {
+		err := dbase.ScanUser(rows, &u)
+		if err != nil {
+			log.Println(err)
+		} else {
+			td.Users = append(td.Users, u)
+		}
+	}
+	rows.Close()
+
+	//якщо вказано ID, то покажемо історію змін користувача
+	tgid, err := strconv.Atoi(r.FormValue("id"))
+	if err == nil {
+		td.User = dbase.GetUserByTelegramID(cfg, tgid)
+		td.Role = userRole(td.User)
+
+		for _, val := range selectUserHistory(td.User) {
+			td.History = append(td.History, models.TplUserHistory{History: val, ChangedBy: dbase.GetUserByTelegramID(cfg, val.ChangedBy)})
+		}
+
+		if can(user, permChangeRoles) && td.User.TelegramID != user.TelegramID {
+			td.Roles = roles
+		}
+	}
+
+	td.CanApprove = can(user, permApproveUsers)
+	td.CanBan = can(user, permBanUsers)
+
+	td.NavBar.LoggedIn = true
+	td.NavBar.MainMenu = getMainMenu("")
+	td.NavBar.User = user
+	td.NavBar.CSRF = csrfToken(r)
+
+	err = tpl.ExecuteTemplate(w, "admin_users.gohtml", td)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+//teamsHandler lets admins create teams and manage their members and leads
+func teamsHandler(w http.ResponseWriter, r *http.Request) {
+
+	var td models.TplTeams
+	var u models.DbUsers
+
+	user := currentUser(r)
+
+	if !can(user, permManageTeams) {
+		http.Error(w, "Access denied", http.StatusNotFound)
+		return
+	}
+
+	var err error
+
+	do := r.FormValue("do")
+	switch do {
+	case "add":
+		name := strings.TrimSpace(r.FormValue("name"))
+		if name == "" {
+			http.Error(w, "Team name is empty", http.StatusBadRequest)
+			return
+		}
+
+		err = addTeam(name, user)
+	case "delete":
+		id, convErr := strconv.Atoi(r.FormValue("id"))
+		if convErr != nil {
+			http.Error(w, convErr.Error(), http.StatusBadRequest)
+			return
+		}
+
+		err = deleteTeam(id)
+	case "join":
+		id, convErr := strconv.Atoi(r.FormValue("id"))
+		if convErr != nil {
+			http.Error(w, convErr.Error(), http.StatusBadRequest)
+			return
+		}
+
+		tgid, convErr := strconv.Atoi(r.FormValue("tgid"))
+		if convErr != nil {
+			http.Error(w, convErr.Error(), http.StatusBadRequest)
+			return
+		}
+
+		err = addTeamMember(id, tgid)
+	case "leave", "lead":
+		id, convErr := strconv.Atoi(r.FormValue("id"))
+		if convErr != nil {
+			http.Error(w, convErr.Error(), http.StatusBadRequest)
+			return
+		}
+
+		memberID, convErr := strconv.Atoi(r.FormValue("member"))
+		if convErr != nil {
+			http.Error(w, convErr.Error(), http.StatusBadRequest)
+			return
+		}
+
+		for _, val := range selectTeamMembers(id) {
+			if val.ID != memberID {
+				continue
+			}
+
+			if do == "leave" {
+				err = removeTeamMember(val.ID)
+			} else {
+				err = setTeamLead(val.ID, 1-val.Lead)
+			}
+		}
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if do != "" {
+		http.Redirect(w, r, "/teams", http.StatusSeeOther)
+		return
+	}
+
+	for _, t := range selectTeams() {
+		tt := models.TplTeam{Team: t}
+		for _, m := range selectTeamMembers(t.ID) {
+			tt.Members = append(tt.Members, models.TplTeamMember{Member: m, User: dbase.GetUserByTelegramID(cfg, m.TelegramID)})
+		}
+		td.Teams = append(td.Teams, tt)
+	}
+
+	rows, err := dbase.SelectUsersByStatus(cfg, models.UserApprowed)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	for rows.Next() {
+		err := dbase.ScanUser(rows, &u)
+		if err != nil {
+			log.Println(err)
+		} else {
+			td.Users = append(td.Users, u)
+		}
+	}
+	rows.Close()
+
+	td.NavBar.LoggedIn = true
+	td.NavBar.MainMenu = getMainMenu("")
+	td.NavBar.User = user
+	td.NavBar.CSRF = csrfToken(r)
+
+	err = tpl.ExecuteTemplate(w, "teams.gohtml", td)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+//invitesHandler lists outstanding invites and lets users who approve users create and revoke them
+func invitesHandler(w http.ResponseWriter, r *http.Request) {
+
+	var td models.TplInvites
+
+	user := currentUser(r)
+
+	if !can(user, permApproveUsers) {
+		http.Error(w, "Access denied", http.StatusNotFound)
+		return
+	}
+
+	do := r.FormValue("do")
+	switch do {
+	case "add":
+		teamID, _ := strconv.Atoi(r.FormValue("team"))
+		uses, _ := strconv.Atoi(r.FormValue("uses"))
+		days, _ := strconv.Atoi(r.FormValue("days"))
+
+		_, err := newInvite(user, r.FormValue("role"), teamID, uses, days)
+		if err == errInviteRole {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	case "revoke":
+		id, err := strconv.Atoi(r.FormValue("id"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		err = revokeInvite(id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if do != "" {
+		http.Redirect(w, r, "/invites", http.StatusSeeOther)
+		return
+	}
+
+	for _, i := range selectInvites() {
+		td.Invites = append(td.Invites, models.TplInvite{
+			Invite:    i,
+			Link:      inviteLink(i.Code),
+			Team:      getTeamByID(i.TeamID),
+			CreatedBy: dbase.GetUserByTelegramID(cfg, i.CreatedBy),
+		})
+	}
+
+	td.Roles = []string{models.RoleMember, models.RoleGuest}
+	if can(user, permChangeRoles) {
+		td.Roles = roles
+	}
+	td.Teams = selectTeams()
+
+	td.NavBar.LoggedIn = true
+	td.NavBar.MainMenu = getMainMenu("")
+	td.NavBar.User = user
+	td.NavBar.CSRF = csrfToken(r)
+
+	err := tpl.ExecuteTemplate(w, "invites.gohtml", td)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+func getTasksTabs(taskType string, status string) string {
+
+	if status == "" {
+		status = models.TaskStatusNew
+	}
+
+	var xs []string
+
+	xs = append(xs, models.TaskStatusNew)
+	xs = append(xs, models.TaskStatusStarted)
+	xs = append(xs, models.TaskStatusRejected)
+	xs = append(xs, models.TaskStatusCompleted)
+	xs = append(xs, models.TaskStatusClosed)
+
+	nav := `<div class="row">
+	<ul class="nav nav-tabs">`
+
+	for _, val := range xs {
+		nav += fmt.Sprintf(`<li class="nav-item">
+	<a class="nav-link%v" href="/tasks?type=%v&status=%v">%v</a>
+	</li>`, isStatusActive(status, val), taskType, strings.ToLower(val), val)
+	}
+
+	nav += fmt.Sprintln(`	
+		</ul>
+	</div>`)
+
+	return nav
+}
 
 func isStatusActive(currentStatus string, status string) string {
 	if currentStatus == status {
@@ -816,6 +1772,13 @@ func getMainMenu(currentMenu string) []models.TplMainMenu {
 	}
 	mm = append(mm, m)
 
+	m.Link = "/import"
+	m.Alias = `<i class="fas fa-file-import"></i> Import`
+	if currentMenu == "import" {
+		m.Alias += `<span class="sr-only">(current)</span>`
+	}
+	mm = append(mm, m)
+
 	return mm
 }
 
@@ -833,99 +1796,18 @@ func logoutHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	sessionUUID := c.Value
 	stmt, err := dbase.DeleteSessionByUUID(cfg)
 	if err != nil {
 		log.Println(fmt.Errorf("Can't get Delete session stmt. %v", err))
-	}
-
-	_, err = stmt.Exec(sessionUUID)
-	if err != nil {
-		log.Println(fmt.Errorf("Can't Delete session UUID: %v. %v", sessionUUID, err))
-	}
-
-	c.MaxAge = -1
-	http.SetCookie(w, c)
-
-	http.Redirect(w, r, "/", http.StatusSeeOther)
-}
-
-func alreadyLoggedIn(w http.ResponseWriter, r *http.Request, sessionUUID string) (bool, models.DbUsers) {
-
-	var u models.DbUsers
-	ok := false
-
-	if time.Now().Sub(lastSessionCleaned) > (time.Duration(sessionLenght) * time.Second) {
-		//go cleanSession()
-	}
-
-	c, err := r.Cookie("session")
-	if err != nil {
-		return ok, u
-	}
-
-	if sessionUUID == "" {
-		sessionUUID = c.Value
-	}
-
-	stmt, err := dbase.UpdateSessionLastActivityByUuid(cfg)
-	if err != nil {
-		return ok, u
-	}
-
-	_, err = stmt.Exec(time.Now().UTC(), sessionUUID)
-	if err != nil {
-		return ok, u
-	}
-
-	rows, err := dbase.SelectUsersBySessionUUID(cfg, sessionUUID)
-
-	if err != nil {
-		panic(err)
-	}
-
-	if rows.Next() {
-		err := dbase.ScanUser(rows, &u)
+	} else {
+		_, err = stmt.Exec(hashToken(c.Value))
 		if err != nil {
-			return ok, u
+			log.Println(fmt.Errorf("Can't Delete session. %v", err))
 		}
-		ok = true
 	}
-	rows.Close()
 
-	c.MaxAge = sessionLenght
-	http.SetCookie(w, c)
+	http.SetCookie(w, newSessionCookie(r, "", -1))
 
-	return ok, u
+	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
 
-func cleanSession() {
-
-	rows, err := dbase.SelectSessions(cfg)
-	if err != nil {
-		panic(err)
-	}
-	defer rows.Close()
-
-	var s models.DbSessions
-
-	for rows.Next() {
-		err = dbase.ScanSession(rows, &s)
-		if err == nil {
-			if time.Now().Sub(s.LastActivity.Time) > (time.Duration(sessionLenght) * time.Second) {
-				stmt, err := dbase.DeleteSessionByUUID(cfg)
-				if err != nil {
-					log.Println()
-					continue
-				}
-				_, err = stmt.Exec(s.ID)
-				if err != nil {
-					log.Println()
-					continue
-				}
-			}
-		}
-	}
-
-	lastSessionCleaned = time.Now()
-}
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/slevchyk/taskeram/models"
+)
+
+//loginAs starts the session of the user and returns its cookie
+func loginAs(t *testing.T, tgid int) *http.Cookie {
+
+	w := httptest.NewRecorder()
+	err := startSession(w, httptest.NewRequest(http.MethodGet, "/login", nil), tgid)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range w.Result().Cookies() {
+		if c.Name == "session" {
+			return c
+		}
+	}
+
+	t.Fatal("session cookie isn't set")
+	return nil
+}
+
+//postTask sends the form to /task with CSRF token of the session
+func postTask(rt http.Handler, session *http.Cookie, form url.Values) *httptest.ResponseRecorder {
+
+	r := httptest.NewRequest(http.MethodPost, "/task", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.AddCookie(session)
+	r.Header.Set("X-CSRF-Token", hashToken("csrf:"+session.Value))
+
+	w := httptest.NewRecorder()
+	rt.ServeHTTP(w, r)
+
+	return w
+}
+
+func TestRequireLogin(t *testing.T) {
+
+	setupTestDB(t)
+	rt := newWebRouter()
+
+	for _, path := range []string{"/", "/tasks", "/tasks/1", "/task", "/user"} {
+		for _, session := range []string{"", "bogus"} {
+			r := httptest.NewRequest(http.MethodGet, path, nil)
+			if session != "" {
+				r.AddCookie(&http.Cookie{Name: "session", Value: session})
+			}
+
+			w := httptest.NewRecorder()
+			rt.ServeHTTP(w, r)
+
+			if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/login" {
+				t.Errorf("GET %v with session %q: got %v to %q, want redirect to /login", path, session, w.Code, w.Header().Get("Location"))
+			}
+		}
+	}
+}
+
+func TestTaskUpdateByGet(t *testing.T) {
+
+	task := setupTestDB(t)
+	rt := newWebRouter()
+	session := loginAs(t, testAssigneeID)
+
+	for _, path := range []string{"/tasks/1?do=update&id=1&status=started", "/task?do=update&id=1&status=started"} {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		r.AddCookie(session)
+
+		w := httptest.NewRecorder()
+		rt.ServeHTTP(w, r)
+
+		if w.Code != http.StatusMethodNotAllowed && w.Code != http.StatusForbidden {
+			t.Errorf("GET %v: status %v, want it rejected", path, w.Code)
+		}
+	}
+
+	if status := taskStatus(t, task.ID); status != models.TaskStatusNew {
+		t.Errorf("status of Task #%v is changed to %v by GET", task.ID, status)
+	}
+}
+
+func TestTaskUpdateWithoutCSRF(t *testing.T) {
+
+	task := setupTestDB(t)
+	rt := newWebRouter()
+	session := loginAs(t, testAdminID)
+
+	form := url.Values{"do": {"update"}, "id": {"1"}, "status": {"closed"}}
+	r := httptest.NewRequest(http.MethodPost, "/task", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.AddCookie(session)
+
+	w := httptest.NewRecorder()
+	rt.ServeHTTP(w, r)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("POST without CSRF token: status %v, want %v", w.Code, http.StatusForbidden)
+	}
+
+	if status := taskStatus(t, task.ID); status != models.TaskStatusNew {
+		t.Errorf("status of Task #%v is changed to %v without CSRF token", task.ID, status)
+	}
+}
+
+func TestTaskForbiddenTransition(t *testing.T) {
+
+	task := setupTestDB(t)
+	rt := newWebRouter()
+
+	tests := []struct {
+		tgid   int
+		status string
+	}{
+		//виконавець не може закрити задачу, а автор - взяти її в роботу
+		{testAssigneeID, "closed"},
+		{testAdminID, "started"},
+		{testAssigneeID, "whatever"},
+	}
+
+	for _, tt := range tests {
+		w := postTask(rt, loginAs(t, tt.tgid), url.Values{"do": {"update"}, "id": {"1"}, "status": {tt.status}})
+
+		if w.Code != http.StatusForbidden {
+			t.Errorf("user %v sets %v: status %v, want %v", tt.tgid, tt.status, w.Code, http.StatusForbidden)
+		}
+	}
+
+	if status := taskStatus(t, task.ID); status != models.TaskStatusNew {
+		t.Errorf("status of Task #%v is changed to %v by forbidden transition", task.ID, status)
+	}
+}
+
+func TestTaskUpdateOfAnotherUser(t *testing.T) {
+
+	task := setupTestDB(t)
+	rt := newWebRouter()
+
+	addTestUser(t, 3, "Stranger", models.RoleMember)
+
+	w := postTask(rt, loginAs(t, 3), url.Values{"do": {"update"}, "id": {"1"}, "status": {"closed"}})
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("stranger updates Task: status %v, want %v", w.Code, http.StatusNotFound)
+	}
+
+	if status := taskStatus(t, task.ID); status != models.TaskStatusNew {
+		t.Errorf("status of Task #%v is changed to %v by stranger", task.ID, status)
+	}
+}
+
+func TestTaskAllowedTransition(t *testing.T) {
+
+	task := setupTestDB(t)
+	rt := newWebRouter()
+
+	w := postTask(rt, loginAs(t, testAssigneeID), url.Values{"do": {"update"}, "id": {"1"}, "status": {"started"}})
+
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/tasks/1" {
+		t.Errorf("assignee starts Task: got %v to %q, want redirect to /tasks/1", w.Code, w.Header().Get("Location"))
+	}
+
+	if status := taskStatus(t, task.ID); status != models.TaskStatusStarted {
+		t.Errorf("status of Task #%v is %v, want %v", task.ID, status, models.TaskStatusStarted)
+	}
+
+	w = postTask(rt, loginAs(t, testAdminID), url.Values{"do": {"update"}, "id": {"1"}, "status": {"close"}})
+
+	if w.Code != http.StatusSeeOther {
+		t.Errorf("author closes Task: status %v, want %v", w.Code, http.StatusSeeOther)
+	}
+
+	if status := taskStatus(t, task.ID); status != models.TaskStatusClosed {
+		t.Errorf("status of Task #%v is %v, want %v", task.ID, status, models.TaskStatusClosed)
+	}
+}
+
+//postAPI sends the request to the task page API the same way as the page script does
+func postAPI(rt http.Handler, session *http.Cookie, path string, body string) *httptest.ResponseRecorder {
+
+	r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	r.Header.Set("Origin", "http://example.com")
+	r.AddCookie(session)
+	r.Header.Set("X-CSRF-Token", hashToken("csrf:"+session.Value))
+
+	w := httptest.NewRecorder()
+	rt.ServeHTTP(w, r)
+
+	return w
+}
+
+func TestAPIAccess(t *testing.T) {
+
+	task := setupTestDB(t)
+	rt := newWebRouter()
+
+	addTestUser(t, 3, "Stranger", models.RoleMember)
+
+	tests := []struct {
+		tgid   int
+		path   string
+		body   string
+		code   int
+		status string
+	}{
+		{3, "/api/updatetaskstatus?id=1&status=Closed", "", http.StatusNotFound, models.TaskStatusNew},
+		{3, "/api/commenttask?id=1", "spam", http.StatusNotFound, models.TaskStatusNew},
+		{testAssigneeID, "/api/updatetaskstatus?id=1&status=Closed", "", http.StatusForbidden, models.TaskStatusNew},
+		{testAssigneeID, "/api/updatetaskstatus?id=1&status=Started", "", http.StatusOK, models.TaskStatusStarted},
+		{testAssigneeID, "/api/commenttask?id=1", "on it", http.StatusOK, models.TaskStatusStarted},
+	}
+
+	for _, tt := range tests {
+		w := postAPI(rt, loginAs(t, tt.tgid), tt.path, tt.body)
+
+		if w.Code != tt.code {
+			t.Errorf("user %v %v: status %v, want %v", tt.tgid, tt.path, w.Code, tt.code)
+		}
+
+		if status := taskStatus(t, task.ID); status != tt.status {
+			t.Errorf("user %v %v: Task status %v, want %v", tt.tgid, tt.path, status, tt.status)
+		}
+	}
+
+	task, err := getTask(task.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if task.Comment != "on it" || task.CommentedBy != testAssigneeID {
+		t.Errorf("Task comment %q by %v, want %q by %v", task.Comment, task.CommentedBy, "on it", testAssigneeID)
+	}
+}
+
+func TestLoginWithBrokenUsersTable(t *testing.T) {
+
+	setupTestDB(t)
+	session := loginAs(t, testAssigneeID)
+
+	_, err := cfg.DB.Exec("ALTER TABLE users RENAME TO users_old")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(session)
+
+	loggedIn, u := alreadyLoggedIn(httptest.NewRecorder(), r, "")
+	if loggedIn || u.ID != 0 {
+		t.Errorf("user %v is logged in while users can't be read", u.TelegramID)
+	}
+}
+
+func TestAPIRequiresPostWithCSRF(t *testing.T) {
+
+	task := setupTestDB(t)
+	rt := newWebRouter()
+	session := loginAs(t, testAssigneeID)
+
+	for _, path := range []string{"/api/updatetaskstatus?id=1&status=Started", "/api/commenttask?id=1"} {
+		for _, method := range []string{http.MethodGet, http.MethodPost} {
+			r := httptest.NewRequest(method, path, strings.NewReader("spam"))
+			r.Header.Set("Origin", "http://example.com")
+			r.AddCookie(session)
+
+			w := httptest.NewRecorder()
+			rt.ServeHTTP(w, r)
+
+			if w.Code != http.StatusMethodNotAllowed && w.Code != http.StatusForbidden {
+				t.Errorf("%v %v without CSRF token: status %v, want it rejected", method, path, w.Code)
+			}
+		}
+	}
+
+	task, err := getTask(task.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if task.Status != models.TaskStatusNew || task.Comment != "" {
+		t.Errorf("Task is changed to %v with comment %q", task.Status, task.Comment)
+	}
+}
+
+func TestAPIErrors(t *testing.T) {
+
+	setupTestDB(t)
+	rt := newWebRouter()
+	session := loginAs(t, testAssigneeID)
+
+	tests := []struct {
+		path string
+		body string
+		code int
+	}{
+		{"/api/updatetaskstatus?id=1", "", http.StatusBadRequest},
+		{"/api/commenttask?id=1", "", http.StatusBadRequest},
+		{"/api/updatetaskstatus?id=x&status=Started", "", http.StatusBadRequest},
+		{"/api/updatetaskstatus?id=99&status=Started", "", http.StatusNotFound},
+		{"/api/commenttask?id=99", "on it", http.StatusNotFound},
+		{"/api/commenttask?id=1", strings.Repeat("a", apiMaxCommentSize+1), http.StatusRequestEntityTooLarge},
+		{"/api/updatetaskstatus?id=1&status=Started&session=bogus", "", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		w := postAPI(rt, session, tt.path, tt.body)
+
+		if w.Code != tt.code {
+			t.Errorf("%v: status %v, want %v", tt.path, w.Code, tt.code)
+		}
+	}
+}
+
+func TestTaskNotFound(t *testing.T) {
+
+	setupTestDB(t)
+	rt := newWebRouter()
+
+	r := httptest.NewRequest(http.MethodGet, "/tasks/99", nil)
+	r.AddCookie(loginAs(t, testAdminID))
+
+	w := httptest.NewRecorder()
+	rt.ServeHTTP(w, r)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("GET /tasks/99: status %v, want %v", w.Code, http.StatusNotFound)
+	}
+}
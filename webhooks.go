@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/slevchyk/taskeram/dbase"
+	"github.com/slevchyk/taskeram/models"
+	"github.com/slevchyk/taskeram/notifier"
+)
+
+const (
+	webhookAttempts = 5
+	webhookPause    = time.Second
+	webhookTimeout  = 10 * time.Second
+)
+
+//webhooksInFlight counts webhook deliveries in progress, commands and shutdown wait for them
+var webhooksInFlight sync.WaitGroup
+
+var webhookEvents = []string{models.WebhookTaskCreated, models.WebhookTaskStatusChanged, models.WebhookTaskCommented, models.WebhookUserApproved}
+
+//webhookPayload is the JSON body posted to admin webhooks
+type webhookPayload struct {
+	Event     string          `json:"event"`
+	SentAt    time.Time       `json:"sent_at"`
+	Task      *models.DbTasks `json:"task,omitempty"`
+	User      *models.DbUsers `json:"user,omitempty"`
+	By        *models.DbUsers `json:"by,omitempty"`
+	OldStatus string          `json:"old_status,omitempty"`
+	Comment   string          `json:"comment,omitempty"`
+}
+
+//fireWebhooks posts the event to every active webhook subscribed to it.
+//Delivery runs in background, so a slow receiver doesn't hold up the bot. waitWebhooks waits for it
+func fireWebhooks(p webhookPayload) {
+
+	var wh models.DbWebhooks
+	var xs []models.DbWebhooks
+
+	rows, err := dbase.SelectWebhooks(cfg)
+	if err != nil {
+		log.Println(fmt.Errorf("SelectWebhooks: %v", err))
+		return
+	}
+
+	for rows.Next() {
+		err := dbase.ScanWebhook(rows, &wh)
+		if err != nil {
+			log.Println(err)
+		} else if wh.Active != 0 && webhookSubscribed(wh, p.Event) {
+			xs = append(xs, wh)
+		}
+	}
+	rows.Close()
+
+	if len(xs) == 0 {
+		return
+	}
+
+	p.SentAt = time.Now().UTC()
+
+	body, err := json.Marshal(p)
+	if err != nil {
+		log.Println(fmt.Errorf("webhook payload: %v", err))
+		return
+	}
+
+	for _, wh := range xs {
+		webhooksInFlight.Add(1)
+		go func(wh models.DbWebhooks) {
+			defer webhooksInFlight.Done()
+			deliverWebhook(wh, p.Event, body)
+		}(wh)
+	}
+}
+
+//waitWebhooks waits for webhook deliveries started by fireWebhooks, so the process doesn't exit in the middle of them.
+//Retries of a delivery are limited, so the wait is limited too
+func waitWebhooks() {
+
+	webhooksInFlight.Wait()
+}
+
+func webhookSubscribed(wh models.DbWebhooks, event string) bool {
+
+	for _, val := range strings.Split(wh.Events, ",") {
+		if strings.TrimSpace(val) == event {
+			return true
+		}
+	}
+
+	return false
+}
+
+//deliverWebhook posts the body retrying failed attempts with a doubling pause and saves the result to the delivery history
+func deliverWebhook(wh models.DbWebhooks, event string, body []byte) {
+
+	client := &http.Client{Timeout: webhookTimeout}
+	pause := webhookPause
+
+	wd := models.DbWebhookDeliveries{
+		WebhookID: wh.ID,
+		Event:     event,
+		Payload:   string(body),
+		Status:    models.DeliveryFailed,
+	}
+
+	for wd.Attempts < webhookAttempts {
+		if wd.Attempts > 0 {
+			time.Sleep(pause)
+			pause *= 2
+		}
+		wd.Attempts++
+
+		req, err := http.NewRequest(http.MethodPost, wh.URL, bytes.NewReader(body))
+		if err != nil {
+			wd.Error = err.Error()
+			break
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Taskeram-Event", event)
+		req.Header.Set(notifier.SignatureHeader, notifier.Sign(wh.Secret, body))
+
+		resp, err := client.Do(req)
+		if err != nil {
+			wd.Error = err.Error()
+			continue
+		}
+		resp.Body.Close()
+
+		wd.ResponseCode = resp.StatusCode
+		if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+			wd.Status = models.DeliverySent
+			wd.Error = ""
+			break
+		}
+		wd.Error = resp.Status
+	}
+
+	wd.CreatedAt = models.NullTime{Time: time.Now().UTC(), Valid: true}
+
+	stmt, err := dbase.InsertWebhookDelivery(cfg)
+	if err != nil {
+		log.Println(fmt.Errorf("InsertWebhookDelivery: %v", err))
+		return
+	}
+
+	_, err = dbase.ExecInsertWebhookDelivery(stmt, wd)
+	if err != nil {
+		log.Println(fmt.Errorf("ExecInsertWebhookDelivery: %v", err))
+	}
+}
+
+//newWebhookSecret generates a random secret for signing webhook payloads
+func newWebhookSecret() (string, error) {
+
+	b := make([]byte, 32)
+
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	defaultListen       = ":80"
+	defaultReadTimeout  = 30 * time.Second
+	defaultWriteTimeout = 90 * time.Second
+)
+
+//newWebServer configures the web server from taskeram.cfg
+func newWebServer(h http.Handler) (*http.Server, error) {
+
+	srv := &http.Server{
+		Addr:         cfg.Web.Listen,
+		Handler:      h,
+		ReadTimeout:  defaultReadTimeout,
+		WriteTimeout: defaultWriteTimeout,
+	}
+
+	if srv.Addr == "" {
+		srv.Addr = defaultListen
+	}
+
+	if cfg.Web.ReadTimeout > 0 {
+		srv.ReadTimeout = time.Duration(cfg.Web.ReadTimeout) * time.Second
+	}
+
+	if cfg.Web.WriteTimeout > 0 {
+		srv.WriteTimeout = time.Duration(cfg.Web.WriteTimeout) * time.Second
+	}
+
+	if (cfg.Web.CertFile == "") != (cfg.Web.KeyFile == "") {
+		return nil, errors.New("both cert_file and key_file should be set in web config")
+	}
+
+	if cfg.Web.CertFile == "" && cfg.Web.SelfSigned {
+		cert, err := selfSignedCert(webHosts())
+		if err != nil {
+			return nil, err
+		}
+
+		log.Println("Web app uses self-signed certificate, don't use it in production")
+		srv.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+	}
+
+	return srv, nil
+}
+
+//webHosts returns host names the web app is available by
+func webHosts() []string {
+
+	hosts := []string{"localhost", "127.0.0.1"}
+
+	u, err := url.Parse(cfg.Web.URL)
+	if err == nil && u.Hostname() != "" {
+		hosts = append(hosts, u.Hostname())
+	}
+
+	return hosts
+}
+
+//selfSignedCert generates certificate for development, it is valid for a year
+func selfSignedCert(hosts []string) (tls.Certificate, error) {
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	template := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"Taskeram dev"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(1, 0, 0),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+
+	for _, val := range hosts {
+		if ip := net.ParseIP(val); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, val)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}